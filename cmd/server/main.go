@@ -4,29 +4,41 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/RoGogDBD/ecom/internal/auth"
 	"github.com/RoGogDBD/ecom/internal/config"
+	"github.com/RoGogDBD/ecom/internal/events"
 	"github.com/RoGogDBD/ecom/internal/handler"
+	"github.com/RoGogDBD/ecom/internal/idempotency"
 	"github.com/RoGogDBD/ecom/internal/logger"
+	"github.com/RoGogDBD/ecom/internal/metrics"
+	"github.com/RoGogDBD/ecom/internal/policy"
+	"github.com/RoGogDBD/ecom/internal/ratelimit"
 	"github.com/RoGogDBD/ecom/internal/repository"
 	"github.com/RoGogDBD/ecom/internal/service"
+	"github.com/RoGogDBD/ecom/internal/webhook"
 )
 
 const (
 	errServerShutdown = "server shutdown failed"
 	errLoadConfig     = "could not load config"
 	errInitLogger     = "could not initialize logger"
+	errOpenStorage    = "could not open storage"
+	errCloseStorage   = "could not close storage"
+	errLoadReminders  = "could not load reminders"
 
-	logServerStart = "Starting server on %s"
-	logServerStop  = "Server stopped"
-	logShutdown    = "Shutting down gracefully..."
-	logHTTPError   = "HTTP server error: %v"
-	logCriticalErr = "critical error: %v"
+	logServerStart = "starting server"
+	logServerStop  = "server stopped"
+	logShutdown    = "shutting down gracefully"
+	logDraining    = "draining before shutdown"
+	logHTTPError   = "HTTP server error"
+	logCriticalErr = "critical error: %v\n"
 
 	shutdownTimeout = 10 * time.Second
 )
@@ -39,41 +51,169 @@ func main() {
 }
 
 func run() error {
-	appLogger, err := logger.New()
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("%s: %w", errLoadConfig, err)
+	}
+
+	appLogger, logFile, err := logger.New(logger.Options{
+		Level:  cfg.Log.Level,
+		Format: cfg.Log.Format,
+		File: logger.RotateOptions{
+			Dir:        cfg.Log.Dir,
+			Daily:      cfg.Log.RotateDaily,
+			MaxSize:    int64(cfg.Log.MaxSizeMB) << 20,
+			Compress:   cfg.Log.Compress,
+			MaxBackups: cfg.Log.MaxBackups,
+			MaxAge:     cfg.Log.MaxAge.Std(),
+		},
+	})
 	if err != nil {
 		return fmt.Errorf("%s: %w", errInitLogger, err)
 	}
+	defer logFile.Close()
 
-	cfg, err := config.Load()
+	registry := metrics.NewRegistry()
+
+	storage, closeStorage, err := newStorage(cfg.Storage, registry)
 	if err != nil {
-		return fmt.Errorf("%s: %w", errLoadConfig, err)
+		return fmt.Errorf("%s: %w", errOpenStorage, err)
+	}
+	defer func() {
+		if err := closeStorage(); err != nil {
+			appLogger.Error(errCloseStorage, slog.Any("error", err))
+		}
+	}()
+
+	if cfg.Trash.TTL > 0 {
+		purger := service.NewPurger(storage, service.PurgerOptions{
+			TTL:      cfg.Trash.TTL.Std(),
+			Interval: cfg.Trash.PurgeInterval.Std(),
+			Logger:   appLogger,
+		})
+		defer purger.Close()
+	}
+
+	health := handler.NewHealth()
+	if checker, ok := storage.(interface{ Check(context.Context) error }); ok {
+		health.AddCheck("storage", checker.Check)
+	}
+
+	broker := events.NewBroker(events.Options{
+		Buffer:  cfg.Events.Buffer,
+		History: cfg.Events.History,
+	})
+	broker.Instrument(registry)
+
+	webhooks := webhook.New(webhook.Options{
+		Workers:              cfg.Webhooks.Workers,
+		QueueSize:            cfg.Webhooks.QueueSize,
+		MaxAttempts:          cfg.Webhooks.MaxAttempts,
+		Backoff:              cfg.Webhooks.Backoff.Std(),
+		MaxBackoff:           cfg.Webhooks.MaxBackoff.Std(),
+		Timeout:              cfg.Webhooks.Timeout.Std(),
+		History:              cfg.Webhooks.History,
+		AllowPrivateNetworks: cfg.Webhooks.AllowPrivateNetworks,
+	})
+	webhooks.Instrument(registry)
+	defer webhooks.Close()
+
+	reminders, err := service.NewReminderScheduler(context.Background(), storage, service.ReminderOptions{
+		Notifiers: []service.Notifier{
+			service.ReminderPublisher(broker),
+			service.ReminderPublisher(webhooks),
+		},
+		Logger: appLogger,
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", errLoadReminders, err)
 	}
+	defer reminders.Close()
 
-	storage := repository.NewTodoStorage()
-	todoService := service.NewTodoService(storage)
-	router := handler.NewRouter(todoService)
-	httpHandler := handler.Conveyor(
-		router,
+	serviceOpts := []service.Option{
+		service.WithEvents(broker),
+		service.WithPublisher(webhooks),
+		service.WithPublisher(reminders),
+	}
+	if cfg.Subtasks.AutoComplete {
+		serviceOpts = append(serviceOpts, service.WithAutoComplete())
+	}
+	var todoService handler.TodoService = service.NewTodoService(storage, serviceOpts...)
+	var webhookService handler.WebhookService = webhooks
+	if cfg.Auth.Enabled {
+		roles := newRoles(cfg.Auth)
+		todoService = policy.NewTodoService(todoService, roles)
+		webhookService = policy.NewWebhookService(webhookService, roles)
+	}
+	router := handler.NewRouter(todoService,
+		handler.WithMetrics(registry),
+		handler.WithHealth(health),
+		handler.WithWebhooks(webhookService),
+	)
+	// Middleware перечислены изнутри наружу: первый ближе всего к маршрутизатору.
+	var middlewares []handler.Middleware
+	if cfg.Idempotency.Enabled {
+		store := idempotency.New(cfg.Idempotency.TTL.Std())
+		defer store.Close()
+		middlewares = append(middlewares, handler.IdempotencyMiddleware(store, registry))
+	}
+	// authFailureLimit внешний по отношению к AuthMiddleware: запросы с неверными
+	// учетными данными не доходят до лимита по пользователю.
+	var authFailureLimit handler.Middleware
+	if cfg.RateLimit.Enabled {
+		rateLimit, authFailures, closeLimiter, err := newRateLimit(cfg.RateLimit, registry)
+		if err != nil {
+			return err
+		}
+		defer closeLimiter()
+		middlewares = append(middlewares, rateLimit)
+		authFailureLimit = authFailures
+	}
+	if cfg.Auth.Enabled {
+		middlewares = append(middlewares, handler.AuthMiddleware(newVerifiers(cfg.Auth)...))
+		if authFailureLimit != nil {
+			middlewares = append(middlewares, authFailureLimit)
+		}
+	}
+	middlewares = append(middlewares,
+		handler.RecoveryMiddleware(appLogger, registry),
+		handler.MetricsMiddleware(registry),
 		handler.LoggingMiddleware(appLogger),
+		handler.RequestIDMiddleware(),
 	)
+	httpHandler := handler.Conveyor(router, middlewares...)
 
 	srv := &http.Server{
 		Addr:    fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port),
 		Handler: httpHandler,
 	}
+	// Shutdown не прерывает активные соединения, поэтому потоки событий
+	// закрываются отдельно, иначе остановка ждала бы их до таймаута.
+	srv.RegisterOnShutdown(broker.Close)
 
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 
 	go func() {
-		appLogger.Printf(logServerStart, srv.Addr)
+		appLogger.Info(logServerStart, slog.String("addr", srv.Addr))
 		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			appLogger.Printf(logHTTPError, err)
+			appLogger.Error(logHTTPError, slog.Any("error", err))
 		}
 	}()
 
 	<-sigChan
-	appLogger.Println(logShutdown)
+	appLogger.Info(logShutdown)
+
+	// Сначала снимаем готовность и даем балансировщику время перестать слать запросы;
+	// повторный сигнал прерывает ожидание.
+	health.Drain()
+	if drain := cfg.Server.DrainPeriod.Std(); drain > 0 {
+		appLogger.Info(logDraining, slog.Duration("period", drain))
+		select {
+		case <-time.After(drain):
+		case <-sigChan:
+		}
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 	defer cancel()
@@ -82,6 +222,91 @@ func run() error {
 		return fmt.Errorf("%s: %w", errServerShutdown, err)
 	}
 
-	appLogger.Println(logServerStop)
+	appLogger.Info(logServerStop)
 	return nil
 }
+
+// newStorage создает хранилище согласно конфигурации, регистрирует его метрики в reg
+// и возвращает функцию для его закрытия.
+func newStorage(cfg config.StorageConfig, reg *metrics.Registry) (service.Storage, func() error, error) {
+	if cfg.Type != config.StorageFile {
+		storage := repository.NewTodoStorage()
+		storage.Instrument(reg)
+		return storage, func() error { return nil }, nil
+	}
+
+	storage, err := repository.OpenFileStorage(repository.FileOptions{
+		Dir:           cfg.Dir,
+		Sync:          repository.SyncPolicy(cfg.Sync),
+		SyncInterval:  cfg.SyncInterval.Std(),
+		SnapshotEvery: cfg.SnapshotEvery,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	storage.Instrument(reg)
+	return storage, storage.Close, nil
+}
+
+// newVerifiers создает верификаторы для включенных способов аутентификации.
+func newVerifiers(cfg config.AuthConfig) []auth.Verifier {
+	var verifiers []auth.Verifier
+
+	if len(cfg.APIKeys) > 0 {
+		keys := make(map[string]string, len(cfg.APIKeys))
+		for _, key := range cfg.APIKeys {
+			keys[key.Key] = key.Subject
+		}
+		verifiers = append(verifiers, auth.NewAPIKeyVerifier(cfg.APIKeyHeader, keys))
+	}
+
+	if cfg.JWT.Secret != "" {
+		verifiers = append(verifiers, auth.NewJWTVerifier(auth.JWTOptions{
+			Secret:   []byte(cfg.JWT.Secret),
+			Issuer:   cfg.JWT.Issuer,
+			Audience: cfg.JWT.Audience,
+			Leeway:   cfg.JWT.Leeway.Std(),
+		}))
+	}
+
+	return verifiers
+}
+
+// newRoles переводит роли из конфигурации в роли политики доступа.
+func newRoles(cfg config.AuthConfig) policy.Roles {
+	roles := policy.Roles{
+		Subjects: make(map[string]policy.Role, len(cfg.Roles)),
+		Default:  policy.Role(cfg.DefaultRole),
+	}
+	for subject, role := range cfg.Roles {
+		roles.Subjects[subject] = policy.Role(role)
+	}
+
+	return roles
+}
+
+// newRateLimit создает middleware ограничения частоты запросов, middleware ограничения
+// неудачных попыток аутентификации и функцию остановки фонового удаления корзин.
+func newRateLimit(cfg config.RateLimitConfig, reg *metrics.Registry) (handler.Middleware, handler.Middleware, func(), error) {
+	trusted, err := cfg.TrustedPrefixes()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	routes := make(map[string]ratelimit.Limit, len(cfg.Routes))
+	for route, limit := range cfg.Routes {
+		routes[route] = ratelimit.Limit{Rate: limit.Rate, Burst: limit.Burst}
+	}
+
+	limiter := ratelimit.New(cfg.IdleTTL.Std())
+	middleware := handler.RateLimitMiddleware(limiter, handler.RateLimitOptions{
+		Default:        ratelimit.Limit{Rate: cfg.Default.Rate, Burst: cfg.Default.Burst},
+		Routes:         routes,
+		TrustedProxies: trusted,
+	}, reg)
+	authFailures := handler.AuthFailureLimitMiddleware(limiter,
+		ratelimit.Limit{Rate: cfg.AuthFailures.Rate, Burst: cfg.AuthFailures.Burst}, trusted, reg)
+
+	return middleware, authFailures, limiter.Close, nil
+}
@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+)
+
+// APIKeyVerifier проверяет статические ключи API из заголовка.
+// Ключи хранятся только в виде SHA-256: поиск по хешу не раскрывает
+// содержимое ключей через время ответа.
+type APIKeyVerifier struct {
+	header string
+	keys   map[[sha256.Size]byte]string
+}
+
+// NewAPIKeyVerifier создает верификатор ключей из заголовка header; keys сопоставляет ключ пользователю.
+func NewAPIKeyVerifier(header string, keys map[string]string) *APIKeyVerifier {
+	v := &APIKeyVerifier{
+		header: header,
+		keys:   make(map[[sha256.Size]byte]string, len(keys)),
+	}
+	for key, subject := range keys {
+		v.keys[sha256.Sum256([]byte(key))] = subject
+	}
+
+	return v
+}
+
+// Verify ищет пользователя по ключу из заголовка.
+func (v *APIKeyVerifier) Verify(req *http.Request) (Principal, error) {
+	key := req.Header.Get(v.header)
+	if key == "" {
+		return Principal{}, ErrNoCredentials
+	}
+
+	subject, ok := v.keys[sha256.Sum256([]byte(key))]
+	if !ok {
+		return Principal{}, fmt.Errorf("%w: неизвестный ключ API", ErrInvalidCredentials)
+	}
+
+	return Principal{Subject: subject, Method: MethodAPIKey}, nil
+}
+
+// Challenge сообщает клиенту, в каком заголовке ожидается ключ.
+func (v *APIKeyVerifier) Challenge() string {
+	return fmt.Sprintf(`ApiKey realm=%q, header=%q`, realm, v.header)
+}
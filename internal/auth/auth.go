@@ -0,0 +1,57 @@
+// Package auth проверяет учетные данные HTTP-запросов и хранит
+// аутентифицированного пользователя в контексте запроса.
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// realm область защиты в заголовке WWW-Authenticate.
+const realm = "ecom"
+
+// Способы аутентификации.
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+)
+
+var (
+	// ErrNoCredentials в запросе нет учетных данных, которые проверяет верификатор.
+	ErrNoCredentials = errors.New("учетные данные не переданы")
+	// ErrInvalidCredentials учетные данные переданы, но не прошли проверку.
+	ErrInvalidCredentials = errors.New("некорректные учетные данные")
+)
+
+type (
+	// Principal аутентифицированный пользователь.
+	Principal struct {
+		// Subject идентификатор пользователя: владелец ключа API или claim sub токена.
+		Subject string
+		// Method способ, которым пользователь аутентифицирован.
+		Method string
+	}
+
+	// Verifier проверяет учетные данные одного типа.
+	Verifier interface {
+		// Verify возвращает пользователя по учетным данным запроса. ErrNoCredentials
+		// означает, что данных этого типа в запросе нет и стоит попробовать другой верификатор.
+		Verify(req *http.Request) (Principal, error)
+		// Challenge возвращает значение WWW-Authenticate для ответа 401.
+		Challenge() string
+	}
+
+	contextKey struct{}
+)
+
+// NewContext возвращает копию ctx с аутентифицированным пользователем.
+func NewContext(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// FromContext возвращает пользователя из ctx; ok == false, если запрос не аутентифицирован.
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(contextKey{}).(Principal)
+	return p, ok
+}
@@ -0,0 +1,208 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testSecret = []byte("0123456789abcdef0123456789abcdef")
+
+// makeToken подписывает claims ключом secret; header задается явно, чтобы проверять чужие алгоритмы.
+func makeToken(t *testing.T, header, claims map[string]any, secret []byte) string {
+	t.Helper()
+
+	encode := func(v any) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("json.Marshal() ошибка = %v", err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+
+	input := encode(header) + "." + encode(claims)
+	return input + "." + base64.RawURLEncoding.EncodeToString(sign(secret, input))
+}
+
+func bearerRequest(token string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/todos", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+func TestJWTVerifier_Verify(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	hs256 := map[string]any{"alg": "HS256", "typ": "JWT"}
+	valid := func() map[string]any {
+		return map[string]any{
+			"sub": "alice",
+			"iss": "ecom-auth",
+			"aud": []string{"ecom", "other"},
+			"exp": now.Add(time.Hour).Unix(),
+		}
+	}
+	with := func(key string, value any) map[string]any {
+		claims := valid()
+		if value == nil {
+			delete(claims, key)
+		} else {
+			claims[key] = value
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name    string
+		req     *http.Request
+		want    Principal
+		wantErr error
+	}{
+		{
+			name: "валидный токен",
+			req:  bearerRequest(makeToken(t, hs256, valid(), testSecret)),
+			want: Principal{Subject: "alice", Method: MethodJWT},
+		},
+		{
+			name: "aud строкой и схема в нижнем регистре",
+			req: func() *http.Request {
+				req := bearerRequest("")
+				req.Header.Set("Authorization", "bearer "+makeToken(t, hs256, with("aud", "ecom"), testSecret))
+				return req
+			}(),
+			want: Principal{Subject: "alice", Method: MethodJWT},
+		},
+		{
+			name:    "нет заголовка",
+			req:     httptest.NewRequest(http.MethodGet, "/todos", nil),
+			wantErr: ErrNoCredentials,
+		},
+		{
+			name: "другая схема",
+			req: func() *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/todos", nil)
+				req.Header.Set("Authorization", "Basic YWxpY2U6c2VjcmV0")
+				return req
+			}(),
+			wantErr: ErrNoCredentials,
+		},
+		{
+			name:    "чужой ключ подписи",
+			req:     bearerRequest(makeToken(t, hs256, valid(), []byte("другой секрет другой секрет 1234"))),
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name:    "алгоритм none",
+			req:     bearerRequest(makeToken(t, map[string]any{"alg": "none"}, valid(), testSecret)),
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name:    "истекший токен",
+			req:     bearerRequest(makeToken(t, hs256, with("exp", now.Add(-time.Minute).Unix()), testSecret)),
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name: "истекший в пределах leeway",
+			req:  bearerRequest(makeToken(t, hs256, with("exp", now.Add(-10*time.Second).Unix()), testSecret)),
+			want: Principal{Subject: "alice", Method: MethodJWT},
+		},
+		{
+			name:    "без exp",
+			req:     bearerRequest(makeToken(t, hs256, with("exp", nil), testSecret)),
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name:    "еще не действует",
+			req:     bearerRequest(makeToken(t, hs256, with("nbf", now.Add(time.Minute).Unix()), testSecret)),
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name:    "без sub",
+			req:     bearerRequest(makeToken(t, hs256, with("sub", nil), testSecret)),
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name:    "чужой издатель",
+			req:     bearerRequest(makeToken(t, hs256, with("iss", "evil"), testSecret)),
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name:    "чужой получатель",
+			req:     bearerRequest(makeToken(t, hs256, with("aud", "other"), testSecret)),
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name:    "мусор вместо токена",
+			req:     bearerRequest("not.a.token"),
+			wantErr: ErrInvalidCredentials,
+		},
+	}
+
+	v := NewJWTVerifier(JWTOptions{Secret: testSecret, Issuer: "ecom-auth", Audience: "ecom", Leeway: 30 * time.Second})
+	v.now = func() time.Time { return now }
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := v.Verify(tt.req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify() ошибка = %v, ожидалась %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Verify() = %+v, ожидалось %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAPIKeyVerifier_Verify(t *testing.T) {
+	v := NewAPIKeyVerifier("X-API-Key", map[string]string{"secret-1": "ci", "secret-2": "admin"})
+
+	tests := []struct {
+		name    string
+		key     string
+		want    Principal
+		wantErr error
+	}{
+		{name: "известный ключ", key: "secret-2", want: Principal{Subject: "admin", Method: MethodAPIKey}},
+		{name: "нет ключа", key: "", wantErr: ErrNoCredentials},
+		{name: "неизвестный ключ", key: "secret-3", wantErr: ErrInvalidCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/todos", nil)
+			if tt.key != "" {
+				req.Header.Set("X-API-Key", tt.key)
+			}
+
+			got, err := v.Verify(req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify() ошибка = %v, ожидалась %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Verify() = %+v, ожидалось %+v", got, tt.want)
+			}
+		})
+	}
+
+	if challenge := v.Challenge(); !strings.Contains(challenge, `header="X-API-Key"`) {
+		t.Errorf("Challenge() = %q, ожидалось имя заголовка", challenge)
+	}
+}
+
+func TestContext(t *testing.T) {
+	if _, ok := FromContext(context.Background()); ok {
+		t.Error("в пустом контексте не должно быть пользователя")
+	}
+
+	want := Principal{Subject: "alice", Method: MethodJWT}
+	got, ok := FromContext(NewContext(context.Background(), want))
+	if !ok || got != want {
+		t.Errorf("FromContext() = %+v, %v, ожидалось %+v", got, ok, want)
+	}
+}
@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	authorizationHeader = "Authorization"
+	bearerPrefix        = "Bearer "
+
+	jwtAlgHS256 = "HS256"
+)
+
+type (
+	// JWTOptions параметры проверки токенов.
+	JWTOptions struct {
+		// Secret общий ключ HMAC-SHA256.
+		Secret []byte
+		// Issuer ожидаемое значение claim iss. Пустое — не проверяется.
+		Issuer string
+		// Audience значение, которое должно присутствовать в claim aud. Пустое — не проверяется.
+		Audience string
+		// Leeway допустимое расхождение часов при проверке exp и nbf.
+		Leeway time.Duration
+	}
+
+	// JWTVerifier проверяет bearer-токены JWT, подписанные HS256.
+	JWTVerifier struct {
+		opts JWTOptions
+		now  func() time.Time
+	}
+
+	jwtHeader struct {
+		Alg string `json:"alg"`
+		Typ string `json:"typ,omitempty"`
+	}
+
+	// jwtClaims поддерживаемые зарегистрированные claims (RFC 7519).
+	jwtClaims struct {
+		Subject   string   `json:"sub"`
+		Issuer    string   `json:"iss,omitempty"`
+		Audience  audience `json:"aud,omitempty"`
+		ExpiresAt *float64 `json:"exp,omitempty"`
+		NotBefore *float64 `json:"nbf,omitempty"`
+	}
+
+	// audience claim aud: строка или массив строк.
+	audience []string
+)
+
+// NewJWTVerifier создает верификатор токенов.
+func NewJWTVerifier(opts JWTOptions) *JWTVerifier {
+	return &JWTVerifier{opts: opts, now: time.Now}
+}
+
+// Verify проверяет токен из заголовка Authorization: Bearer.
+func (v *JWTVerifier) Verify(req *http.Request) (Principal, error) {
+	header := req.Header.Get(authorizationHeader)
+	if len(header) < len(bearerPrefix) || !strings.EqualFold(header[:len(bearerPrefix)], bearerPrefix) {
+		return Principal{}, ErrNoCredentials
+	}
+
+	claims, err := v.parse(strings.TrimSpace(header[len(bearerPrefix):]))
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+	}
+
+	return Principal{Subject: claims.Subject, Method: MethodJWT}, nil
+}
+
+// Challenge возвращает вызов схемы Bearer (RFC 6750).
+func (v *JWTVerifier) Challenge() string {
+	return fmt.Sprintf(`Bearer realm=%q`, realm)
+}
+
+// parse проверяет подпись и claims токена. Алгоритм фиксирован: токены с другим alg,
+// включая "none", отклоняются до проверки подписи.
+func (v *JWTVerifier) parse(token string) (jwtClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return jwtClaims{}, fmt.Errorf("токен должен состоять из трех частей")
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return jwtClaims{}, fmt.Errorf("некорректный заголовок токена")
+	}
+	if header.Alg != jwtAlgHS256 {
+		return jwtClaims{}, fmt.Errorf("неподдерживаемый алгоритм %q", header.Alg)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return jwtClaims{}, fmt.Errorf("некорректная подпись токена")
+	}
+	if !hmac.Equal(signature, sign(v.opts.Secret, parts[0]+"."+parts[1])) {
+		return jwtClaims{}, fmt.Errorf("неверная подпись токена")
+	}
+
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return jwtClaims{}, fmt.Errorf("некорректные claims токена")
+	}
+
+	return claims, v.validate(claims)
+}
+
+func (v *JWTVerifier) validate(c jwtClaims) error {
+	now := v.now()
+
+	if c.Subject == "" {
+		return fmt.Errorf("в токене нет claim sub")
+	}
+	// Бессрочные токены не принимаются: утекший токен должен когда-нибудь перестать работать.
+	if c.ExpiresAt == nil {
+		return fmt.Errorf("в токене нет claim exp")
+	}
+	if !now.Before(unixTime(*c.ExpiresAt).Add(v.opts.Leeway)) {
+		return fmt.Errorf("срок действия токена истек")
+	}
+	if c.NotBefore != nil && now.Add(v.opts.Leeway).Before(unixTime(*c.NotBefore)) {
+		return fmt.Errorf("токен еще не действует")
+	}
+	if v.opts.Issuer != "" && c.Issuer != v.opts.Issuer {
+		return fmt.Errorf("неожиданный издатель токена")
+	}
+	if v.opts.Audience != "" && !slices.Contains(c.Audience, v.opts.Audience) {
+		return fmt.Errorf("токен выпущен для другого получателя")
+	}
+
+	return nil
+}
+
+// UnmarshalJSON принимает aud как строку или массив строк.
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+	*a = multiple
+	return nil
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+func sign(secret []byte, signingInput string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signingInput))
+	return mac.Sum(nil)
+}
+
+// unixTime переводит NumericDate (секунды, возможно дробные) во время.
+func unixTime(seconds float64) time.Time {
+	return time.Unix(0, int64(seconds*float64(time.Second)))
+}
@@ -6,14 +6,89 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"time"
 )
 
 const (
 	defaultHost = "localhost"
 	defaultPort = 8080
+	// defaultDrainPeriod время между снятием готовности и остановкой сервера.
+	defaultDrainPeriod = 5 * time.Second
 
-	envServerHost = "SERVER_HOST"
-	envServerPort = "SERVER_PORT"
+	defaultStorageType          = StorageMemory
+	defaultStorageDir           = "data"
+	defaultStorageSync          = SyncInterval
+	defaultStorageSyncInterval  = time.Second
+	defaultStorageSnapshotEvery = 1000
+
+	defaultTrashTTL           = 30 * 24 * time.Hour
+	defaultTrashPurgeInterval = time.Hour
+
+	defaultLogLevel  = "info"
+	defaultLogFormat = LogFormatText
+	defaultLogDir    = "logs"
+
+	defaultEventsBuffer  = 64
+	defaultEventsHistory = 1000
+
+	defaultWebhookWorkers     = 4
+	defaultWebhookQueueSize   = 1000
+	defaultWebhookMaxAttempts = 5
+	defaultWebhookBackoff     = time.Second
+	defaultWebhookMaxBackoff  = time.Minute
+	defaultWebhookTimeout     = 10 * time.Second
+	defaultWebhookHistory     = 50
+
+	defaultAPIKeyHeader     = "X-API-Key"
+	defaultRole             = RoleEditor
+	defaultRateLimitIdleTTL = 10 * time.Minute
+	// defaultAuthFailureRate и defaultAuthFailureBurst — до 10 неудачных попыток
+	// аутентификации подряд с одного адреса, затем одна в 10 секунд.
+	defaultAuthFailureRate  = 0.1
+	defaultAuthFailureBurst = 10
+
+	defaultIdempotencyTTL = 24 * time.Hour
+
+	envServerHost  = "SERVER_HOST"
+	envServerPort  = "SERVER_PORT"
+	envServerDrain = "SERVER_DRAIN_PERIOD"
+	envStorageType = "STORAGE_TYPE"
+	envStorageDir  = "STORAGE_DIR"
+	envStorageSync = "STORAGE_SYNC"
+	envLogLevel    = "LOG_LEVEL"
+	envLogFormat   = "LOG_FORMAT"
+	envLogDir      = "LOG_DIR"
+	envRateLimit   = "RATE_LIMIT_ENABLED"
+	envIdempotency = "IDEMPOTENCY_ENABLED"
+	envAuth        = "AUTH_ENABLED"
+	envJWTSecret   = "AUTH_JWT_SECRET"
+	envSubtasks    = "SUBTASKS_AUTO_COMPLETE"
+)
+
+// Форматы логов.
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// Роли пользователей.
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+)
+
+// Типы хранилища.
+const (
+	StorageMemory = "memory"
+	StorageFile   = "file"
+)
+
+// Политики сброса журнала файлового хранилища на диск.
+const (
+	SyncAlways   = "always"
+	SyncInterval = "interval"
+	SyncNever    = "never"
 )
 
 type (
@@ -21,11 +96,162 @@ type (
 	Config struct {
 		// ServerConfig содержит конфигурацию сервера.
 		Server ServerConfig `json:"server"`
+		// Storage содержит конфигурацию хранилища задач.
+		Storage StorageConfig `json:"storage"`
+		// Trash содержит конфигурацию корзины удаленных задач.
+		Trash TrashConfig `json:"trash"`
+		// Subtasks содержит конфигурацию подзадач.
+		Subtasks SubtasksConfig `json:"subtasks"`
+		// Log содержит конфигурацию логирования.
+		Log LogConfig `json:"log"`
+		// RateLimit содержит конфигурацию ограничения частоты запросов.
+		RateLimit RateLimitConfig `json:"rate_limit"`
+		// Idempotency содержит конфигурацию повторов запросов с Idempotency-Key.
+		Idempotency IdempotencyConfig `json:"idempotency"`
+		// Auth содержит конфигурацию аутентификации.
+		Auth AuthConfig `json:"auth"`
+		// Events содержит конфигурацию потока событий об изменениях задач.
+		Events EventsConfig `json:"events"`
+		// Webhooks содержит конфигурацию доставки webhook.
+		Webhooks WebhooksConfig `json:"webhooks"`
 	}
 	// ServerConfig содержит конфигурацию сервера.
 	ServerConfig struct {
 		Host string `json:"host"`
 		Port int    `json:"port"`
+		// DrainPeriod сколько ждать после снятия готовности (/readyz отвечает 503)
+		// до остановки сервера, чтобы балансировщик успел убрать его из ротации.
+		DrainPeriod Duration `json:"drain_period"`
+	}
+	// StorageConfig содержит конфигурацию хранилища задач.
+	StorageConfig struct {
+		// Type тип хранилища: memory или file.
+		Type string `json:"type"`
+		// Dir директория для журнала и снимков файлового хранилища.
+		Dir string `json:"dir"`
+		// Sync политика fsync журнала: always, interval или never.
+		Sync string `json:"sync"`
+		// SyncInterval период fsync для политики interval.
+		SyncInterval Duration `json:"sync_interval"`
+		// SnapshotEvery количество записей журнала, после которого он сжимается в снимок.
+		SnapshotEvery int `json:"snapshot_every"`
+	}
+	// TrashConfig содержит конфигурацию корзины удаленных задач.
+	TrashConfig struct {
+		// TTL сколько задача хранится в корзине до окончательного удаления. 0 — бессрочно.
+		TTL Duration `json:"ttl"`
+		// PurgeInterval период проверки корзины.
+		PurgeInterval Duration `json:"purge_interval"`
+	}
+	// SubtasksConfig содержит конфигурацию подзадач.
+	SubtasksConfig struct {
+		// AutoComplete завершает задачу, когда завершены все ее подзадачи.
+		AutoComplete bool `json:"auto_complete"`
+	}
+	// LogConfig содержит конфигурацию логирования.
+	LogConfig struct {
+		// Level минимальный уровень: debug, info, warn или error.
+		Level string `json:"level"`
+		// Format формат записей: text или json.
+		Format string `json:"format"`
+		// Dir директория файлов логов.
+		Dir string `json:"dir"`
+		// RotateDaily включает ежедневную ротацию файла логов.
+		RotateDaily bool `json:"rotate_daily"`
+		// MaxSizeMB размер файла в мегабайтах, после которого он ротируется. 0 — без ограничения.
+		MaxSizeMB int `json:"max_size_mb"`
+		// Compress сжимает ротированные файлы в gzip.
+		Compress bool `json:"compress"`
+		// MaxBackups количество хранимых ротированных файлов. 0 — без ограничения.
+		MaxBackups int `json:"max_backups"`
+		// MaxAge срок хранения ротированных файлов. 0 — без ограничения.
+		MaxAge Duration `json:"max_age"`
+	}
+	// EventsConfig содержит конфигурацию потока событий.
+	EventsConfig struct {
+		// Buffer размер очереди событий одного подписчика; 0 — 64. Переполнивший ее подписчик отключается.
+		Buffer int `json:"buffer"`
+		// History количество последних событий, доступных для возобновления по Last-Event-ID.
+		History int `json:"history"`
+	}
+	// WebhooksConfig содержит конфигурацию доставки webhook. Нулевые значения заменяются значениями по умолчанию.
+	WebhooksConfig struct {
+		// Workers количество одновременных доставок.
+		Workers int `json:"workers"`
+		// QueueSize размер очереди доставок; при переполнении новые доставки помечаются неудачными.
+		QueueSize int `json:"queue_size"`
+		// MaxAttempts максимальное количество попыток одной доставки.
+		MaxAttempts int `json:"max_attempts"`
+		// Backoff задержка перед второй попыткой; каждая следующая вдвое больше.
+		Backoff Duration `json:"backoff"`
+		// MaxBackoff верхняя граница задержки между попытками.
+		MaxBackoff Duration `json:"max_backoff"`
+		// Timeout время ожидания ответа получателя.
+		Timeout Duration `json:"timeout"`
+		// History количество последних доставок, хранимых для каждой подписки.
+		History int `json:"history"`
+		// AllowPrivateNetworks разрешает адреса подписок в локальных, частных и служебных сетях.
+		AllowPrivateNetworks bool `json:"allow_private_networks"`
+	}
+	// RateLimitConfig содержит конфигурацию ограничения частоты запросов.
+	RateLimitConfig struct {
+		// Enabled включает ограничение.
+		Enabled bool `json:"enabled"`
+		// Default лимит для маршрутов без собственного лимита.
+		Default RateLimit `json:"default"`
+		// Routes лимиты маршрутов по ключу "METHOD /path" или "/path", например "POST /todos".
+		Routes map[string]RateLimit `json:"routes"`
+		// TrustedProxies IP-адреса и подсети (CIDR) прокси, которым доверяется X-Forwarded-For.
+		TrustedProxies []string `json:"trusted_proxies"`
+		// IdleTTL через сколько простоя корзина клиента удаляется.
+		IdleTTL Duration `json:"idle_ttl"`
+		// AuthFailures лимит неудачных попыток аутентификации с одного IP-адреса.
+		AuthFailures RateLimit `json:"auth_failures"`
+	}
+	// IdempotencyConfig содержит конфигурацию повторов запросов с Idempotency-Key.
+	IdempotencyConfig struct {
+		// Enabled включает сохранение ответов на POST-запросы с Idempotency-Key.
+		Enabled bool `json:"enabled"`
+		// TTL сколько хранится ответ для повторов с тем же ключом.
+		TTL Duration `json:"ttl"`
+	}
+	// AuthConfig содержит конфигурацию аутентификации.
+	AuthConfig struct {
+		// Enabled включает обязательную аутентификацию всех запросов, кроме проверок состояния.
+		Enabled bool `json:"enabled"`
+		// APIKeyHeader заголовок с ключом API.
+		APIKeyHeader string `json:"api_key_header"`
+		// APIKeys статические ключи API.
+		APIKeys []APIKey `json:"api_keys"`
+		// JWT параметры bearer-токенов. Пустой Secret отключает проверку токенов.
+		JWT JWTConfig `json:"jwt"`
+		// Roles роли пользователей по subject: viewer, editor или admin.
+		Roles map[string]string `json:"roles"`
+		// DefaultRole роль пользователей, которых нет в Roles. Пустая — такие пользователи
+		// проходят аутентификацию, но не могут выполнять операции с задачами.
+		DefaultRole string `json:"default_role"`
+	}
+	// APIKey статический ключ API и пользователь, которому он выдан.
+	APIKey struct {
+		Key     string `json:"key"`
+		Subject string `json:"subject"`
+	}
+	// JWTConfig содержит параметры проверки JWT, подписанных HS256.
+	JWTConfig struct {
+		// Secret общий ключ HMAC, не короче 32 байт.
+		Secret string `json:"secret"`
+		// Issuer ожидаемый claim iss. Пустой — не проверяется.
+		Issuer string `json:"issuer"`
+		// Audience ожидаемое значение claim aud. Пустое — не проверяется.
+		Audience string `json:"audience"`
+		// Leeway допустимое расхождение часов при проверке exp и nbf.
+		Leeway Duration `json:"leeway"`
+	}
+	// RateLimit параметры token bucket: Rate запросов в секунду, до Burst запросов подряд.
+	// Нулевой Rate снимает ограничение.
+	RateLimit struct {
+		Rate  float64 `json:"rate"`
+		Burst int     `json:"burst"`
 	}
 )
 
@@ -33,8 +259,51 @@ type (
 func NewDefault() *Config {
 	return &Config{
 		Server: ServerConfig{
-			Host: defaultHost,
-			Port: defaultPort,
+			Host:        defaultHost,
+			Port:        defaultPort,
+			DrainPeriod: Duration(defaultDrainPeriod),
+		},
+		Storage: StorageConfig{
+			Type:          defaultStorageType,
+			Dir:           defaultStorageDir,
+			Sync:          defaultStorageSync,
+			SyncInterval:  Duration(defaultStorageSyncInterval),
+			SnapshotEvery: defaultStorageSnapshotEvery,
+		},
+		Trash: TrashConfig{
+			TTL:           Duration(defaultTrashTTL),
+			PurgeInterval: Duration(defaultTrashPurgeInterval),
+		},
+		Log: LogConfig{
+			Level:       defaultLogLevel,
+			Format:      defaultLogFormat,
+			Dir:         defaultLogDir,
+			RotateDaily: true,
+		},
+		Events: EventsConfig{
+			Buffer:  defaultEventsBuffer,
+			History: defaultEventsHistory,
+		},
+		Webhooks: WebhooksConfig{
+			Workers:     defaultWebhookWorkers,
+			QueueSize:   defaultWebhookQueueSize,
+			MaxAttempts: defaultWebhookMaxAttempts,
+			Backoff:     Duration(defaultWebhookBackoff),
+			MaxBackoff:  Duration(defaultWebhookMaxBackoff),
+			Timeout:     Duration(defaultWebhookTimeout),
+			History:     defaultWebhookHistory,
+		},
+		RateLimit: RateLimitConfig{
+			IdleTTL:      Duration(defaultRateLimitIdleTTL),
+			AuthFailures: RateLimit{Rate: defaultAuthFailureRate, Burst: defaultAuthFailureBurst},
+		},
+		Idempotency: IdempotencyConfig{
+			Enabled: true,
+			TTL:     Duration(defaultIdempotencyTTL),
+		},
+		Auth: AuthConfig{
+			APIKeyHeader: defaultAPIKeyHeader,
+			DefaultRole:  defaultRole,
 		},
 	}
 }
@@ -97,5 +366,73 @@ func (c *Config) overrideFromEnv() error {
 		c.Server.Port = port
 	}
 
+	if drainStr := os.Getenv(envServerDrain); drainStr != "" {
+		drain, err := time.ParseDuration(drainStr)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", envServerDrain, err)
+		}
+		c.Server.DrainPeriod = Duration(drain)
+	}
+
+	if storageType := os.Getenv(envStorageType); storageType != "" {
+		c.Storage.Type = storageType
+	}
+
+	if dir := os.Getenv(envStorageDir); dir != "" {
+		c.Storage.Dir = dir
+	}
+
+	if sync := os.Getenv(envStorageSync); sync != "" {
+		c.Storage.Sync = sync
+	}
+
+	if level := os.Getenv(envLogLevel); level != "" {
+		c.Log.Level = level
+	}
+
+	if format := os.Getenv(envLogFormat); format != "" {
+		c.Log.Format = format
+	}
+
+	if dir := os.Getenv(envLogDir); dir != "" {
+		c.Log.Dir = dir
+	}
+
+	if enabledStr := os.Getenv(envRateLimit); enabledStr != "" {
+		enabled, err := strconv.ParseBool(enabledStr)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", envRateLimit, err)
+		}
+		c.RateLimit.Enabled = enabled
+	}
+
+	if enabledStr := os.Getenv(envIdempotency); enabledStr != "" {
+		enabled, err := strconv.ParseBool(enabledStr)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", envIdempotency, err)
+		}
+		c.Idempotency.Enabled = enabled
+	}
+
+	if enabledStr := os.Getenv(envAuth); enabledStr != "" {
+		enabled, err := strconv.ParseBool(enabledStr)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", envAuth, err)
+		}
+		c.Auth.Enabled = enabled
+	}
+
+	if secret := os.Getenv(envJWTSecret); secret != "" {
+		c.Auth.JWT.Secret = secret
+	}
+
+	if enabledStr := os.Getenv(envSubtasks); enabledStr != "" {
+		enabled, err := strconv.ParseBool(enabledStr)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", envSubtasks, err)
+		}
+		c.Subtasks.AutoComplete = enabled
+	}
+
 	return nil
 }
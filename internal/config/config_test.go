@@ -1,8 +1,10 @@
 package config
 
 import (
+	"encoding/json"
 	"os"
 	"testing"
+	"time"
 )
 
 func TestNewDefault(t *testing.T) {
@@ -19,6 +21,37 @@ func TestNewDefault(t *testing.T) {
 	if cfg.Server.Port != defaultPort {
 		t.Errorf("ожидался port %d, получено %d", defaultPort, cfg.Server.Port)
 	}
+
+	if err := cfg.validate(); err != nil {
+		t.Errorf("дефолтная конфигурация невалидна: %v", err)
+	}
+}
+
+func TestDuration_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "секунды", input: `"5s"`, want: 5 * time.Second},
+		{name: "миллисекунды", input: `"250ms"`, want: 250 * time.Millisecond},
+		{name: "число вместо строки", input: `5`, wantErr: true},
+		{name: "невалидная строка", input: `"пять"`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var d Duration
+			err := json.Unmarshal([]byte(tt.input), &d)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UnmarshalJSON() ошибка = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && d.Std() != tt.want {
+				t.Errorf("ожидалось %v, получено %v", tt.want, d.Std())
+			}
+		})
+	}
 }
 
 func TestConfig_overrideFromEnv(t *testing.T) {
@@ -95,3 +128,32 @@ func TestConfig_overrideFromEnv(t *testing.T) {
 		})
 	}
 }
+
+func TestConfig_overrideFromEnv_DrainPeriod(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "по умолчанию", env: "", want: defaultDrainPeriod},
+		{name: "валидная длительность", env: "15s", want: 15 * time.Second},
+		{name: "без ожидания", env: "0s", want: 0},
+		{name: "невалидная длительность", env: "скоро", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(envServerDrain, tt.env)
+
+			cfg := NewDefault()
+			err := cfg.overrideFromEnv()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("overrideFromEnv() ошибка = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && cfg.Server.DrainPeriod.Std() != tt.want {
+				t.Errorf("ожидался drain_period %v, получено %v", tt.want, cfg.Server.DrainPeriod.Std())
+			}
+		})
+	}
+}
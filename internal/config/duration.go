@@ -0,0 +1,36 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Duration обертка над time.Duration, которая читается из JSON строкой вида "1s", "500ms".
+type Duration time.Duration
+
+// UnmarshalJSON разбирает длительность из строки формата time.ParseDuration.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var raw string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("duration must be a string: %w", err)
+	}
+
+	parsed, err := time.ParseDuration(raw)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q: %w", raw, err)
+	}
+
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalJSON сериализует длительность в строку.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Std возвращает значение как time.Duration.
+func (d Duration) Std() time.Duration {
+	return time.Duration(d)
+}
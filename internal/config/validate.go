@@ -1,6 +1,11 @@
 package config
 
-import "fmt"
+import (
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"strings"
+)
 
 // Validate проверяет корректность конфигурации.
 func (c *Config) validate() error {
@@ -14,6 +19,268 @@ func (c *Config) validate() error {
 	if c.Server.Port <= 0 {
 		return fmt.Errorf("server.port must be > 0")
 	}
+	if c.Server.DrainPeriod < 0 {
+		return fmt.Errorf("server.drain_period must be >= 0")
+	}
+
+	if err := c.Storage.validate(); err != nil {
+		return err
+	}
+
+	if err := c.Trash.validate(); err != nil {
+		return err
+	}
+
+	if err := c.Log.validate(); err != nil {
+		return err
+	}
+
+	if err := c.Events.validate(); err != nil {
+		return err
+	}
+
+	if err := c.Webhooks.validate(); err != nil {
+		return err
+	}
+
+	if err := c.RateLimit.validate(); err != nil {
+		return err
+	}
+
+	if err := c.Idempotency.validate(); err != nil {
+		return err
+	}
+
+	return c.Auth.validate()
+}
+
+// minJWTSecretLength минимальная длина ключа HS256: не короче выхода SHA-256.
+const minJWTSecretLength = 32
+
+func (a AuthConfig) validate() error {
+	if !a.Enabled {
+		return nil
+	}
+
+	if len(a.APIKeys) == 0 && a.JWT.Secret == "" {
+		return fmt.Errorf("auth: at least one of auth.api_keys or auth.jwt.secret is required")
+	}
+	if len(a.APIKeys) > 0 && a.APIKeyHeader == "" {
+		return fmt.Errorf("auth.api_key_header is required")
+	}
+
+	seen := make(map[string]struct{}, len(a.APIKeys))
+	for i, key := range a.APIKeys {
+		if key.Key == "" || key.Subject == "" {
+			return fmt.Errorf("auth.api_keys[%d]: key and subject are required", i)
+		}
+		if _, dup := seen[key.Key]; dup {
+			return fmt.Errorf("auth.api_keys[%d]: duplicate key", i)
+		}
+		seen[key.Key] = struct{}{}
+	}
+
+	if a.JWT.Secret != "" && len(a.JWT.Secret) < minJWTSecretLength {
+		return fmt.Errorf("auth.jwt.secret must be at least %d bytes", minJWTSecretLength)
+	}
+	if a.JWT.Leeway < 0 {
+		return fmt.Errorf("auth.jwt.leeway must be >= 0")
+	}
+
+	if a.DefaultRole != "" && !validRole(a.DefaultRole) {
+		return fmt.Errorf("auth.default_role must be one of %q, %q, %q", RoleViewer, RoleEditor, RoleAdmin)
+	}
+	for subject, role := range a.Roles {
+		if !validRole(role) {
+			return fmt.Errorf("auth.roles[%q] must be one of %q, %q, %q", subject, RoleViewer, RoleEditor, RoleAdmin)
+		}
+	}
 
 	return nil
 }
+
+func validRole(role string) bool {
+	switch role {
+	case RoleViewer, RoleEditor, RoleAdmin:
+		return true
+	default:
+		return false
+	}
+}
+
+func (l LogConfig) validate() error {
+	if l.Level != "" {
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(l.Level)); err != nil {
+			return fmt.Errorf("log.level must be one of debug, info, warn, error")
+		}
+	}
+
+	switch l.Format {
+	case "", LogFormatText, LogFormatJSON:
+	default:
+		return fmt.Errorf("log.format must be %q or %q", LogFormatText, LogFormatJSON)
+	}
+
+	if l.MaxSizeMB < 0 {
+		return fmt.Errorf("log.max_size_mb must be >= 0")
+	}
+	if l.MaxBackups < 0 {
+		return fmt.Errorf("log.max_backups must be >= 0")
+	}
+	if l.MaxAge < 0 {
+		return fmt.Errorf("log.max_age must be >= 0")
+	}
+
+	return nil
+}
+
+func (s StorageConfig) validate() error {
+	switch s.Type {
+	case "", StorageMemory:
+		return nil
+	case StorageFile:
+	default:
+		return fmt.Errorf("storage.type must be %q or %q", StorageMemory, StorageFile)
+	}
+
+	if s.Dir == "" {
+		return fmt.Errorf("storage.dir is required for file storage")
+	}
+
+	switch s.Sync {
+	case SyncAlways, SyncNever:
+	case SyncInterval:
+		if s.SyncInterval <= 0 {
+			return fmt.Errorf("storage.sync_interval must be > 0")
+		}
+	default:
+		return fmt.Errorf("storage.sync must be one of %q, %q, %q", SyncAlways, SyncInterval, SyncNever)
+	}
+
+	if s.SnapshotEvery < 0 {
+		return fmt.Errorf("storage.snapshot_every must be >= 0")
+	}
+
+	return nil
+}
+
+func (t TrashConfig) validate() error {
+	if t.TTL < 0 {
+		return fmt.Errorf("trash.ttl must be >= 0")
+	}
+	if t.TTL > 0 && t.PurgeInterval <= 0 {
+		return fmt.Errorf("trash.purge_interval must be > 0")
+	}
+
+	return nil
+}
+
+func (e EventsConfig) validate() error {
+	if e.Buffer < 0 {
+		return fmt.Errorf("events.buffer must be >= 0")
+	}
+	if e.History < 0 {
+		return fmt.Errorf("events.history must be >= 0")
+	}
+
+	return nil
+}
+
+func (w WebhooksConfig) validate() error {
+	switch {
+	case w.Workers < 0:
+		return fmt.Errorf("webhooks.workers must be >= 0")
+	case w.QueueSize < 0:
+		return fmt.Errorf("webhooks.queue_size must be >= 0")
+	case w.MaxAttempts < 0:
+		return fmt.Errorf("webhooks.max_attempts must be >= 0")
+	case w.Backoff < 0:
+		return fmt.Errorf("webhooks.backoff must be >= 0")
+	case w.MaxBackoff < 0:
+		return fmt.Errorf("webhooks.max_backoff must be >= 0")
+	case w.Backoff > w.MaxBackoff && w.MaxBackoff > 0:
+		return fmt.Errorf("webhooks.backoff must not exceed webhooks.max_backoff")
+	case w.Timeout < 0:
+		return fmt.Errorf("webhooks.timeout must be >= 0")
+	case w.History < 0:
+		return fmt.Errorf("webhooks.history must be >= 0")
+	}
+
+	return nil
+}
+
+func (i IdempotencyConfig) validate() error {
+	if i.Enabled && i.TTL <= 0 {
+		return fmt.Errorf("idempotency.ttl must be > 0")
+	}
+
+	return nil
+}
+
+func (r RateLimitConfig) validate() error {
+	if !r.Enabled {
+		return nil
+	}
+
+	if r.IdleTTL <= 0 {
+		return fmt.Errorf("rate_limit.idle_ttl must be > 0")
+	}
+	if _, err := r.TrustedPrefixes(); err != nil {
+		return err
+	}
+	if err := r.Default.validate("rate_limit.default"); err != nil {
+		return err
+	}
+	if err := r.AuthFailures.validate("rate_limit.auth_failures"); err != nil {
+		return err
+	}
+
+	for key, limit := range r.Routes {
+		path := key
+		if method, rest, ok := strings.Cut(key, " "); ok {
+			if method == "" || strings.ToUpper(method) != method {
+				return fmt.Errorf("rate_limit.routes: invalid method in %q", key)
+			}
+			path = rest
+		}
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("rate_limit.routes: route %q must start with /", key)
+		}
+		if err := limit.validate(fmt.Sprintf("rate_limit.routes[%q]", key)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (l RateLimit) validate(name string) error {
+	if l.Rate < 0 {
+		return fmt.Errorf("%s.rate must be >= 0", name)
+	}
+	if l.Rate > 0 && l.Burst < 1 {
+		return fmt.Errorf("%s.burst must be >= 1", name)
+	}
+
+	return nil
+}
+
+// TrustedPrefixes разбирает адреса доверенных прокси; одиночный IP считается подсетью из одного адреса.
+func (r RateLimitConfig) TrustedPrefixes() ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(r.TrustedProxies))
+	for _, raw := range r.TrustedProxies {
+		if prefix, err := netip.ParsePrefix(raw); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(raw)
+		if err != nil {
+			return nil, fmt.Errorf("rate_limit.trusted_proxies: invalid address %q", raw)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+
+	return prefixes, nil
+}
@@ -1,7 +1,9 @@
 package config
 
 import (
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestConfig_validate(t *testing.T) {
@@ -45,6 +47,275 @@ func TestConfig_validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "отрицательный период остановки",
+			config: &Config{
+				Server: ServerConfig{Host: "localhost", Port: 8080, DrainPeriod: Duration(-time.Second)},
+			},
+			wantErr: true,
+		},
+		{
+			name: "неизвестный тип хранилища",
+			config: &Config{
+				Server:  ServerConfig{Host: "localhost", Port: 8080},
+				Storage: StorageConfig{Type: "redis"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "файловое хранилище без директории",
+			config: &Config{
+				Server:  ServerConfig{Host: "localhost", Port: 8080},
+				Storage: StorageConfig{Type: StorageFile, Sync: SyncAlways},
+			},
+			wantErr: true,
+		},
+		{
+			name: "файловое хранилище с нулевым интервалом fsync",
+			config: &Config{
+				Server:  ServerConfig{Host: "localhost", Port: 8080},
+				Storage: StorageConfig{Type: StorageFile, Dir: "data", Sync: SyncInterval},
+			},
+			wantErr: true,
+		},
+		{
+			name: "валидное файловое хранилище",
+			config: &Config{
+				Server:  ServerConfig{Host: "localhost", Port: 8080},
+				Storage: StorageConfig{Type: StorageFile, Dir: "data", Sync: SyncAlways, SnapshotEvery: 10},
+			},
+			wantErr: false,
+		},
+		{
+			name: "неизвестный уровень логов",
+			config: &Config{
+				Server: ServerConfig{Host: "localhost", Port: 8080},
+				Log:    LogConfig{Level: "verbose"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "неизвестный формат логов",
+			config: &Config{
+				Server: ServerConfig{Host: "localhost", Port: 8080},
+				Log:    LogConfig{Format: "xml"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "json логи",
+			config: &Config{
+				Server: ServerConfig{Host: "localhost", Port: 8080},
+				Log:    LogConfig{Level: "debug", Format: LogFormatJSON},
+			},
+			wantErr: false,
+		},
+		{
+			name: "отрицательный размер файла логов",
+			config: &Config{
+				Server: ServerConfig{Host: "localhost", Port: 8080},
+				Log:    LogConfig{MaxSizeMB: -1},
+			},
+			wantErr: true,
+		},
+		{
+			name: "отрицательный срок хранения логов",
+			config: &Config{
+				Server: ServerConfig{Host: "localhost", Port: 8080},
+				Log:    LogConfig{MaxAge: Duration(-time.Hour)},
+			},
+			wantErr: true,
+		},
+		{
+			name: "ротация логов",
+			config: &Config{
+				Server: ServerConfig{Host: "localhost", Port: 8080},
+				Log: LogConfig{
+					Dir:         "logs",
+					RotateDaily: true,
+					MaxSizeMB:   100,
+					Compress:    true,
+					MaxBackups:  7,
+					MaxAge:      Duration(7 * 24 * time.Hour),
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "выключенное ограничение не проверяется",
+			config: &Config{
+				Server:    ServerConfig{Host: "localhost", Port: 8080},
+				RateLimit: RateLimitConfig{Default: RateLimit{Rate: -1}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "лимит без burst",
+			config: &Config{
+				Server: ServerConfig{Host: "localhost", Port: 8080},
+				RateLimit: RateLimitConfig{
+					Enabled: true,
+					IdleTTL: Duration(time.Minute),
+					Default: RateLimit{Rate: 10},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "маршрут без слэша",
+			config: &Config{
+				Server: ServerConfig{Host: "localhost", Port: 8080},
+				RateLimit: RateLimitConfig{
+					Enabled: true,
+					IdleTTL: Duration(time.Minute),
+					Routes:  map[string]RateLimit{"POST todos": {Rate: 1, Burst: 1}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "некорректный адрес прокси",
+			config: &Config{
+				Server: ServerConfig{Host: "localhost", Port: 8080},
+				RateLimit: RateLimitConfig{
+					Enabled:        true,
+					IdleTTL:        Duration(time.Minute),
+					TrustedProxies: []string{"proxy.local"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "валидное ограничение частоты",
+			config: &Config{
+				Server: ServerConfig{Host: "localhost", Port: 8080},
+				RateLimit: RateLimitConfig{
+					Enabled:        true,
+					IdleTTL:        Duration(time.Minute),
+					Default:        RateLimit{Rate: 50, Burst: 100},
+					Routes:         map[string]RateLimit{"POST /todos": {Rate: 1, Burst: 5}, "/todos/{id}": {}},
+					TrustedProxies: []string{"10.0.0.0/8", "127.0.0.1"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "аутентификация без способов",
+			config: &Config{
+				Server: ServerConfig{Host: "localhost", Port: 8080},
+				Auth:   AuthConfig{Enabled: true, APIKeyHeader: "X-API-Key"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "повторяющийся ключ API",
+			config: &Config{
+				Server: ServerConfig{Host: "localhost", Port: 8080},
+				Auth: AuthConfig{
+					Enabled:      true,
+					APIKeyHeader: "X-API-Key",
+					APIKeys:      []APIKey{{Key: "k", Subject: "a"}, {Key: "k", Subject: "b"}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "короткий секрет JWT",
+			config: &Config{
+				Server: ServerConfig{Host: "localhost", Port: 8080},
+				Auth:   AuthConfig{Enabled: true, JWT: JWTConfig{Secret: "short"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "неизвестная роль",
+			config: &Config{
+				Server: ServerConfig{Host: "localhost", Port: 8080},
+				Auth: AuthConfig{
+					Enabled:      true,
+					APIKeyHeader: "X-API-Key",
+					APIKeys:      []APIKey{{Key: "k", Subject: "ci"}},
+					Roles:        map[string]string{"ci": "root"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "неизвестная роль по умолчанию",
+			config: &Config{
+				Server: ServerConfig{Host: "localhost", Port: 8080},
+				Auth: AuthConfig{
+					Enabled:      true,
+					APIKeyHeader: "X-API-Key",
+					APIKeys:      []APIKey{{Key: "k", Subject: "ci"}},
+					DefaultRole:  "guest",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "валидная аутентификация",
+			config: &Config{
+				Server: ServerConfig{Host: "localhost", Port: 8080},
+				Auth: AuthConfig{
+					Enabled:      true,
+					APIKeyHeader: "X-API-Key",
+					APIKeys:      []APIKey{{Key: "k", Subject: "ci"}},
+					JWT:          JWTConfig{Secret: strings.Repeat("s", 32), Leeway: Duration(time.Minute)},
+					Roles:        map[string]string{"ci": RoleAdmin},
+					DefaultRole:  RoleViewer,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "отрицательная история событий",
+			config: &Config{
+				Server: ServerConfig{Host: "localhost", Port: 8080},
+				Events: EventsConfig{Buffer: 16, History: -1},
+			},
+			wantErr: true,
+		},
+		{
+			name: "корзина без периода очистки",
+			config: &Config{
+				Server: ServerConfig{Host: "localhost", Port: 8080},
+				Trash:  TrashConfig{TTL: Duration(time.Hour)},
+			},
+			wantErr: true,
+		},
+		{
+			name: "бессрочная корзина",
+			config: &Config{
+				Server: ServerConfig{Host: "localhost", Port: 8080},
+				Trash:  TrashConfig{TTL: 0},
+			},
+			wantErr: false,
+		},
+		{
+			name: "отрицательное количество попыток webhook",
+			config: &Config{
+				Server:   ServerConfig{Host: "localhost", Port: 8080},
+				Webhooks: WebhooksConfig{MaxAttempts: -1},
+			},
+			wantErr: true,
+		},
+		{
+			name: "задержка webhook больше максимальной",
+			config: &Config{
+				Server:   ServerConfig{Host: "localhost", Port: 8080},
+				Webhooks: WebhooksConfig{Backoff: Duration(time.Minute), MaxBackoff: Duration(time.Second)},
+			},
+			wantErr: true,
+		},
+		{
+			name: "идемпотентность без срока хранения",
+			config: &Config{
+				Server:      ServerConfig{Host: "localhost", Port: 8080},
+				Idempotency: IdempotencyConfig{Enabled: true},
+			},
+			wantErr: true,
+		},
 		{
 			name: "валидный конфиг",
 			config: &Config{
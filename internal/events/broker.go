@@ -0,0 +1,209 @@
+// Package events рассылает подписчикам события об изменениях задач внутри процесса.
+package events
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/RoGogDBD/ecom/internal/metrics"
+	"github.com/RoGogDBD/ecom/internal/models"
+)
+
+// Типы событий.
+const (
+	TypeCreated = "created"
+	TypeUpdated = "updated"
+	TypeDeleted = "deleted"
+	// TypeReminder наступило время напоминания о задаче.
+	TypeReminder = "reminder"
+)
+
+// defaultBuffer размер очереди подписчика, если он не задан.
+const defaultBuffer = 64
+
+// ErrClosed брокер остановлен и не принимает подписчиков.
+var ErrClosed = errors.New("поток событий закрыт")
+
+type (
+	// Event изменение задачи. ID растут на единицу с каждым событием брокера
+	// и используются клиентами для возобновления потока (Last-Event-ID).
+	Event struct {
+		ID   uint64
+		Type string
+		Todo models.Todo
+	}
+
+	// Options параметры брокера.
+	Options struct {
+		// Buffer размер очереди подписчика; 0 — 64 события. Подписчик, который
+		// не успевает забирать события и переполнил очередь, отключается.
+		Buffer int
+		// History количество последних событий, доступных для возобновления потока.
+		History int
+	}
+
+	// Broker рассылает события подписчикам того же владельца задач.
+	// Публикация не блокируется медленными подписчиками.
+	Broker struct {
+		buffer int
+
+		mu     sync.Mutex
+		lastID uint64
+		// history кольцевой буфер последних событий: событие с ID n хранится в history[n%len(history)].
+		history []Event
+		subs    map[*Subscription]struct{}
+		closed  bool
+
+		metrics *brokerMetrics
+	}
+
+	// Subscription подписка на события одного владельца.
+	Subscription struct {
+		// Backlog события после lastID из истории, которые нужно отдать до событий из Events.
+		Backlog []Event
+		// Gap часть событий после lastID уже вытеснена из истории или lastID неизвестен брокеру
+		// (например, после перезапуска): клиенту следует заново загрузить состояние.
+		Gap bool
+
+		owner  string
+		ch     chan Event
+		broker *Broker
+	}
+
+	brokerMetrics struct {
+		dropped *metrics.Counter
+	}
+)
+
+// NewBroker создает брокер.
+func NewBroker(opts Options) *Broker {
+	if opts.Buffer <= 0 {
+		opts.Buffer = defaultBuffer
+	}
+
+	return &Broker{
+		buffer:  opts.Buffer,
+		history: make([]Event, opts.History),
+		subs:    make(map[*Subscription]struct{}),
+	}
+}
+
+// Instrument регистрирует метрики брокера в reg: количество подписчиков
+// и количество подписчиков, отключенных из-за переполнения очереди.
+func (b *Broker) Instrument(reg *metrics.Registry) {
+	reg.NewGaugeFunc("todo_events_subscribers", "Количество подписчиков на события задач.", func() float64 {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return float64(len(b.subs))
+	})
+
+	b.metrics = &brokerMetrics{
+		dropped: reg.NewCounter("todo_events_dropped_subscribers_total",
+			"Количество подписчиков, отключенных из-за переполнения очереди."),
+	}
+}
+
+// Publish рассылает событие typ о задаче todo подписчикам ее владельца.
+// После Close события не публикуются.
+func (b *Broker) Publish(typ string, todo models.Todo) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+
+	b.lastID++
+	event := Event{ID: b.lastID, Type: typ, Todo: todo}
+	if len(b.history) > 0 {
+		b.history[event.ID%uint64(len(b.history))] = event
+	}
+
+	for sub := range b.subs {
+		if sub.owner != todo.Owner {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			// Очередь переполнена: отключаем подписчика, чтобы не блокировать публикацию.
+			// Клиент переподключится с Last-Event-ID и получит пропущенное из истории.
+			b.remove(sub)
+			b.metrics.drop()
+		}
+	}
+}
+
+// Subscribe подписывает на события владельца owner. Ненулевой lastID — ID последнего
+// полученного клиентом события: более поздние события из истории попадут в Backlog.
+func (b *Broker) Subscribe(owner string, lastID uint64) (*Subscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, ErrClosed
+	}
+
+	sub := &Subscription{
+		owner:  owner,
+		ch:     make(chan Event, b.buffer),
+		broker: b,
+	}
+
+	if lastID > 0 {
+		oldest := b.lastID + 1 - min(b.lastID, uint64(len(b.history)))
+		sub.Gap = lastID > b.lastID || lastID+1 < oldest
+
+		for id := max(lastID+1, oldest); id <= b.lastID; id++ {
+			if event := b.history[id%uint64(len(b.history))]; event.Todo.Owner == owner {
+				sub.Backlog = append(sub.Backlog, event)
+			}
+		}
+	}
+
+	b.subs[sub] = struct{}{}
+	return sub, nil
+}
+
+// Close отключает всех подписчиков и перестает принимать новых.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for sub := range b.subs {
+		b.remove(sub)
+	}
+}
+
+// remove отключает подписчика. Вызывается под b.mu.
+func (b *Broker) remove(sub *Subscription) {
+	if _, ok := b.subs[sub]; !ok {
+		return
+	}
+
+	delete(b.subs, sub)
+	close(sub.ch)
+}
+
+// Events возвращает канал событий. Канал закрывается, когда подписка отменена,
+// подписчик отключен из-за переполнения очереди или брокер остановлен.
+func (s *Subscription) Events() <-chan Event {
+	return s.ch
+}
+
+// Close отменяет подписку. Повторный вызов безопасен.
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+
+	s.broker.remove(s)
+}
+
+func (m *brokerMetrics) drop() {
+	if m == nil {
+		return
+	}
+
+	m.dropped.Inc()
+}
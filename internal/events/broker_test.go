@@ -0,0 +1,126 @@
+package events
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/RoGogDBD/ecom/internal/models"
+)
+
+// ids возвращает ID событий.
+func ids(events []Event) []uint64 {
+	result := make([]uint64, 0, len(events))
+	for _, event := range events {
+		result = append(result, event.ID)
+	}
+	return result
+}
+
+func TestBrokerPublish(t *testing.T) {
+	broker := NewBroker(Options{Buffer: 4, History: 4})
+
+	alice, err := broker.Subscribe("alice", 0)
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	defer alice.Close()
+
+	broker.Publish(TypeCreated, models.Todo{ID: 1, Title: "чужая", Owner: "bob"})
+	broker.Publish(TypeUpdated, models.Todo{ID: 1, Title: "своя", Owner: "alice"})
+
+	select {
+	case event := <-alice.Events():
+		if event.ID != 2 || event.Type != TypeUpdated || event.Todo.Title != "своя" {
+			t.Fatalf("получено неожиданное событие %+v", event)
+		}
+	default:
+		t.Fatal("ожидалось событие владельца")
+	}
+
+	select {
+	case event := <-alice.Events():
+		t.Fatalf("получено лишнее событие %+v", event)
+	default:
+	}
+}
+
+func TestBrokerResume(t *testing.T) {
+	cases := []struct {
+		name        string
+		lastID      uint64
+		wantBacklog []uint64
+		wantGap     bool
+	}{
+		{name: "новый подписчик", lastID: 0, wantBacklog: nil},
+		{name: "пропущенные события в истории", lastID: 4, wantBacklog: []uint64{5, 6}},
+		{name: "все события получены", lastID: 6, wantBacklog: nil},
+		{name: "часть событий вытеснена", lastID: 1, wantBacklog: []uint64{3, 4, 5, 6}, wantGap: true},
+		{name: "неизвестный ID", lastID: 100, wantBacklog: nil, wantGap: true},
+	}
+
+	broker := NewBroker(Options{Buffer: 4, History: 4})
+	for id := 1; id <= 6; id++ {
+		broker.Publish(TypeCreated, models.Todo{ID: id, Owner: "alice"})
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			sub, err := broker.Subscribe("alice", tc.lastID)
+			if err != nil {
+				t.Fatalf("неожиданная ошибка: %v", err)
+			}
+			defer sub.Close()
+
+			if got := ids(sub.Backlog); !slices.Equal(got, tc.wantBacklog) {
+				t.Errorf("ожидались события %v, получено %v", tc.wantBacklog, got)
+			}
+			if sub.Gap != tc.wantGap {
+				t.Errorf("ожидался разрыв %v, получено %v", tc.wantGap, sub.Gap)
+			}
+		})
+	}
+}
+
+func TestBrokerDropsSlowSubscriber(t *testing.T) {
+	broker := NewBroker(Options{Buffer: 2})
+
+	slow, err := broker.Subscribe("", 0)
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+
+	for id := 1; id <= 3; id++ {
+		broker.Publish(TypeCreated, models.Todo{ID: id})
+	}
+
+	var received int
+	for range slow.Events() {
+		received++
+	}
+	if received != 2 {
+		t.Fatalf("ожидалось 2 события до отключения, получено %d", received)
+	}
+
+	// Повторное закрытие уже отключенной подписки безопасно.
+	slow.Close()
+}
+
+func TestBrokerClose(t *testing.T) {
+	broker := NewBroker(Options{})
+
+	sub, err := broker.Subscribe("", 0)
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+
+	broker.Close()
+
+	if _, ok := <-sub.Events(); ok {
+		t.Fatal("ожидалось закрытие канала подписки")
+	}
+	if _, err := broker.Subscribe("", 0); !errors.Is(err, ErrClosed) {
+		t.Fatalf("ожидалась ошибка %v, получено %v", ErrClosed, err)
+	}
+	sub.Close()
+}
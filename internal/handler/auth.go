@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/RoGogDBD/ecom/internal/auth"
+)
+
+const (
+	wwwAuthenticateHeader = "WWW-Authenticate"
+
+	authRequiredMsg = "authentication required"
+)
+
+// AuthMiddleware требует аутентификации запроса одним из верификаторов и кладет
+// пользователя в контекст запроса. Верификаторы опрашиваются по порядку; решение
+// принимает первый, нашедший в запросе свои учетные данные. Без учетных данных или
+// с неверными запрос получает 401 с вызовами всех верификаторов в WWW-Authenticate.
+// Проверки состояния доступны без аутентификации, чтобы их мог опрашивать балансировщик.
+func AuthMiddleware(verifiers ...auth.Verifier) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Path == healthzPath || req.URL.Path == readyzPath {
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			for _, verifier := range verifiers {
+				principal, err := verifier.Verify(req)
+				if errors.Is(err, auth.ErrNoCredentials) {
+					continue
+				}
+				if err != nil {
+					unauthorized(w, verifiers, err.Error())
+					return
+				}
+
+				next.ServeHTTP(w, req.WithContext(auth.NewContext(req.Context(), principal)))
+				return
+			}
+
+			unauthorized(w, verifiers, authRequiredMsg)
+		})
+	}
+}
+
+func unauthorized(w http.ResponseWriter, verifiers []auth.Verifier, message string) {
+	for _, verifier := range verifiers {
+		w.Header().Add(wwwAuthenticateHeader, verifier.Challenge())
+	}
+
+	writeError(w, http.StatusUnauthorized, message)
+}
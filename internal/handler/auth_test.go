@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RoGogDBD/ecom/internal/auth"
+)
+
+const (
+	testAPIKeyHeader = "X-API-Key"
+	testAPIKey       = "alice-key"
+)
+
+var testJWTSecret = []byte("секрет для подписи тестовых токенов")
+
+// testToken подписывает HS256 токен пользователя subject ключом secret.
+func testToken(t *testing.T, subject string, secret []byte) string {
+	t.Helper()
+
+	encode := func(v any) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("json.Marshal() ошибка = %v", err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+
+	input := encode(map[string]string{"alg": "HS256", "typ": "JWT"}) + "." +
+		encode(map[string]any{"sub": subject, "exp": time.Now().Add(time.Hour).Unix()})
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(input))
+
+	return input + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	apiKey := auth.NewAPIKeyVerifier(testAPIKeyHeader, map[string]string{testAPIKey: "alice"})
+	jwt := auth.NewJWTVerifier(auth.JWTOptions{Secret: testJWTSecret})
+
+	cases := []struct {
+		name      string
+		verifiers []auth.Verifier
+		path      string
+		header    map[string]string
+		// wantPrincipal пользователь в контексте обработчика; nil — обработчик не вызывается.
+		wantPrincipal *auth.Principal
+		wantError     string
+	}{
+		{
+			name:      "без учетных данных",
+			verifiers: []auth.Verifier{apiKey, jwt},
+			wantError: authRequiredMsg,
+		},
+		{
+			name:      "неизвестный ключ API",
+			verifiers: []auth.Verifier{apiKey, jwt},
+			header:    map[string]string{testAPIKeyHeader: "unknown"},
+			wantError: auth.ErrInvalidCredentials.Error(),
+		},
+		{
+			name:      "токен с чужой подписью",
+			verifiers: []auth.Verifier{apiKey, jwt},
+			header:    map[string]string{"Authorization": "Bearer " + testToken(t, "bob", []byte("другой секрет"))},
+			wantError: auth.ErrInvalidCredentials.Error(),
+		},
+		{
+			name:          "ключ API",
+			verifiers:     []auth.Verifier{apiKey, jwt},
+			header:        map[string]string{testAPIKeyHeader: testAPIKey},
+			wantPrincipal: &auth.Principal{Subject: "alice", Method: auth.MethodAPIKey},
+		},
+		{
+			name:          "токен при первом верификаторе без учетных данных",
+			verifiers:     []auth.Verifier{apiKey, jwt},
+			header:        map[string]string{"Authorization": "Bearer " + testToken(t, "bob", testJWTSecret)},
+			wantPrincipal: &auth.Principal{Subject: "bob", Method: auth.MethodJWT},
+		},
+		{
+			name:      "решает первый верификатор с учетными данными",
+			verifiers: []auth.Verifier{jwt, apiKey},
+			header: map[string]string{
+				"Authorization":  "Bearer invalid",
+				testAPIKeyHeader: testAPIKey,
+			},
+			wantError: auth.ErrInvalidCredentials.Error(),
+		},
+		{
+			name:          "проверка состояния без учетных данных",
+			verifiers:     []auth.Verifier{apiKey, jwt},
+			path:          healthzPath,
+			wantPrincipal: &auth.Principal{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got *auth.Principal
+			next := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				principal, _ := auth.FromContext(req.Context())
+				got = &principal
+				w.WriteHeader(http.StatusOK)
+			})
+			h := AuthMiddleware(tc.verifiers...)(next)
+
+			path := tc.path
+			if path == "" {
+				path = "/todos"
+			}
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			for name, value := range tc.header {
+				req.Header.Set(name, value)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if tc.wantPrincipal != nil {
+				if rec.Code != http.StatusOK || got == nil || *got != *tc.wantPrincipal {
+					t.Fatalf("статус %d, пользователь %+v, ожидался %+v", rec.Code, got, *tc.wantPrincipal)
+				}
+				return
+			}
+
+			if rec.Code != http.StatusUnauthorized {
+				t.Fatalf("ожидался статус %d, получено %d", http.StatusUnauthorized, rec.Code)
+			}
+			if got != nil {
+				t.Fatal("обработчик не должен вызываться без аутентификации")
+			}
+
+			var body map[string]string
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("некорректное тело ответа %q: %v", rec.Body.String(), err)
+			}
+			if !strings.Contains(body[jsonErrorKey], tc.wantError) {
+				t.Fatalf("ошибка %q не содержит %q", body[jsonErrorKey], tc.wantError)
+			}
+
+			challenges := rec.Header().Values(wwwAuthenticateHeader)
+			var want []string
+			for _, verifier := range tc.verifiers {
+				want = append(want, verifier.Challenge())
+			}
+			if !slices.Equal(challenges, want) {
+				t.Fatalf("%s = %v, ожидалось %v", wwwAuthenticateHeader, challenges, want)
+			}
+		})
+	}
+}
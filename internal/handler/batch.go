@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/RoGogDBD/ecom/internal/models"
+	"github.com/RoGogDBD/ecom/internal/service"
+)
+
+const (
+	batchPath = "/todos:batch"
+
+	// maxBatchBodyBytes максимальный размер тела пакетного запроса.
+	maxBatchBodyBytes = 10 * maxBodyBytes
+)
+
+// batchResult элемент ответа пакетного запроса.
+type batchResult struct {
+	Index  int          `json:"index"`
+	Status int          `json:"status"`
+	Todo   *models.Todo `json:"todo,omitempty"`
+	Error  string       `json:"error,omitempty"`
+}
+
+// handleBatch выполняет POST /todos:batch. Тело — массив операций, режим выбирается
+// параметром atomic: true — все или ничего, иначе каждая операция независимо.
+// Ответ — массив результатов в порядке операций со статусами как у одиночных запросов.
+func (r *Router) handleBatch(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	atomic := false
+	if raw := req.URL.Query().Get(queryAtomic); raw != "" {
+		var err error
+		if atomic, err = strconv.ParseBool(raw); err != nil {
+			writeError(w, http.StatusBadRequest, "некорректный параметр "+queryAtomic)
+			return
+		}
+	}
+
+	ops, err := decodeBatch(w, req)
+	if err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	results, err := r.service.Batch(req.Context(), ops, atomic)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	status := http.StatusOK
+	response := make([]batchResult, len(results))
+	for i, result := range results {
+		response[i] = newBatchResult(i, ops[i].Op, result)
+		// В атомарном режиме статус ответа — статус операции, из-за которой пакет отменен.
+		if atomic && result.Err != nil && !errors.Is(result.Err, models.ErrBatchAborted) {
+			status = response[i].Status
+		}
+	}
+
+	writeJSON(w, status, response)
+}
+
+func newBatchResult(index int, op string, result models.BatchResult) batchResult {
+	if result.Err != nil {
+		status, message := serviceErrorStatus(result.Err)
+		return batchResult{Index: index, Status: status, Error: message}
+	}
+
+	switch op {
+	case models.BatchCreate:
+		return batchResult{Index: index, Status: http.StatusCreated, Todo: &result.Todo}
+	case models.BatchDelete:
+		return batchResult{Index: index, Status: http.StatusNoContent}
+	default:
+		return batchResult{Index: index, Status: http.StatusOK, Todo: &result.Todo}
+	}
+}
+
+// decodeBatch читает операции пакета из тела запроса не больше maxBatchBodyBytes
+// и отклоняет пакет, в котором больше service.MaxBatchSize операций.
+func decodeBatch(w http.ResponseWriter, req *http.Request) ([]models.BatchOperation, error) {
+	defer req.Body.Close()
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxBatchBodyBytes))
+	dec.DisallowUnknownFields()
+
+	var ops []models.BatchOperation
+	if err := dec.Decode(&ops); err != nil {
+		return nil, err
+	}
+
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		return nil, errors.New(invalidJSONPayloadMsg)
+	}
+
+	if len(ops) > service.MaxBatchSize {
+		return nil, fmt.Errorf("слишком много операций: не более %d в пакете", service.MaxBatchSize)
+	}
+
+	for _, op := range ops {
+		if op.Todo != nil {
+			clearServerFields(op.Todo)
+		}
+	}
+
+	return ops, nil
+}
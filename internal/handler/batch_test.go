@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/RoGogDBD/ecom/internal/models"
+	"github.com/RoGogDBD/ecom/internal/service"
+)
+
+// batchService TodoService, у которого реализован только Batch: возвращает results
+// и запоминает переданные операции и режим.
+type batchService struct {
+	TodoService
+
+	results []models.BatchResult
+	err     error
+
+	ops    []models.BatchOperation
+	atomic bool
+	calls  int
+}
+
+func (s *batchService) Batch(_ context.Context, ops []models.BatchOperation, atomic bool) ([]models.BatchResult, error) {
+	s.calls++
+	s.ops = ops
+	s.atomic = atomic
+	return s.results, s.err
+}
+
+func TestHandleBatch(t *testing.T) {
+	todo := models.Todo{ID: 1, Title: "задача", Version: 1}
+	threeOps := `[
+		{"op":"create","todo":{"title":"задача"}},
+		{"op":"update","id":2,"todo":{"title":"другая"}},
+		{"op":"delete","id":1}
+	]`
+
+	cases := []struct {
+		name       string
+		query      string
+		body       string
+		results    []models.BatchResult
+		err        error
+		wantStatus int
+		wantAtomic bool
+		// wantItems статусы результатов операций; nil — ответ не массив результатов.
+		wantItems []int
+	}{
+		{
+			name:       "все операции выполнены",
+			query:      "?atomic=true",
+			body:       threeOps,
+			results:    []models.BatchResult{{Todo: todo}, {Todo: todo}, {}},
+			wantStatus: http.StatusOK,
+			wantAtomic: true,
+			wantItems:  []int{http.StatusCreated, http.StatusOK, http.StatusNoContent},
+		},
+		{
+			name:  "атомарный пакет отменен",
+			query: "?atomic=true",
+			body:  threeOps,
+			results: []models.BatchResult{
+				{Err: models.ErrBatchAborted}, {Err: models.ErrVersionMismatch}, {Err: models.ErrBatchAborted},
+			},
+			wantStatus: http.StatusPreconditionFailed,
+			wantAtomic: true,
+			wantItems:  []int{http.StatusFailedDependency, http.StatusPreconditionFailed, http.StatusFailedDependency},
+		},
+		{
+			name:       "независимые операции с ошибкой",
+			body:       threeOps,
+			results:    []models.BatchResult{{Todo: todo}, {Err: models.ErrNotFound}, {Err: models.ErrHasChildren}},
+			wantStatus: http.StatusOK,
+			wantItems:  []int{http.StatusCreated, http.StatusNotFound, http.StatusConflict},
+		},
+		{
+			name:       "ошибка сервиса",
+			body:       threeOps,
+			err:        models.ErrInvalidBatch,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "некорректный atomic",
+			query:      "?atomic=maybe",
+			body:       threeOps,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "слишком много операций",
+			body:       "[" + strings.Repeat(`{"op":"delete","id":1},`, service.MaxBatchSize) + `{"op":"delete","id":1}]`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "слишком большое тело",
+			body:       `[{"op":"create","todo":{"title":"` + strings.Repeat("a", maxBatchBodyBytes) + `"}}]`,
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			service := &batchService{results: tc.results, err: tc.err}
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/todos:batch"+tc.query, strings.NewReader(tc.body))
+			NewRouter(service).ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("ожидался статус %d, получено %d: %s", tc.wantStatus, rec.Code, rec.Body.String())
+			}
+			if tc.wantItems == nil {
+				if tc.err == nil && service.calls != 0 {
+					t.Fatal("некорректный запрос не должен доходить до сервиса")
+				}
+				return
+			}
+			if service.atomic != tc.wantAtomic || len(service.ops) != len(tc.wantItems) {
+				t.Fatalf("сервис получил %d операций, atomic=%v", len(service.ops), service.atomic)
+			}
+
+			var items []batchResult
+			if err := json.Unmarshal(rec.Body.Bytes(), &items); err != nil {
+				t.Fatalf("некорректное тело ответа %q: %v", rec.Body.String(), err)
+			}
+			if len(items) != len(tc.wantItems) {
+				t.Fatalf("ожидалось %d результатов, получено %d", len(tc.wantItems), len(items))
+			}
+			for i, item := range items {
+				if item.Index != i || item.Status != tc.wantItems[i] {
+					t.Fatalf("результат %d: index=%d status=%d, ожидался status %d", i, item.Index, item.Status, tc.wantItems[i])
+				}
+				if wantTodo := item.Status == http.StatusCreated || item.Status == http.StatusOK; wantTodo != (item.Todo != nil) {
+					t.Fatalf("результат %d: todo=%v при статусе %d", i, item.Todo, item.Status)
+				}
+				if item.Status >= http.StatusBadRequest && item.Error == "" {
+					t.Fatalf("результат %d: ожидалось сообщение об ошибке", i)
+				}
+			}
+		})
+	}
+}
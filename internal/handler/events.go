@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/RoGogDBD/ecom/internal/events"
+)
+
+const (
+	eventsPath = "/todos/events"
+
+	lastEventIDHeader    = "Last-Event-ID"
+	cacheControlHeader   = "Cache-Control"
+	contentTypeEvents    = "text/event-stream"
+	eventTypeReset       = "reset"
+	eventsUnavailableMsg = "поток событий недоступен"
+
+	// eventsHeartbeat период комментариев-пингов, которые не дают прокси закрыть простаивающее соединение.
+	eventsHeartbeat = 15 * time.Second
+)
+
+// handleEvents выполняет GET /todos/events: поток Server-Sent Events с изменениями задач
+// пользователя. Клиент, передавший Last-Event-ID, сначала получает пропущенные события
+// из истории; если часть из них уже недоступна, поток начинается с события reset.
+func (r *Router) handleEvents(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var lastID uint64
+	if raw := req.Header.Get(lastEventIDHeader); raw != "" {
+		var err error
+		if lastID, err = strconv.ParseUint(raw, 10, 64); err != nil {
+			writeError(w, http.StatusBadRequest, "некорректный заголовок "+lastEventIDHeader)
+			return
+		}
+	}
+
+	sub, err := r.service.Subscribe(req.Context(), lastID)
+	if errors.Is(err, events.ErrClosed) {
+		writeError(w, http.StatusServiceUnavailable, eventsUnavailableMsg)
+		return
+	}
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	defer sub.Close()
+
+	rc := http.NewResponseController(w)
+	w.Header().Set(contentTypeHeader, contentTypeEvents)
+	w.Header().Set(cacheControlHeader, "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	if sub.Gap {
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: {}\n\n", eventTypeReset); err != nil {
+			return
+		}
+	}
+	for _, event := range sub.Backlog {
+		if err := writeEvent(w, event); err != nil {
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(eventsHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case event, ok := <-sub.Events():
+			if !ok {
+				// Подписка закрыта брокером: сервер останавливается или клиент не успевал
+				// читать. Клиент переподключится и продолжит с Last-Event-ID.
+				return
+			}
+			if err := writeEvent(w, event); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := io.WriteString(w, ": ping\n\n"); err != nil {
+				return
+			}
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// writeEvent пишет событие в формате text/event-stream; данные — задача в JSON.
+func writeEvent(w io.Writer, event events.Event) error {
+	data, err := json.Marshal(event.Todo)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
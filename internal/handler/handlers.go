@@ -1,12 +1,29 @@
 package handler
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/RoGogDBD/ecom/internal/models"
 )
 
-const notFoundMessage = "todo not found"
+const (
+	notFoundMessage = "todo not found"
+
+	revisionNotFoundMessage = "revision not found"
+
+	trashPath      = "/todos/trash"
+	tagsPath       = "/tags"
+	restoreSegment = "restore"
+	historySegment = "history"
+	revertSegment  = "revert"
+	// childrenSegment подзадачи первого уровня: /todos/{id}/children.
+	childrenSegment = "children"
+)
 
 func (r *Router) handleTodos(w http.ResponseWriter, req *http.Request) {
 	switch req.Method {
@@ -19,18 +36,69 @@ func (r *Router) handleTodos(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
+// handleTodoByID обслуживает /todos/{id} и вложенные ресурсы задачи: восстановление
+// из корзины /todos/{id}/restore, историю /todos/{id}/history[/{rev}],
+// откат к ревизии /todos/{id}/revert/{rev} и подзадачи /todos/{id}/children.
 func (r *Router) handleTodoByID(w http.ResponseWriter, req *http.Request) {
-	id, ok := parseID(req.URL.Path)
+	rawID, sub, nested := strings.Cut(strings.TrimPrefix(req.URL.Path, todosPathPrefix), "/")
+	id, ok := parseID(todosPathPrefix + rawID)
 	if !ok {
 		writeError(w, http.StatusNotFound, notFoundMessage)
 		return
 	}
 
+	switch segment, rawRev, hasRev := strings.Cut(sub, "/"); {
+	case !nested:
+		r.handleTodo(w, req, id)
+	case sub == restoreSegment:
+		if req.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		r.handleRestore(w, req, id)
+	case sub == childrenSegment:
+		if req.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		r.handleChildren(w, req, id)
+	case segment == historySegment:
+		if req.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if !hasRev {
+			r.handleHistory(w, req, id)
+			return
+		}
+		if rev, ok := parseRev(rawRev); ok {
+			r.handleRevision(w, req, id, rev)
+			return
+		}
+		writeError(w, http.StatusNotFound, revisionNotFoundMessage)
+	case segment == revertSegment && hasRev:
+		if req.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if rev, ok := parseRev(rawRev); ok {
+			r.handleRevert(w, req, id, rev)
+			return
+		}
+		writeError(w, http.StatusNotFound, revisionNotFoundMessage)
+	default:
+		writeError(w, http.StatusNotFound, notFoundMessage)
+	}
+}
+
+func (r *Router) handleTodo(w http.ResponseWriter, req *http.Request, id int) {
 	switch req.Method {
 	case http.MethodGet:
 		r.handleGetByID(w, req, id)
 	case http.MethodPut:
 		r.handleUpdate(w, req, id)
+	case http.MethodPatch:
+		r.handlePatch(w, req, id)
 	case http.MethodDelete:
 		r.handleDelete(w, req, id)
 	default:
@@ -39,62 +107,168 @@ func (r *Router) handleTodoByID(w http.ResponseWriter, req *http.Request) {
 }
 
 func (r *Router) handleCreate(w http.ResponseWriter, req *http.Request) {
-	todo, err := decodeTodo(req)
+	todo, err := decodeTodo(w, req)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, err.Error())
+		writeBodyError(w, err)
 		return
 	}
 
-	if err := r.service.Create(req.Context(), todo); err != nil {
+	created, err := r.service.Create(req.Context(), todo)
+	if err != nil {
 		writeServiceError(w, err)
 		return
 	}
 
-	writeJSON(w, http.StatusCreated, todo)
+	w.Header().Set(locationHeader, todosPathPrefix+strconv.Itoa(created.ID))
+	w.Header().Set(etagHeader, etag(created))
+	writeJSON(w, http.StatusCreated, created)
 }
 
 func (r *Router) handleGetAll(w http.ResponseWriter, req *http.Request) {
-	items, err := r.service.GetAll(req.Context())
+	query, err := parseTodoQuery(req.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	expand, err := parseExpand(req.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page, err := r.service.List(req.Context(), query)
 	if err != nil {
 		writeServiceError(w, err)
 		return
 	}
 
+	items := page.Items
 	if items == nil {
 		items = []models.Todo{}
 	}
 
-	writeJSON(w, http.StatusOK, items)
+	w.Header().Set(totalCountHeader, strconv.Itoa(page.Total))
+	if page.NextCursor != "" {
+		w.Header().Set(nextCursorHeader, page.NextCursor)
+		w.Header().Set(linkHeader, nextPageLink(req.URL, page.NextCursor))
+	}
+
+	r.writeTodos(w, req, items, expand)
 }
 
 func (r *Router) handleGetByID(w http.ResponseWriter, req *http.Request, id int) {
+	expand, err := parseExpand(req.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	item, err := r.service.GetByID(req.Context(), id)
 	if err != nil {
 		writeServiceError(w, err)
 		return
 	}
 
+	// Дерево меняется вместе с подзадачами, а ETag отражает версию только самой задачи.
+	if expand {
+		trees, err := r.service.Expand(req.Context(), []models.Todo{item})
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, trees[0])
+		return
+	}
+
+	tag := etag(item)
+	w.Header().Set(etagHeader, tag)
+	if etagMatches(req.Header.Get(ifNoneMatchHeader), tag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
 	writeJSON(w, http.StatusOK, item)
 }
 
 func (r *Router) handleUpdate(w http.ResponseWriter, req *http.Request, id int) {
-	todo, err := decodeTodo(req)
+	version, err := r.ifMatchVersion(req, r.todoByID, id)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, err.Error())
+		writeServiceError(w, err)
+		return
+	}
+
+	todo, err := decodeTodo(w, req)
+	if err != nil {
+		writeBodyError(w, err)
 		return
 	}
 	todo.ID = id
+	todo.Version = version
+
+	updated, err := r.service.Update(req.Context(), todo)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set(etagHeader, etag(updated))
+	writeJSON(w, http.StatusOK, updated)
+}
+
+func (r *Router) handlePatch(w http.ResponseWriter, req *http.Request, id int) {
+	patchType, ok := parsePatchType(req.Header.Get(contentTypeHeader))
+	if !ok {
+		w.Header().Set(acceptPatchHeader, acceptPatchValue)
+		writeError(w, http.StatusUnsupportedMediaType, unsupportedPatchMsg)
+		return
+	}
 
-	if err := r.service.Update(req.Context(), todo); err != nil {
+	version, err := r.ifMatchVersion(req, r.todoByID, id)
+	if err != nil {
 		writeServiceError(w, err)
 		return
 	}
 
+	patch, err := readBody(w, req)
+	if err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	todo, err := r.service.Patch(req.Context(), id, version, patchType, patch)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set(etagHeader, etag(todo))
 	writeJSON(w, http.StatusOK, todo)
 }
 
 func (r *Router) handleDelete(w http.ResponseWriter, req *http.Request, id int) {
-	if err := r.service.Delete(req.Context(), id); err != nil {
+	hard := false
+	if raw := req.URL.Query().Get(queryHard); raw != "" {
+		var err error
+		if hard, err = strconv.ParseBool(raw); err != nil {
+			writeError(w, http.StatusBadRequest, "некорректный параметр "+queryHard)
+			return
+		}
+	}
+	children := models.ChildrenMode(req.URL.Query().Get(queryChildren))
+
+	del, lookup := r.service.Delete, r.todoByID
+	if hard {
+		// Окончательно удалить можно и задачу из корзины.
+		del, lookup = r.service.HardDelete, r.storedByID
+	}
+
+	version, err := r.ifMatchVersion(req, lookup, id)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	if err := del(req.Context(), id, version, children); err != nil {
 		writeServiceError(w, err)
 		return
 	}
@@ -102,6 +276,193 @@ func (r *Router) handleDelete(w http.ResponseWriter, req *http.Request, id int)
 	w.WriteHeader(http.StatusNoContent)
 }
 
+func (r *Router) handleRestore(w http.ResponseWriter, req *http.Request, id int) {
+	version, err := r.ifMatchVersion(req, r.trashedByID, id)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	restored, err := r.service.Restore(req.Context(), id, version)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set(etagHeader, etag(restored))
+	writeJSON(w, http.StatusOK, restored)
+}
+
+// handleTrash выполняет GET /todos/trash: задачи в корзине, начиная с удаленных последними.
+func (r *Router) handleTrash(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	items, err := r.service.ListTrash(req.Context())
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, items)
+}
+
+// handleTags выполняет GET /tags: теги задач с количеством задач у каждого, начиная с самых частых.
+func (r *Router) handleTags(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	tags, err := r.service.Tags(req.Context())
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tags)
+}
+
+// handleChildren выполняет GET /todos/{id}/children: подзадачи первого уровня в порядке ID.
+// С expand=subtasks каждая подзадача отдается с деревом своих подзадач.
+func (r *Router) handleChildren(w http.ResponseWriter, req *http.Request, id int) {
+	expand, err := parseExpand(req.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	children, err := r.service.Children(req.Context(), id)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	r.writeTodos(w, req, children, expand)
+}
+
+// writeTodos пишет список задач, при expand — с деревьями их подзадач.
+func (r *Router) writeTodos(w http.ResponseWriter, req *http.Request, todos []models.Todo, expand bool) {
+	if !expand {
+		writeJSON(w, http.StatusOK, todos)
+		return
+	}
+
+	trees, err := r.service.Expand(req.Context(), todos)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, trees)
+}
+
+// handleHistory выполняет GET /todos/{id}/history: все ревизии задачи, начиная с первой.
+func (r *Router) handleHistory(w http.ResponseWriter, req *http.Request, id int) {
+	revisions, err := r.service.History(req.Context(), id)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, revisions)
+}
+
+// handleRevision выполняет GET /todos/{id}/history/{rev}. Параметр from задает ревизию,
+// относительно которой считаются изменения; без него — предыдущая.
+func (r *Router) handleRevision(w http.ResponseWriter, req *http.Request, id, rev int) {
+	from, err := parseIntParam(req.URL.Query(), queryFrom)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	revision, err := r.service.Revision(req.Context(), id, rev, from)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, revision)
+}
+
+func (r *Router) handleRevert(w http.ResponseWriter, req *http.Request, id, rev int) {
+	version, err := r.ifMatchVersion(req, r.todoByID, id)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	reverted, err := r.service.Revert(req.Context(), id, rev, version)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set(etagHeader, etag(reverted))
+	writeJSON(w, http.StatusOK, reverted)
+}
+
+// ifMatchVersion возвращает ожидаемую версию задачи id по заголовку If-Match.
+// Если в заголовке несколько ETag, ожидаемой становится текущая версия задачи из lookup,
+// когда она есть в списке. Сервис сравнивает ее с версией задачи при записи, поэтому
+// изменение задачи после чтения все равно приводит к 412.
+func (r *Router) ifMatchVersion(req *http.Request, lookup func(context.Context, int) (models.Todo, error), id int) (int, error) {
+	versions, ok := parseIfMatch(req.Header.Get(ifMatchHeader))
+	if !ok {
+		return 0, models.ErrVersionMismatch
+	}
+	switch len(versions) {
+	case 0:
+		return 0, nil
+	case 1:
+		return versions[0], nil
+	}
+
+	current, err := lookup(req.Context(), id)
+	if err != nil {
+		return 0, err
+	}
+	if !slices.Contains(versions, current.Version) {
+		return 0, models.ErrVersionMismatch
+	}
+
+	return current.Version, nil
+}
+
+// todoByID возвращает задачу id, если она не в корзине.
+func (r *Router) todoByID(ctx context.Context, id int) (models.Todo, error) {
+	return r.service.GetByID(ctx, id)
+}
+
+// trashedByID возвращает задачу id из корзины.
+func (r *Router) trashedByID(ctx context.Context, id int) (models.Todo, error) {
+	items, err := r.service.ListTrash(ctx)
+	if err != nil {
+		return models.Todo{}, err
+	}
+
+	for _, todo := range items {
+		if todo.ID == id {
+			return todo, nil
+		}
+	}
+
+	return models.Todo{}, models.ErrNotFound
+}
+
+// storedByID возвращает задачу id, в том числе из корзины.
+func (r *Router) storedByID(ctx context.Context, id int) (models.Todo, error) {
+	todo, err := r.todoByID(ctx, id)
+	if errors.Is(err, models.ErrNotFound) {
+		return r.trashedByID(ctx, id)
+	}
+
+	return todo, err
+}
+
 // func swaggerHandler(w http.ResponseWriter, req *http.Request) {
 // 	if req.Method != http.MethodGet {
 // 		w.WriteHeader(http.StatusMethodNotAllowed)
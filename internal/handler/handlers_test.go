@@ -0,0 +1,237 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/RoGogDBD/ecom/internal/models"
+)
+
+func TestDecodeTodoErrors(t *testing.T) {
+	cases := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{
+			name:       "слишком большое тело при создании",
+			method:     http.MethodPost,
+			path:       "/todos",
+			body:       `{"title":"` + strings.Repeat("a", maxBodyBytes) + `"}`,
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:       "слишком большое тело при замене",
+			method:     http.MethodPut,
+			path:       "/todos/1",
+			body:       `{"title":"` + strings.Repeat("a", maxBodyBytes) + `"}`,
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:       "слишком большое тело подписки",
+			method:     http.MethodPost,
+			path:       "/webhooks",
+			body:       `{"url":"` + strings.Repeat("a", maxBodyBytes) + `"}`,
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:       "неизвестное поле",
+			method:     http.MethodPost,
+			path:       "/todos",
+			body:       `{"title":"задача","unknown":1}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "данные после объекта",
+			method:     http.MethodPost,
+			path:       "/todos",
+			body:       `{"title":"задача"} {}`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	// Ошибки разбора тела возвращаются до обращения к сервису.
+	router := NewRouter(nil, WithWebhooks(nil))
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body)))
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("ожидался статус %d, получено %d: %s", tc.wantStatus, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+// versionService TodoService с активной задачей 1 версии 4 и задачей 2 версии 5 в корзине.
+// Изменяющие методы запоминают полученную ожидаемую версию, не сравнивая ее с текущей.
+type versionService struct {
+	TodoService
+
+	version int
+	calls   int
+}
+
+func (s *versionService) GetByID(_ context.Context, id int) (models.Todo, error) {
+	if id != 1 {
+		return models.Todo{}, models.ErrNotFound
+	}
+	return models.Todo{ID: 1, Title: "задача", Version: 4}, nil
+}
+
+func (s *versionService) ListTrash(context.Context) ([]models.Todo, error) {
+	return []models.Todo{{ID: 2, Title: "в корзине", Version: 5}}, nil
+}
+
+func (s *versionService) Update(_ context.Context, todo models.Todo) (models.Todo, error) {
+	s.record(todo.Version)
+	return todo, nil
+}
+
+func (s *versionService) Delete(_ context.Context, _, version int, _ models.ChildrenMode) error {
+	s.record(version)
+	return nil
+}
+
+func (s *versionService) HardDelete(_ context.Context, _, version int, _ models.ChildrenMode) error {
+	s.record(version)
+	return nil
+}
+
+func (s *versionService) Restore(_ context.Context, id, version int) (models.Todo, error) {
+	s.record(version)
+	return models.Todo{ID: id}, nil
+}
+
+func (s *versionService) record(version int) {
+	s.calls++
+	s.version = version
+}
+
+func TestIfMatch(t *testing.T) {
+	cases := []struct {
+		name        string
+		method      string
+		path        string
+		ifMatch     string
+		wantStatus  int
+		wantVersion int
+	}{
+		{
+			name:        "без условия",
+			method:      http.MethodPut,
+			path:        "/todos/1",
+			wantStatus:  http.StatusOK,
+			wantVersion: 0,
+		},
+		{
+			name:        "любая версия",
+			method:      http.MethodPut,
+			path:        "/todos/1",
+			ifMatch:     "*",
+			wantStatus:  http.StatusOK,
+			wantVersion: 0,
+		},
+		{
+			name:        "один ETag",
+			method:      http.MethodPut,
+			path:        "/todos/1",
+			ifMatch:     `"3"`,
+			wantStatus:  http.StatusOK,
+			wantVersion: 3,
+		},
+		{
+			name:        "список с текущей версией",
+			method:      http.MethodPut,
+			path:        "/todos/1",
+			ifMatch:     `"3", "4"`,
+			wantStatus:  http.StatusOK,
+			wantVersion: 4,
+		},
+		{
+			name:       "список без текущей версии",
+			method:     http.MethodPut,
+			path:       "/todos/1",
+			ifMatch:    `"2", "3"`,
+			wantStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:        "слабый ETag в списке пропускается",
+			method:      http.MethodPut,
+			path:        "/todos/1",
+			ifMatch:     `W/"4", "3"`,
+			wantStatus:  http.StatusOK,
+			wantVersion: 3,
+		},
+		{
+			name:       "только слабый ETag",
+			method:     http.MethodPut,
+			path:       "/todos/1",
+			ifMatch:    `W/"4"`,
+			wantStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:       "список для несуществующей задачи",
+			method:     http.MethodPut,
+			path:       "/todos/3",
+			ifMatch:    `"3", "4"`,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:        "удаление по списку",
+			method:      http.MethodDelete,
+			path:        "/todos/1",
+			ifMatch:     `"4", "5"`,
+			wantStatus:  http.StatusNoContent,
+			wantVersion: 4,
+		},
+		{
+			name:        "окончательное удаление из корзины по списку",
+			method:      http.MethodDelete,
+			path:        "/todos/2?hard=true",
+			ifMatch:     `"4", "5"`,
+			wantStatus:  http.StatusNoContent,
+			wantVersion: 5,
+		},
+		{
+			name:        "восстановление по списку",
+			method:      http.MethodPost,
+			path:        "/todos/2/restore",
+			ifMatch:     `"5", "6"`,
+			wantStatus:  http.StatusOK,
+			wantVersion: 5,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			service := &versionService{}
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(`{"title":"задача"}`))
+			if tc.ifMatch != "" {
+				req.Header.Set(ifMatchHeader, tc.ifMatch)
+			}
+			rec := httptest.NewRecorder()
+			NewRouter(service).ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("ожидался статус %d, получено %d: %s", tc.wantStatus, rec.Code, rec.Body.String())
+			}
+			if tc.wantStatus >= http.StatusBadRequest {
+				if service.calls != 0 {
+					t.Fatal("запрос с невыполненным условием не должен изменять задачу")
+				}
+				return
+			}
+			if service.calls != 1 || service.version != tc.wantVersion {
+				t.Fatalf("сервис вызван %d раз с версией %d, ожидалась версия %d", service.calls, service.version, tc.wantVersion)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	healthzPath = "/healthz"
+	readyzPath  = "/readyz"
+
+	healthStatusOK          = "ok"
+	healthStatusDraining    = "draining"
+	healthStatusUnavailable = "unavailable"
+	healthCheckFail         = "fail"
+
+	// healthCheckTimeout ограничивает время одной проверки готовности.
+	healthCheckTimeout = 2 * time.Second
+)
+
+type (
+	// HealthCheck проверяет зависимость сервиса; ошибка означает, что сервис не готов.
+	HealthCheck func(ctx context.Context) error
+
+	// Health отвечает на проверки живости (/healthz) и готовности (/readyz).
+	// Готовность снимается при остановке сервера (Drain) или при ошибке любой проверки.
+	Health struct {
+		draining atomic.Bool
+
+		mu     sync.RWMutex
+		checks []namedCheck
+	}
+
+	namedCheck struct {
+		name  string
+		check HealthCheck
+	}
+
+	healthResponse struct {
+		Status string            `json:"status"`
+		Checks map[string]string `json:"checks,omitempty"`
+	}
+)
+
+// NewHealth создает Health без проверок: сервис готов, пока не вызван Drain.
+func NewHealth() *Health {
+	return &Health{}
+}
+
+// AddCheck добавляет именованную проверку готовности.
+func (h *Health) AddCheck(name string, check HealthCheck) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.checks = append(h.checks, namedCheck{name: name, check: check})
+}
+
+// Drain переводит сервис в состояние остановки: /readyz начинает отвечать 503,
+// чтобы балансировщик перестал направлять новые запросы.
+func (h *Health) Drain() {
+	h.draining.Store(true)
+}
+
+// WithHealth публикует проверки живости и готовности по адресам /healthz и /readyz.
+func WithHealth(h *Health) Option {
+	return func(mux *http.ServeMux) {
+		mux.HandleFunc(healthzPath, h.handleLive)
+		mux.HandleFunc(readyzPath, h.handleReady)
+	}
+}
+
+// handleLive сообщает, что процесс жив и обрабатывает запросы.
+func (h *Health) handleLive(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, healthResponse{Status: healthStatusOK})
+}
+
+// handleReady сообщает, готов ли сервис принимать трафик. Текст ошибок проверок
+// не раскрывается: в ответе только имя проверки и ее результат.
+func (h *Health) handleReady(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if h.draining.Load() {
+		writeJSON(w, http.StatusServiceUnavailable, healthResponse{Status: healthStatusDraining})
+		return
+	}
+
+	h.mu.RLock()
+	checks := h.checks
+	h.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(req.Context(), healthCheckTimeout)
+	defer cancel()
+
+	resp := healthResponse{Status: healthStatusOK}
+	status := http.StatusOK
+	for _, c := range checks {
+		if resp.Checks == nil {
+			resp.Checks = make(map[string]string, len(checks))
+		}
+
+		if err := c.check(ctx); err != nil {
+			resp.Checks[c.name] = healthCheckFail
+			resp.Status = healthStatusUnavailable
+			status = http.StatusServiceUnavailable
+			continue
+		}
+		resp.Checks[c.name] = healthStatusOK
+	}
+
+	writeJSON(w, status, resp)
+}
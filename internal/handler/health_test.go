@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHealth(t *testing.T) {
+	okCheck := func(context.Context) error { return nil }
+	failCheck := func(context.Context) error {
+		return errors.New("secret: соединение с хранилищем разорвано")
+	}
+
+	cases := []struct {
+		name       string
+		prepare    func(h *Health)
+		method     string
+		path       string
+		wantStatus int
+		want       healthResponse
+	}{
+		{
+			name:       "живость",
+			path:       healthzPath,
+			wantStatus: http.StatusOK,
+			want:       healthResponse{Status: healthStatusOK},
+		},
+		{
+			name:       "живость при остановке",
+			prepare:    func(h *Health) { h.Drain() },
+			path:       healthzPath,
+			wantStatus: http.StatusOK,
+			want:       healthResponse{Status: healthStatusOK},
+		},
+		{
+			name:       "готовность без проверок",
+			path:       readyzPath,
+			wantStatus: http.StatusOK,
+			want:       healthResponse{Status: healthStatusOK},
+		},
+		{
+			name: "успешные проверки",
+			prepare: func(h *Health) {
+				h.AddCheck("storage", okCheck)
+				h.AddCheck("events", okCheck)
+			},
+			path:       readyzPath,
+			wantStatus: http.StatusOK,
+			want: healthResponse{Status: healthStatusOK, Checks: map[string]string{
+				"storage": healthStatusOK, "events": healthStatusOK,
+			}},
+		},
+		{
+			name: "неуспешная проверка",
+			prepare: func(h *Health) {
+				h.AddCheck("storage", failCheck)
+				h.AddCheck("events", okCheck)
+			},
+			path:       readyzPath,
+			wantStatus: http.StatusServiceUnavailable,
+			want: healthResponse{Status: healthStatusUnavailable, Checks: map[string]string{
+				"storage": healthCheckFail, "events": healthStatusOK,
+			}},
+		},
+		{
+			name: "остановка",
+			prepare: func(h *Health) {
+				h.AddCheck("storage", okCheck)
+				h.Drain()
+			},
+			path:       readyzPath,
+			wantStatus: http.StatusServiceUnavailable,
+			want:       healthResponse{Status: healthStatusDraining},
+		},
+		{
+			name:       "неподдерживаемый метод",
+			method:     http.MethodPost,
+			path:       readyzPath,
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := NewHealth()
+			if tc.prepare != nil {
+				tc.prepare(h)
+			}
+			method := tc.method
+			if method == "" {
+				method = http.MethodGet
+			}
+
+			rec := httptest.NewRecorder()
+			NewRouter(nil, WithHealth(h)).ServeHTTP(rec, httptest.NewRequest(method, tc.path, nil))
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("ожидался статус %d, получено %d", tc.wantStatus, rec.Code)
+			}
+			if tc.want.Status == "" {
+				return
+			}
+			if strings.Contains(rec.Body.String(), "secret") {
+				t.Fatalf("ответ раскрывает текст ошибки проверки: %s", rec.Body.String())
+			}
+
+			var got healthResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("некорректное тело ответа %q: %v", rec.Body.String(), err)
+			}
+			if got.Status != tc.want.Status || !maps.Equal(got.Checks, tc.want.Checks) {
+				t.Fatalf("получено %+v, ожидалось %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestHealthCheckContext(t *testing.T) {
+	h := NewHealth()
+	h.AddCheck("deadline", func(ctx context.Context) error {
+		if _, ok := ctx.Deadline(); !ok {
+			return errors.New("проверка без ограничения времени")
+		}
+		return nil
+	})
+
+	rec := httptest.NewRecorder()
+	NewRouter(nil, WithHealth(h)).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, readyzPath, nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("ожидался статус %d, получено %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+}
@@ -3,10 +3,14 @@ package handler
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/RoGogDBD/ecom/internal/models"
 )
@@ -16,11 +20,58 @@ const (
 
 	contentTypeHeader = "Content-Type"
 	contentTypeJSON   = "application/json"
+	locationHeader    = "Location"
+	totalCountHeader  = "X-Total-Count"
+	nextCursorHeader  = "X-Next-Cursor"
+	linkHeader        = "Link"
+	acceptPatchHeader = "Accept-Patch"
+	etagHeader        = "ETag"
+	ifMatchHeader     = "If-Match"
+	ifNoneMatchHeader = "If-None-Match"
+
+	etagAny        = "*"
+	etagWeakPrefix = "W/"
+
+	contentTypeMergePatch = "application/merge-patch+json"
+	contentTypeJSONPatch  = "application/json-patch+json"
+	acceptPatchValue      = contentTypeMergePatch + ", " + contentTypeJSONPatch
+
+	maxBodyBytes = 1 << 20
+
+	queryLimit     = "limit"
+	queryOffset    = "offset"
+	queryCursor    = "cursor"
+	querySort      = "sort"
+	queryCompleted = "completed"
+	querySearch    = "q"
+	queryPriority  = "priority"
+	queryOverdue   = "overdue"
+	queryTag       = "tag"
+	queryAnyTag    = "any_tag"
+
+	queryAtomic   = "atomic"
+	queryHard     = "hard"
+	queryFrom     = "from"
+	queryChildren = "children"
+	queryExpand   = "expand"
+
+	expandSubtasks = "subtasks"
+
+	queryCreatedAfter    = "created_after"
+	queryCreatedBefore   = "created_before"
+	queryUpdatedAfter    = "updated_after"
+	queryUpdatedBefore   = "updated_before"
+	queryCompletedAfter  = "completed_after"
+	queryCompletedBefore = "completed_before"
+	queryDueAfter        = "due_after"
+	queryDueBefore       = "due_before"
 
 	invalidJSONPayloadMsg  = "invalid JSON payload"
 	internalServerErrorMsg = "internal server error"
+	unsupportedPatchMsg    = "unsupported patch media type, expected " + acceptPatchValue
 
-	jsonErrorKey = "error"
+	jsonErrorKey     = "error"
+	jsonRequestIDKey = "request_id"
 )
 
 func parseID(path string) (int, bool) {
@@ -37,10 +88,145 @@ func parseID(path string) (int, bool) {
 	return id, true
 }
 
-func decodeTodo(req *http.Request) (models.Todo, error) {
+// parseRev разбирает номер ревизии из пути.
+func parseRev(raw string) (int, bool) {
+	rev, err := strconv.Atoi(raw)
+	if err != nil || rev <= 0 {
+		return 0, false
+	}
+
+	return rev, true
+}
+
+// parseTodoQuery разбирает параметры фильтрации и пагинации списка задач.
+func parseTodoQuery(values url.Values) (models.TodoQuery, error) {
+	query := models.TodoQuery{
+		Cursor:   values.Get(queryCursor),
+		Sort:     values.Get(querySort),
+		Search:   values.Get(querySearch),
+		Priority: models.Priority(values.Get(queryPriority)),
+		Tags:     values[queryTag],
+		AnyTags:  values[queryAnyTag],
+	}
+
+	var err error
+	if query.Limit, err = parseIntParam(values, queryLimit); err != nil {
+		return models.TodoQuery{}, err
+	}
+	if query.Offset, err = parseIntParam(values, queryOffset); err != nil {
+		return models.TodoQuery{}, err
+	}
+
+	if query.Completed, err = parseBoolParam(values, queryCompleted); err != nil {
+		return models.TodoQuery{}, err
+	}
+	if query.Overdue, err = parseBoolParam(values, queryOverdue); err != nil {
+		return models.TodoQuery{}, err
+	}
+
+	if query.CreatedAt, err = parseTimeRange(values, queryCreatedAfter, queryCreatedBefore); err != nil {
+		return models.TodoQuery{}, err
+	}
+	if query.UpdatedAt, err = parseTimeRange(values, queryUpdatedAfter, queryUpdatedBefore); err != nil {
+		return models.TodoQuery{}, err
+	}
+	if query.CompletedAt, err = parseTimeRange(values, queryCompletedAfter, queryCompletedBefore); err != nil {
+		return models.TodoQuery{}, err
+	}
+	if query.DueAt, err = parseTimeRange(values, queryDueAfter, queryDueBefore); err != nil {
+		return models.TodoQuery{}, err
+	}
+
+	return query, nil
+}
+
+// parseExpand разбирает параметр expand: единственное поддерживаемое значение —
+// subtasks, дерево подзадач каждой задачи ответа.
+func parseExpand(values url.Values) (bool, error) {
+	switch raw := values.Get(queryExpand); raw {
+	case "":
+		return false, nil
+	case expandSubtasks:
+		return true, nil
+	default:
+		return false, fmt.Errorf("некорректный параметр %s: %q", queryExpand, raw)
+	}
+}
+
+// parseBoolParam разбирает необязательный логический параметр; nil — параметр не задан.
+func parseBoolParam(values url.Values, name string) (*bool, error) {
+	raw := values.Get(name)
+	if raw == "" {
+		return nil, nil
+	}
+
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		return nil, fmt.Errorf("некорректный параметр %s: %q", name, raw)
+	}
+
+	return &value, nil
+}
+
+// parseTimeRange разбирает пару параметров-границ в формате RFC 3339.
+func parseTimeRange(values url.Values, afterName, beforeName string) (models.TimeRange, error) {
+	var (
+		r   models.TimeRange
+		err error
+	)
+	if r.After, err = parseTimeParam(values, afterName); err != nil {
+		return models.TimeRange{}, err
+	}
+	if r.Before, err = parseTimeParam(values, beforeName); err != nil {
+		return models.TimeRange{}, err
+	}
+
+	return r, nil
+}
+
+func parseTimeParam(values url.Values, name string) (time.Time, error) {
+	raw := values.Get(name)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+
+	value, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("некорректный параметр %s: ожидается время в формате RFC 3339", name)
+	}
+
+	return value, nil
+}
+
+func parseIntParam(values url.Values, name string) (int, error) {
+	raw := values.Get(name)
+	if raw == "" {
+		return 0, nil
+	}
+
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("некорректный параметр %s: %q", name, raw)
+	}
+
+	return value, nil
+}
+
+// nextPageLink формирует заголовок Link на следующую страницу с сохранением остальных параметров.
+func nextPageLink(current *url.URL, cursor string) string {
+	values := current.Query()
+	values.Del(queryOffset)
+	values.Set(queryCursor, cursor)
+
+	next := url.URL{Path: current.Path, RawQuery: values.Encode()}
+	return fmt.Sprintf(`<%s>; rel="next"`, next.String())
+}
+
+// decodeTodo читает задачу из тела запроса, ограниченного maxBodyBytes, как и в readBody.
+func decodeTodo(w http.ResponseWriter, req *http.Request) (models.Todo, error) {
 	defer req.Body.Close()
 
-	dec := json.NewDecoder(req.Body)
+	dec := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxBodyBytes))
 	dec.DisallowUnknownFields()
 
 	var todo models.Todo
@@ -52,24 +238,161 @@ func decodeTodo(req *http.Request) (models.Todo, error) {
 		return models.Todo{}, errors.New(invalidJSONPayloadMsg)
 	}
 
+	clearServerFields(&todo)
+
 	return todo, nil
 }
 
+// clearServerFields сбрасывает поля, которыми управляет сервер: владельца, версию
+// (ожидаемая версия передается только через If-Match) и временные метки.
+func clearServerFields(todo *models.Todo) {
+	todo.Owner = ""
+	todo.Version = 0
+	todo.CreatedAt = time.Time{}
+	todo.UpdatedAt = time.Time{}
+	todo.CompletedAt = nil
+	todo.DeletedAt = nil
+}
+
+// etag возвращает сильный ETag задачи на основе ее версии.
+func etag(todo models.Todo) string {
+	return `"` + strconv.Itoa(todo.Version) + `"`
+}
+
+// parseIfMatch разбирает заголовок If-Match в список ожидаемых версий.
+// Пустой заголовок и "*" означают отсутствие условия (пустой список).
+// ok == false, если заголовок не может совпасть ни с одной версией
+// (все ETag списка слабые или некорректные).
+func parseIfMatch(header string) (versions []int, ok bool) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == etagAny {
+		return nil, true
+	}
+
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		// If-Match использует сильное сравнение, слабые ETag никогда не совпадают.
+		if strings.HasPrefix(candidate, etagWeakPrefix) {
+			continue
+		}
+
+		unquoted, err := strconv.Unquote(candidate)
+		if err != nil {
+			continue
+		}
+
+		version, err := strconv.Atoi(unquoted)
+		if err != nil || version <= 0 {
+			continue
+		}
+		versions = append(versions, version)
+	}
+
+	return versions, len(versions) > 0
+}
+
+// etagMatches проверяет заголовок If-None-Match по слабому сравнению с текущим ETag.
+func etagMatches(header, current string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == etagAny {
+			return true
+		}
+		if strings.TrimPrefix(candidate, etagWeakPrefix) == current {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parsePatchType определяет формат патча по заголовку Content-Type.
+func parsePatchType(contentType string) (models.PatchType, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", false
+	}
+
+	switch mediaType {
+	case contentTypeMergePatch:
+		return models.PatchMerge, true
+	case contentTypeJSONPatch:
+		return models.PatchJSON, true
+	default:
+		return "", false
+	}
+}
+
+// readBody читает тело запроса целиком, ограничивая его размер лимитом маршрута.
+func readBody(w http.ResponseWriter, req *http.Request) ([]byte, error) {
+	defer req.Body.Close()
+
+	return io.ReadAll(http.MaxBytesReader(w, req.Body, bodyLimit(req.URL.Path)))
+}
+
+// bodyLimit возвращает максимальный размер тела запроса к пути path: тот же, что
+// применяет обработчик маршрута, чтобы буферизация тела не отклоняла допустимые запросы.
+func bodyLimit(path string) int64 {
+	if path == batchPath {
+		return maxBatchBodyBytes
+	}
+
+	return maxBodyBytes
+}
+
+// writeBodyError отвечает на ошибку чтения тела запроса: 413 при превышении maxBodyBytes, иначе 400.
+func writeBodyError(w http.ResponseWriter, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		writeError(w, http.StatusRequestEntityTooLarge, maxBytesErr.Error())
+		return
+	}
+	writeError(w, http.StatusBadRequest, err.Error())
+}
+
 func writeServiceError(w http.ResponseWriter, err error) {
+	status, message := serviceErrorStatus(err)
+	writeError(w, status, message)
+}
+
+// serviceErrorStatus сопоставляет ошибку сервиса HTTP-статусу и тексту ответа.
+// Внутренние ошибки не раскрываются клиенту.
+func serviceErrorStatus(err error) (int, string) {
 	switch {
-	case errors.Is(err, models.ErrInvalidID), errors.Is(err, models.ErrEmptyTitle):
-		writeError(w, http.StatusBadRequest, err.Error())
-	case errors.Is(err, models.ErrDuplicateID):
-		writeError(w, http.StatusConflict, err.Error())
-	case errors.Is(err, models.ErrNotFound):
-		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, models.ErrInvalidID), errors.Is(err, models.ErrEmptyTitle),
+		errors.Is(err, models.ErrInvalidPriority), errors.Is(err, models.ErrInvalidReminder),
+		errors.Is(err, models.ErrInvalidTags),
+		errors.Is(err, models.ErrInvalidQuery), errors.Is(err, models.ErrInvalidPatch),
+		errors.Is(err, models.ErrInvalidBatch), errors.Is(err, models.ErrInvalidWebhook):
+		return http.StatusBadRequest, err.Error()
+	case errors.Is(err, models.ErrInvalidParent):
+		return http.StatusUnprocessableEntity, err.Error()
+	case errors.Is(err, models.ErrDuplicateID), errors.Is(err, models.ErrPatchTestFailed),
+		errors.Is(err, models.ErrHasChildren):
+		return http.StatusConflict, err.Error()
+	case errors.Is(err, models.ErrForbidden):
+		return http.StatusForbidden, err.Error()
+	case errors.Is(err, models.ErrNotFound), errors.Is(err, models.ErrWebhookNotFound),
+		errors.Is(err, models.ErrRevisionNotFound):
+		return http.StatusNotFound, err.Error()
+	case errors.Is(err, models.ErrVersionMismatch):
+		return http.StatusPreconditionFailed, err.Error()
+	case errors.Is(err, models.ErrBatchAborted):
+		return http.StatusFailedDependency, err.Error()
 	default:
-		writeError(w, http.StatusInternalServerError, internalServerErrorMsg)
+		return http.StatusInternalServerError, internalServerErrorMsg
 	}
 }
 
+// writeError пишет тело ошибки. Идентификатор запроса берется из заголовка ответа,
+// выставленного RequestIDMiddleware, чтобы клиент мог сослаться на него.
 func writeError(w http.ResponseWriter, status int, message string) {
-	writeJSON(w, status, map[string]string{jsonErrorKey: message})
+	body := map[string]string{jsonErrorKey: message}
+	if id := w.Header().Get(requestIDHeader); id != "" {
+		body[jsonRequestIDKey] = id
+	}
+
+	writeJSON(w, status, body)
 }
 
 func writeJSON(w http.ResponseWriter, status int, payload any) {
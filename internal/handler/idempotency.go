@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/RoGogDBD/ecom/internal/auth"
+	"github.com/RoGogDBD/ecom/internal/idempotency"
+	"github.com/RoGogDBD/ecom/internal/metrics"
+)
+
+const (
+	idempotencyKeyHeader     = "Idempotency-Key"
+	idempotentReplayedHeader = "Idempotent-Replayed"
+
+	invalidIdempotencyKeyMsg  = "invalid Idempotency-Key"
+	idempotencyKeyMismatchMsg = "Idempotency-Key was already used for a different request"
+
+	idempotencyResultExecuted = "executed"
+	idempotencyResultReplayed = "replayed"
+	idempotencyResultMismatch = "mismatch"
+)
+
+// IdempotencyMiddleware выполняет POST-запросы с заголовком Idempotency-Key не более одного раза.
+// Первый ответ (статус, заголовки обработчика и тело) сохраняется по ключу и пользователю,
+// повторы того же запроса получают его с заголовком Idempotent-Replayed: true. Повтор ключа
+// с другим методом, путем, If-Match или телом отклоняется с 422, одновременные запросы
+// с одним ключом выполняются по очереди. Ответы 5xx не сохраняются: такой запрос можно повторить.
+// Должен быть внутренним по отношению к AuthMiddleware, чтобы ключи разных пользователей не пересекались.
+func IdempotencyMiddleware(store *idempotency.Store, reg *metrics.Registry) Middleware {
+	requests := reg.NewCounter("http_idempotent_requests_total",
+		"Количество запросов с Idempotency-Key по результату: executed, replayed или mismatch.", "result")
+	reg.NewGaugeFunc("http_idempotency_keys", "Количество сохраненных ключей идемпотентности.", func() float64 {
+		return float64(store.Len())
+	})
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			key := req.Header.Get(idempotencyKeyHeader)
+			if req.Method != http.MethodPost || key == "" {
+				next.ServeHTTP(w, req)
+				return
+			}
+			if !idempotency.ValidKey(key) {
+				writeError(w, http.StatusBadRequest, invalidIdempotencyKeyMsg)
+				return
+			}
+
+			body, err := readBody(w, req)
+			if err != nil {
+				writeBodyError(w, err)
+				return
+			}
+			req.Body = io.NopCloser(bytes.NewReader(body))
+
+			principal, _ := auth.FromContext(req.Context())
+			resp, replayed, err := store.Do(req.Context(), storeKey(principal.Subject, key), fingerprint(req, body),
+				func() (idempotency.Response, bool) {
+					buffered := newBufferedResponse(w.Header())
+					next.ServeHTTP(buffered, req)
+
+					resp := buffered.response()
+					return resp, resp.Status < http.StatusInternalServerError
+				})
+			switch {
+			case errors.Is(err, idempotency.ErrMismatch):
+				requests.Inc(idempotencyResultMismatch)
+				writeError(w, http.StatusUnprocessableEntity, idempotencyKeyMismatchMsg)
+				return
+			case err != nil:
+				// Клиент отменил запрос, пока ждал завершения запроса с тем же ключом.
+				return
+			}
+
+			if replayed {
+				requests.Inc(idempotencyResultReplayed)
+				for name, values := range resp.Header {
+					w.Header()[name] = values
+				}
+				w.Header().Set(idempotentReplayedHeader, "true")
+			} else {
+				requests.Inc(idempotencyResultExecuted)
+			}
+
+			w.WriteHeader(resp.Status)
+			_, _ = w.Write(resp.Body)
+		})
+	}
+}
+
+// storeKey ключ хранилища для ключа идемпотентности пользователя. Длина имени пользователя
+// записывается перед ним: и имя, и ключ могут содержать любой разделитель, и без длины
+// разные пары (subject, key) давали бы одну и ту же строку.
+func storeKey(subject, key string) string {
+	return strconv.Itoa(len(subject)) + ":" + subject + key
+}
+
+// fingerprint отпечаток запроса: повтор с тем же ключом должен совпадать с ним
+// по методу, пути с параметрами, условию If-Match и телу.
+func fingerprint(req *http.Request, body []byte) string {
+	h := sha256.New()
+	_, _ = io.WriteString(h, req.Method+" "+req.URL.RequestURI()+"\n")
+	_, _ = io.WriteString(h, req.Header.Get(ifMatchHeader)+"\n")
+	_, _ = h.Write(body)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// bufferedResponse накапливает ответ обработчика, чтобы сохранить его до отправки клиенту.
+// Заголовки пишутся сразу в заголовки исходного ответа: так обработчик видит выставленные
+// снаружи (например, X-Request-ID), а сохраняются только добавленные им самим.
+type bufferedResponse struct {
+	header      http.Header
+	initial     http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func newBufferedResponse(header http.Header) *bufferedResponse {
+	return &bufferedResponse{
+		header:  header,
+		initial: header.Clone(),
+		status:  http.StatusOK,
+	}
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) WriteHeader(code int) {
+	if b.wroteHeader {
+		return
+	}
+	b.status = code
+	b.wroteHeader = true
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	b.wroteHeader = true
+	return b.body.Write(p)
+}
+
+// response возвращает накопленный ответ с заголовками, выставленными обработчиком.
+func (b *bufferedResponse) response() idempotency.Response {
+	header := make(http.Header)
+	for name, values := range b.header {
+		if initial, ok := b.initial[name]; !ok || !slices.Equal(initial, values) {
+			header[name] = slices.Clone(values)
+		}
+	}
+
+	return idempotency.Response{Status: b.status, Header: header, Body: b.body.Bytes()}
+}
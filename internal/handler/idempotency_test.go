@@ -0,0 +1,189 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RoGogDBD/ecom/internal/auth"
+	"github.com/RoGogDBD/ecom/internal/idempotency"
+	"github.com/RoGogDBD/ecom/internal/metrics"
+	"github.com/RoGogDBD/ecom/internal/models"
+)
+
+func TestStoreKey(t *testing.T) {
+	cases := []struct {
+		name string
+		a, b [2]string
+	}{
+		{name: "разделитель в имени и в ключе", a: [2]string{"a|b", "c"}, b: [2]string{"a", "b|c"}},
+		{name: "цифры и двоеточие", a: [2]string{"1:a", "b"}, b: [2]string{"1", ":ab"}},
+		{name: "пустое имя", a: [2]string{"", "ab"}, b: [2]string{"a", "b"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if storeKey(tc.a[0], tc.a[1]) == storeKey(tc.b[0], tc.b[1]) {
+				t.Fatalf("storeKey(%q, %q) совпадает с storeKey(%q, %q)", tc.a[0], tc.a[1], tc.b[0], tc.b[1])
+			}
+		})
+	}
+}
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	// step запрос клиента и ожидаемый ответ на него.
+	type step struct {
+		subject      string
+		key          string
+		body         string
+		wantStatus   int
+		wantBody     string
+		wantReplayed bool
+	}
+
+	cases := []struct {
+		name      string
+		steps     []step
+		wantCalls int
+	}{
+		{
+			name: "повтор получает сохраненный ответ",
+			steps: []step{
+				{subject: "alice", key: "k1", body: `{"title":"a"}`, wantStatus: http.StatusCreated, wantBody: "call 1"},
+				{subject: "alice", key: "k1", body: `{"title":"a"}`, wantStatus: http.StatusCreated, wantBody: "call 1", wantReplayed: true},
+			},
+			wantCalls: 1,
+		},
+		{
+			name: "другое тело с тем же ключом",
+			steps: []step{
+				{subject: "alice", key: "k1", body: `{"title":"a"}`, wantStatus: http.StatusCreated, wantBody: "call 1"},
+				{subject: "alice", key: "k1", body: `{"title":"b"}`, wantStatus: http.StatusUnprocessableEntity, wantBody: idempotencyKeyMismatchMsg},
+			},
+			wantCalls: 1,
+		},
+		{
+			name: "ключи разных пользователей не пересекаются",
+			steps: []step{
+				{subject: "alice", key: "k1", body: `{"title":"a"}`, wantStatus: http.StatusCreated, wantBody: "call 1"},
+				{subject: "bob", key: "k1", body: `{"title":"a"}`, wantStatus: http.StatusCreated, wantBody: "call 2"},
+			},
+			wantCalls: 2,
+		},
+		{
+			name: "разделитель в имени пользователя и ключе",
+			steps: []step{
+				{subject: "a|b", key: "c", body: `{"title":"a"}`, wantStatus: http.StatusCreated, wantBody: "call 1"},
+				{subject: "a", key: "b|c", body: `{"title":"a"}`, wantStatus: http.StatusCreated, wantBody: "call 2"},
+			},
+			wantCalls: 2,
+		},
+		{
+			name: "некорректный ключ",
+			steps: []step{
+				{subject: "alice", key: strings.Repeat("k", 256), body: `{}`, wantStatus: http.StatusBadRequest, wantBody: invalidIdempotencyKeyMsg},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := idempotency.New(time.Minute)
+			defer store.Close()
+
+			calls := 0
+			next := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				calls++
+				_, _ = io.Copy(io.Discard, req.Body)
+				w.WriteHeader(http.StatusCreated)
+				_, _ = io.WriteString(w, "call "+strconv.Itoa(calls))
+			})
+			h := IdempotencyMiddleware(store, metrics.NewRegistry())(next)
+
+			for i, s := range tc.steps {
+				req := httptest.NewRequest(http.MethodPost, "/todos", strings.NewReader(s.body))
+				req.Header.Set(idempotencyKeyHeader, s.key)
+				principal := auth.Principal{Subject: s.subject, Method: auth.MethodAPIKey}
+				req = req.WithContext(auth.NewContext(req.Context(), principal))
+				rec := httptest.NewRecorder()
+				h.ServeHTTP(rec, req)
+
+				if rec.Code != s.wantStatus {
+					t.Fatalf("запрос %d: ожидался статус %d, получено %d", i, s.wantStatus, rec.Code)
+				}
+				if !strings.Contains(rec.Body.String(), s.wantBody) {
+					t.Fatalf("запрос %d: тело %q не содержит %q", i, rec.Body.String(), s.wantBody)
+				}
+				if replayed := rec.Header().Get(idempotentReplayedHeader) == "true"; replayed != s.wantReplayed {
+					t.Fatalf("запрос %d: Idempotent-Replayed = %v, ожидалось %v", i, replayed, s.wantReplayed)
+				}
+			}
+			if calls != tc.wantCalls {
+				t.Fatalf("обработчик вызван %d раз, ожидалось %d", calls, tc.wantCalls)
+			}
+		})
+	}
+}
+
+func TestIdempotencyMiddlewareBodyLimit(t *testing.T) {
+	largeBatch := `[{"op":"create","todo":{"title":"` + strings.Repeat("a", 2*maxBodyBytes) + `"}}]`
+
+	cases := []struct {
+		name       string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{
+			name:       "пакет больше лимита одиночного запроса",
+			path:       batchPath,
+			body:       largeBatch,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "пакет больше лимита пакета",
+			path:       batchPath,
+			body:       `[{"op":"create","todo":{"title":"` + strings.Repeat("a", maxBatchBodyBytes) + `"}}]`,
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:       "задача больше лимита одиночного запроса",
+			path:       "/todos",
+			body:       `{"title":"` + strings.Repeat("a", maxBodyBytes) + `"}`,
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := idempotency.New(time.Minute)
+			defer store.Close()
+			service := &batchService{results: []models.BatchResult{{Todo: models.Todo{ID: 1}}}}
+			h := Conveyor(NewRouter(service), IdempotencyMiddleware(store, metrics.NewRegistry()))
+
+			for i, wantReplayed := range []bool{false, true} {
+				req := httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(tc.body))
+				req.Header.Set(idempotencyKeyHeader, "k1")
+				rec := httptest.NewRecorder()
+				h.ServeHTTP(rec, req)
+
+				if rec.Code != tc.wantStatus {
+					t.Fatalf("запрос %d: ожидался статус %d, получено %d", i, tc.wantStatus, rec.Code)
+				}
+				if tc.wantStatus != http.StatusOK {
+					return
+				}
+				if replayed := rec.Header().Get(idempotentReplayedHeader) == "true"; replayed != wantReplayed {
+					t.Fatalf("запрос %d: Idempotent-Replayed = %v, ожидалось %v", i, replayed, wantReplayed)
+				}
+			}
+			if service.calls != 1 {
+				t.Fatalf("сервис вызван %d раз, ожидался 1", service.calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RoGogDBD/ecom/internal/metrics"
+)
+
+const (
+	metricsPath = "/metrics"
+
+	// routeOther метка маршрута для запросов, не совпавших ни с одним шаблоном.
+	routeOther = "other"
+	// routeIDSegment заменяет числовые сегменты пути, чтобы /todos/1 и /todos/2
+	// попадали в одну серию и число серий не росло с числом задач.
+	routeIDSegment = "{id}"
+)
+
+// routeTemplates шаблоны путей, обслуживаемых маршрутизатором. Путь с другим шаблоном
+// получает метку routeOther: иначе произвольные пути создавали бы неограниченное число серий.
+var routeTemplates = map[string]struct{}{
+	"/todos":                         {},
+	batchPath:                        {},
+	eventsPath:                       {},
+	trashPath:                        {},
+	tagsPath:                         {},
+	todosPathPrefix + routeIDSegment: {},
+	todosPathPrefix + routeIDSegment + "/" + restoreSegment:                        {},
+	todosPathPrefix + routeIDSegment + "/" + childrenSegment:                       {},
+	todosPathPrefix + routeIDSegment + "/" + historySegment:                        {},
+	todosPathPrefix + routeIDSegment + "/" + historySegment + "/" + routeIDSegment: {},
+	todosPathPrefix + routeIDSegment + "/" + revertSegment + "/" + routeIDSegment:  {},
+	webhooksPath:                        {},
+	webhooksPathPrefix + routeIDSegment: {},
+	webhooksPathPrefix + routeIDSegment + deliveriesSuffix: {},
+	metricsPath: {},
+	healthzPath: {},
+	readyzPath:  {},
+}
+
+// sizeBuckets границы гистограммы размера ответа в байтах: от 64 Б до 4 МБ.
+var sizeBuckets = []float64{64, 256, 1024, 4096, 16384, 65536, 262144, 1048576, 4194304}
+
+// MetricsMiddleware учитывает запросы в reg: количество по маршруту, методу и статусу,
+// длительность и размер ответа. Маршрут определяется по шаблону пути из routeTemplates,
+// поэтому middleware не зависит от ServeMux и должен быть внешним по отношению к
+// аутентификации, ограничению частоты и RecoveryMiddleware, чтобы учитывать их ответы.
+func MetricsMiddleware(reg *metrics.Registry) Middleware {
+	requests := reg.NewCounter("http_requests_total",
+		"Количество HTTP-запросов.", "route", "method", "status")
+	duration := reg.NewHistogram("http_request_duration_seconds",
+		"Длительность обработки HTTP-запроса в секундах.", metrics.DefBuckets, "route", "method")
+	size := reg.NewHistogram("http_response_size_bytes",
+		"Размер тела HTTP-ответа в байтах.", sizeBuckets, "route", "method")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			start := time.Now()
+			wrapped := newResponseWriter(w)
+
+			next.ServeHTTP(wrapped, req)
+
+			route := routeTemplate(req.URL.Path)
+			requests.Inc(route, req.Method, strconv.Itoa(wrapped.statusCode))
+			duration.Observe(time.Since(start).Seconds(), route, req.Method)
+			size.Observe(float64(wrapped.size), route, req.Method)
+		})
+	}
+}
+
+// routeTemplate возвращает шаблон пути из routeTemplates, в котором числовые сегменты
+// заменены на {id}, или routeOther, если путь не обслуживается маршрутизатором.
+func routeTemplate(path string) string {
+	template := normalizePath(path)
+	if _, ok := routeTemplates[template]; !ok {
+		return routeOther
+	}
+
+	return template
+}
+
+// normalizePath заменяет числовые сегменты пути на {id}.
+func normalizePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if _, err := strconv.Atoi(segment); err == nil {
+			segments[i] = routeIDSegment
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/RoGogDBD/ecom/internal/auth"
+	"github.com/RoGogDBD/ecom/internal/logger"
+	"github.com/RoGogDBD/ecom/internal/metrics"
+)
+
+func TestRouteTemplate(t *testing.T) {
+	cases := []struct {
+		path string
+		want string
+	}{
+		{path: "/todos", want: "/todos"},
+		{path: "/todos/42", want: "/todos/{id}"},
+		{path: "/todos/42/history/3", want: "/todos/{id}/history/{id}"},
+		{path: "/todos/42/children", want: "/todos/{id}/children"},
+		{path: "/webhooks/7/deliveries", want: "/webhooks/{id}/deliveries"},
+		{path: "/healthz", want: "/healthz"},
+		{path: "/todos/junk", want: routeOther},
+		{path: "/todos/42/junk", want: routeOther},
+		{path: "/todos/99999999999999999999999", want: routeOther},
+		{path: "/unknown", want: routeOther},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.path, func(t *testing.T) {
+			if got := routeTemplate(tc.path); got != tc.want {
+				t.Fatalf("routeTemplate(%q) = %q, ожидалось %q", tc.path, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMetricsMiddlewareBoundedRoutes(t *testing.T) {
+	reg := metrics.NewRegistry()
+	h := Conveyor(NewRouter(nil), MetricsMiddleware(reg))
+
+	for _, path := range []string{"/todos/junk0", "/todos/junk1", "/todos/junk2", "/nowhere"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	var b strings.Builder
+	if _, err := reg.WriteTo(&b); err != nil {
+		t.Fatalf("WriteTo() ошибка = %v", err)
+	}
+	out := b.String()
+
+	if strings.Contains(out, "junk") {
+		t.Fatalf("метрики содержат путь запроса:\n%s", out)
+	}
+	want := `http_requests_total{route="other",method="GET",status="404"} 4`
+	if !strings.Contains(out, want) {
+		t.Fatalf("метрики не содержат %q:\n%s", want, out)
+	}
+}
+
+func TestMetricsMiddlewareOuterResponses(t *testing.T) {
+	var logs bytes.Buffer
+	log, err := logger.NewWithWriter(&logs, logger.Options{Format: logger.FormatJSON})
+	if err != nil {
+		t.Fatalf("NewWithWriter() ошибка = %v", err)
+	}
+	apiKey := auth.NewAPIKeyVerifier(testAPIKeyHeader, map[string]string{testAPIKey: "alice"})
+	panicking := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("сломалось")
+	})
+
+	reg := metrics.NewRegistry()
+	h := Conveyor(panicking,
+		AuthMiddleware(apiKey),
+		RecoveryMiddleware(log, reg),
+		MetricsMiddleware(reg),
+	)
+
+	unauthorized := httptest.NewRequest(http.MethodGet, "/todos/1", nil)
+	h.ServeHTTP(httptest.NewRecorder(), unauthorized)
+
+	recovered := httptest.NewRequest(http.MethodPost, "/todos", nil)
+	recovered.Header.Set(testAPIKeyHeader, testAPIKey)
+	h.ServeHTTP(httptest.NewRecorder(), recovered)
+
+	var b strings.Builder
+	if _, err := reg.WriteTo(&b); err != nil {
+		t.Fatalf("WriteTo() ошибка = %v", err)
+	}
+	out := b.String()
+
+	for _, want := range []string{
+		`http_requests_total{route="/todos/{id}",method="GET",status="401"} 1`,
+		`http_requests_total{route="/todos",method="POST",status="500"} 1`,
+		`http_request_duration_seconds_count{route="/todos",method="POST"} 1`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("метрики не содержат %q:\n%s", want, out)
+		}
+	}
+}
@@ -1,11 +1,15 @@
 package handler
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 	"time"
+
+	"github.com/RoGogDBD/ecom/internal/requestid"
 )
 
+const requestIDHeader = "X-Request-ID"
+
 type Middleware func(http.Handler) http.Handler
 
 // responseWriter оборачивает http.ResponseWriter для захвата статус-кода и размера ответа.
@@ -13,6 +17,8 @@ type responseWriter struct {
 	http.ResponseWriter
 	statusCode int
 	size       int
+	// wroteHeader сообщает, что заголовки уже отправлены и статус изменить нельзя.
+	wroteHeader bool
 }
 
 // newResponseWriter создает новый responseWriter с дефолтным статусом 200.
@@ -26,16 +32,24 @@ func newResponseWriter(w http.ResponseWriter) *responseWriter {
 // WriteHeader перехватывает статус-код.
 func (rw *responseWriter) WriteHeader(code int) {
 	rw.statusCode = code
+	rw.wroteHeader = true
 	rw.ResponseWriter.WriteHeader(code)
 }
 
 // Write перехватывает размер ответа.
 func (rw *responseWriter) Write(b []byte) (int, error) {
+	rw.wroteHeader = true
 	size, err := rw.ResponseWriter.Write(b)
 	rw.size += size
 	return size, err
 }
 
+// Unwrap возвращает исходный http.ResponseWriter, чтобы http.ResponseController
+// мог добраться до Flush и других возможностей соединения.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 func Conveyor(h http.Handler, middlewares ...Middleware) http.Handler {
 	for _, middleware := range middlewares {
 		h = middleware(h)
@@ -44,7 +58,27 @@ func Conveyor(h http.Handler, middlewares ...Middleware) http.Handler {
 	return h
 }
 
-func LoggingMiddleware(logger *log.Logger) Middleware {
+// RequestIDMiddleware присваивает запросу идентификатор: берет корректный X-Request-ID
+// клиента или генерирует новый. Идентификатор кладется в контекст запроса
+// и возвращается в заголовке ответа. Должен быть внешним по отношению к LoggingMiddleware.
+func RequestIDMiddleware() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			id := req.Header.Get(requestIDHeader)
+			if !requestid.Valid(id) {
+				id = requestid.New()
+			}
+
+			w.Header().Set(requestIDHeader, id)
+			next.ServeHTTP(w, req.WithContext(requestid.NewContext(req.Context(), id)))
+		})
+	}
+}
+
+// LoggingMiddleware пишет по одной структурированной записи на каждый запрос.
+// Уровень записи зависит от статуса: 5xx — error, 4xx — warn, остальные — info.
+// Идентификатор запроса добавляет логгер из контекста запроса.
+func LoggingMiddleware(logger *slog.Logger) Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 			start := time.Now()
@@ -52,16 +86,25 @@ func LoggingMiddleware(logger *log.Logger) Middleware {
 
 			next.ServeHTTP(wrapped, req)
 
-			duration := time.Since(start)
-			logger.Printf(
-				"%s %s %d %s %dB %s",
-				req.Method,
-				req.URL.Path,
-				wrapped.statusCode,
-				req.RemoteAddr,
-				wrapped.size,
-				duration,
+			logger.LogAttrs(req.Context(), statusLevel(wrapped.statusCode), "http request",
+				slog.String("method", req.Method),
+				slog.String("path", req.URL.Path),
+				slog.Int("status", wrapped.statusCode),
+				slog.Int("bytes", wrapped.size),
+				slog.Duration("duration", time.Since(start)),
+				slog.String("remote", req.RemoteAddr),
 			)
 		})
 	}
 }
+
+func statusLevel(status int) slog.Level {
+	switch {
+	case status >= http.StatusInternalServerError:
+		return slog.LevelError
+	case status >= http.StatusBadRequest:
+		return slog.LevelWarn
+	default:
+		return slog.LevelInfo
+	}
+}
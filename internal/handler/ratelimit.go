@@ -0,0 +1,200 @@
+package handler
+
+import (
+	"math"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RoGogDBD/ecom/internal/auth"
+	"github.com/RoGogDBD/ecom/internal/metrics"
+	"github.com/RoGogDBD/ecom/internal/ratelimit"
+)
+
+const (
+	forwardedForHeader       = "X-Forwarded-For"
+	retryAfterHeader         = "Retry-After"
+	rateLimitLimitHeader     = "RateLimit-Limit"
+	rateLimitRemainingHeader = "RateLimit-Remaining"
+	rateLimitResetHeader     = "RateLimit-Reset"
+
+	tooManyRequestsMsg = "too many requests"
+
+	clientPrincipalPrefix = "principal:"
+	clientIPPrefix        = "ip:"
+	// authFailurePrefix ключ корзины неудачных попыток аутентификации; не пересекается
+	// с ключами RateLimitMiddleware, которые начинаются с маршрута и "|".
+	authFailurePrefix = "auth-failures:"
+)
+
+// RateLimitOptions правила ограничения частоты запросов.
+type RateLimitOptions struct {
+	// Default лимит для маршрутов без собственного лимита. Нулевой — без ограничения.
+	Default ratelimit.Limit
+	// Routes лимиты маршрутов по ключу "METHOD /path" или "/path". Числовые сегменты
+	// пути записываются как {id}, например "PUT /todos/{id}". Ключ с методом важнее ключа без него.
+	// Пути, которые не обслуживает маршрутизатор, учитываются как "other".
+	Routes map[string]ratelimit.Limit
+	// TrustedProxies адреса прокси, которым доверяется X-Forwarded-For.
+	TrustedProxies []netip.Prefix
+}
+
+// RateLimitMiddleware ограничивает частоту запросов каждого клиента к маршруту.
+// Каждый маршрут с собственным лимитом имеет отдельную корзину; маршруты с лимитом
+// по умолчанию делят одну. Ответы дополняются заголовками RateLimit-*, при превышении
+// лимита возвращается 429 с Retry-After.
+func RateLimitMiddleware(limiter *ratelimit.Limiter, opts RateLimitOptions, reg *metrics.Registry) Middleware {
+	limited := reg.NewCounter("http_rate_limited_total",
+		"Количество запросов, отклоненных ограничением частоты.", "route")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			route := routeTemplate(req.URL.Path)
+			// Проверки состояния не ограничиваются: иначе частые пробы балансировщика
+			// могли бы исчерпать лимит и вывести сервер из ротации.
+			if route == healthzPath || route == readyzPath {
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			limitKey, limit := opts.limitFor(req.Method, route)
+			if limit.Unlimited() {
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			decision := limiter.Allow(limitKey+"|"+opts.clientKey(req), limit)
+			setRateLimitHeaders(w.Header(), limit, decision)
+			if !decision.Allowed {
+				limited.Inc(route)
+				tooManyRequests(w, decision)
+				return
+			}
+
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+// AuthFailureLimitMiddleware ограничивает частоту неудачных попыток аутентификации с одного
+// IP-адреса, чтобы нельзя было перебирать ключи API и токены. Должен быть внешним по отношению
+// к AuthMiddleware: отклоненные им запросы не доходят до RateLimitMiddleware. Каждый запрос
+// занимает токен корзины адреса и возвращает его, если ответ не 401, поэтому успешные запросы
+// лимит не расходуют, а одновременных попыток не может быть больше limit.Burst.
+func AuthFailureLimitMiddleware(limiter *ratelimit.Limiter, limit ratelimit.Limit, trusted []netip.Prefix, reg *metrics.Registry) Middleware {
+	limited := reg.NewCounter("http_auth_rate_limited_total",
+		"Количество запросов, отклоненных ограничением неудачных попыток аутентификации.")
+
+	return func(next http.Handler) http.Handler {
+		if limit.Unlimited() {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Path == healthzPath || req.URL.Path == readyzPath {
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			key := authFailurePrefix + clientIP(req, trusted)
+			// Заголовки RateLimit-* описывают лимит маршрута, поэтому выставляются только при отказе.
+			if decision := limiter.Allow(key, limit); !decision.Allowed {
+				limited.Inc()
+				setRateLimitHeaders(w.Header(), limit, decision)
+				tooManyRequests(w, decision)
+				return
+			}
+
+			wrapped := newResponseWriter(w)
+			next.ServeHTTP(wrapped, req)
+			if wrapped.statusCode != http.StatusUnauthorized {
+				limiter.Refund(key, limit)
+			}
+		})
+	}
+}
+
+func setRateLimitHeaders(header http.Header, limit ratelimit.Limit, decision ratelimit.Decision) {
+	header.Set(rateLimitLimitHeader, strconv.Itoa(max(limit.Burst, 1)))
+	header.Set(rateLimitRemainingHeader, strconv.Itoa(decision.Remaining))
+	header.Set(rateLimitResetHeader, strconv.Itoa(ceilSeconds(decision.Reset)))
+}
+
+// tooManyRequests отвечает 429 с Retry-After на запрос, отклоненный решением decision.
+func tooManyRequests(w http.ResponseWriter, decision ratelimit.Decision) {
+	w.Header().Set(retryAfterHeader, strconv.Itoa(max(ceilSeconds(decision.RetryAfter), 1)))
+	writeError(w, http.StatusTooManyRequests, tooManyRequestsMsg)
+}
+
+// limitFor возвращает ключ и лимит маршрута; для маршрутов без собственного лимита ключ пустой.
+func (o RateLimitOptions) limitFor(method, route string) (string, ratelimit.Limit) {
+	key := method + " " + route
+	if limit, ok := o.Routes[key]; ok {
+		return key, limit
+	}
+	if limit, ok := o.Routes[route]; ok {
+		return route, limit
+	}
+
+	return "", o.Default
+}
+
+// clientKey определяет клиента: по аутентифицированному пользователю, если
+// AuthMiddleware уже проверил запрос, иначе по IP-адресу. Непроверенные учетные данные
+// не учитываются: клиент мог бы обходить лимит, присылая новый ключ в каждом запросе.
+func (o RateLimitOptions) clientKey(req *http.Request) string {
+	if principal, ok := auth.FromContext(req.Context()); ok {
+		return clientPrincipalPrefix + principal.Subject
+	}
+
+	return clientIPPrefix + clientIP(req, o.TrustedProxies)
+}
+
+// clientIP возвращает адрес клиента. X-Forwarded-For учитывается, только если запрос
+// пришел от доверенного прокси: список просматривается справа налево, и клиентом считается
+// первый адрес, не принадлежащий доверенным прокси. Левые элементы списка клиент может подделать.
+func clientIP(req *http.Request, trusted []netip.Prefix) string {
+	remote, err := netip.ParseAddrPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+
+	addr := remote.Addr().Unmap()
+	if !isTrusted(addr, trusted) {
+		return addr.String()
+	}
+
+	var hops []string
+	for _, value := range req.Header.Values(forwardedForHeader) {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		addr = hop.Unmap()
+		if !isTrusted(addr, trusted) {
+			break
+		}
+	}
+
+	return addr.String()
+}
+
+func isTrusted(addr netip.Addr, trusted []netip.Prefix) bool {
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RoGogDBD/ecom/internal/auth"
+	"github.com/RoGogDBD/ecom/internal/metrics"
+	"github.com/RoGogDBD/ecom/internal/ratelimit"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	cases := []struct {
+		name string
+		// prepare дополняет i-й запрос клиента.
+		prepare    func(req *http.Request, i int) *http.Request
+		wantStatus []int
+	}{
+		{
+			name:       "лимит по адресу",
+			prepare:    func(req *http.Request, _ int) *http.Request { return req },
+			wantStatus: []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name: "новый ключ API в каждом запросе не обходит лимит",
+			prepare: func(req *http.Request, i int) *http.Request {
+				req.Header.Set("X-API-Key", "random-"+strconv.Itoa(i))
+				return req
+			},
+			wantStatus: []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name: "у пользователей отдельные корзины",
+			prepare: func(req *http.Request, i int) *http.Request {
+				principal := auth.Principal{Subject: "user-" + strconv.Itoa(i), Method: auth.MethodAPIKey}
+				return req.WithContext(auth.NewContext(req.Context(), principal))
+			},
+			wantStatus: []int{http.StatusOK, http.StatusOK, http.StatusOK},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			limiter := ratelimit.New(time.Minute)
+			defer limiter.Close()
+			h := RateLimitMiddleware(limiter, RateLimitOptions{
+				Default: ratelimit.Limit{Rate: 0.001, Burst: 2},
+			}, metrics.NewRegistry())(ok)
+
+			for i, want := range tc.wantStatus {
+				req := tc.prepare(httptest.NewRequest(http.MethodGet, "/todos", nil), i)
+				rec := httptest.NewRecorder()
+				h.ServeHTTP(rec, req)
+				if rec.Code != want {
+					t.Fatalf("запрос %d: ожидался статус %d, получено %d", i, want, rec.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimitMiddlewareRouteLabel(t *testing.T) {
+	reg := metrics.NewRegistry()
+	limiter := ratelimit.New(time.Minute)
+	defer limiter.Close()
+	h := RateLimitMiddleware(limiter, RateLimitOptions{
+		Default: ratelimit.Limit{Rate: 0.001, Burst: 1},
+	}, reg)(http.NotFoundHandler())
+
+	for _, path := range []string{"/todos/junk0", "/todos/junk1", "/todos/junk2"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	var b strings.Builder
+	if _, err := reg.WriteTo(&b); err != nil {
+		t.Fatalf("WriteTo() ошибка = %v", err)
+	}
+	out := b.String()
+	if strings.Contains(out, "junk") {
+		t.Fatalf("метрики содержат путь запроса:\n%s", out)
+	}
+	want := `http_rate_limited_total{route="other"} 2`
+	if !strings.Contains(out, want) {
+		t.Fatalf("метрики не содержат %q:\n%s", want, out)
+	}
+}
+
+func TestAuthFailureLimitMiddleware(t *testing.T) {
+	apiKey := auth.NewAPIKeyVerifier(testAPIKeyHeader, map[string]string{testAPIKey: "alice"})
+	ok := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	type step struct {
+		remote     string
+		key        string
+		path       string
+		wantStatus int
+	}
+	const attacker, other = "192.0.2.1:1234", "192.0.2.2:1234"
+
+	cases := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "перебор ключей упирается в лимит",
+			steps: []step{
+				{remote: attacker, key: "guess-1", wantStatus: http.StatusUnauthorized},
+				{remote: attacker, key: "guess-2", wantStatus: http.StatusUnauthorized},
+				{remote: attacker, wantStatus: http.StatusUnauthorized},
+				{remote: attacker, key: "guess-3", wantStatus: http.StatusTooManyRequests},
+				{remote: attacker, key: testAPIKey, wantStatus: http.StatusTooManyRequests},
+				{remote: other, key: "guess-1", wantStatus: http.StatusUnauthorized},
+				{remote: attacker, path: healthzPath, wantStatus: http.StatusOK},
+			},
+		},
+		{
+			name: "успешные запросы не расходуют лимит",
+			steps: []step{
+				{remote: attacker, key: testAPIKey, wantStatus: http.StatusOK},
+				{remote: attacker, key: testAPIKey, wantStatus: http.StatusOK},
+				{remote: attacker, key: testAPIKey, wantStatus: http.StatusOK},
+				{remote: attacker, key: testAPIKey, wantStatus: http.StatusOK},
+				{remote: attacker, key: "guess-1", wantStatus: http.StatusUnauthorized},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			reg := metrics.NewRegistry()
+			limiter := ratelimit.New(time.Minute)
+			defer limiter.Close()
+			h := Conveyor(ok,
+				AuthMiddleware(apiKey),
+				AuthFailureLimitMiddleware(limiter, ratelimit.Limit{Rate: 0.001, Burst: 3}, nil, reg),
+			)
+
+			for i, s := range tc.steps {
+				path := s.path
+				if path == "" {
+					path = "/todos"
+				}
+				req := httptest.NewRequest(http.MethodGet, path, nil)
+				req.RemoteAddr = s.remote
+				if s.key != "" {
+					req.Header.Set(testAPIKeyHeader, s.key)
+				}
+				rec := httptest.NewRecorder()
+				h.ServeHTTP(rec, req)
+
+				if rec.Code != s.wantStatus {
+					t.Fatalf("запрос %d: ожидался статус %d, получено %d", i, s.wantStatus, rec.Code)
+				}
+				if s.wantStatus == http.StatusTooManyRequests && rec.Header().Get(retryAfterHeader) == "" {
+					t.Fatalf("запрос %d: ответ 429 без %s", i, retryAfterHeader)
+				}
+				if s.wantStatus == http.StatusOK && rec.Header().Get(rateLimitRemainingHeader) != "" {
+					t.Fatalf("запрос %d: успешный ответ содержит заголовки лимита попыток", i)
+				}
+			}
+		})
+	}
+}
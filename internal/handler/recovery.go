@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/RoGogDBD/ecom/internal/metrics"
+)
+
+const logPanicRecovered = "panic recovered"
+
+// RecoveryMiddleware перехватывает панику обработчика или middleware внутри него: пишет в лог
+// значение паники и стек вызовов, отвечает 500 со стандартным телом ошибки и увеличивает
+// счетчик паник. Должен быть внешним по отношению к остальным middleware, чтобы перехватывать
+// и их паники, но внутренним по отношению к RequestIDMiddleware, LoggingMiddleware и
+// MetricsMiddleware, чтобы ответ 500 попадал в журнал запросов и метрики с идентификатором запроса.
+func RecoveryMiddleware(logger *slog.Logger, reg *metrics.Registry) Middleware {
+	panics := reg.NewCounter("http_panics_total", "Количество паник в обработчиках HTTP.")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			wrapped := newResponseWriter(w)
+
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+				// ErrAbortHandler — штатный способ прервать ответ, его обрабатывает сам net/http.
+				if err, ok := recovered.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+					panic(recovered)
+				}
+
+				panics.Inc()
+				logger.LogAttrs(req.Context(), slog.LevelError, logPanicRecovered,
+					slog.String("method", req.Method),
+					slog.String("path", req.URL.Path),
+					slog.String("panic", fmt.Sprint(recovered)),
+					slog.String("stack", string(debug.Stack())),
+				)
+
+				// Если заголовки уже отправлены, корректный ответ сформировать нельзя:
+				// прерываем соединение, чтобы клиент не принял обрезанный ответ за полный.
+				if wrapped.wroteHeader {
+					panic(http.ErrAbortHandler)
+				}
+				writeError(wrapped, http.StatusInternalServerError, internalServerErrorMsg)
+			}()
+
+			next.ServeHTTP(wrapped, req)
+		})
+	}
+}
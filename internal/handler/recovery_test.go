@@ -0,0 +1,195 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/RoGogDBD/ecom/internal/logger"
+	"github.com/RoGogDBD/ecom/internal/metrics"
+)
+
+func TestRecoveryMiddleware(t *testing.T) {
+	panicking := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("сломалось")
+	})
+	// panicMiddleware паникует до вызова следующего обработчика, как сбойный middleware.
+	panicMiddleware := func(http.Handler) http.Handler { return panicking }
+	ok := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	cases := []struct {
+		name       string
+		handler    func(recovery Middleware) http.Handler
+		wantStatus int
+		wantPanics int
+		wantLogged bool
+	}{
+		{
+			name: "паника в обработчике",
+			handler: func(recovery Middleware) http.Handler {
+				return Conveyor(panicking, MetricsMiddleware(metrics.NewRegistry()), recovery, RequestIDMiddleware())
+			},
+			wantStatus: http.StatusInternalServerError,
+			wantPanics: 1,
+			wantLogged: true,
+		},
+		{
+			name: "паника во внутреннем middleware",
+			handler: func(recovery Middleware) http.Handler {
+				return Conveyor(ok, panicMiddleware, recovery, RequestIDMiddleware())
+			},
+			wantStatus: http.StatusInternalServerError,
+			wantPanics: 1,
+			wantLogged: true,
+		},
+		{
+			name: "без паники",
+			handler: func(recovery Middleware) http.Handler {
+				return Conveyor(ok, recovery, RequestIDMiddleware())
+			},
+			wantStatus: http.StatusNoContent,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			log, err := logger.NewWithWriter(&logs, logger.Options{Format: logger.FormatJSON})
+			if err != nil {
+				t.Fatalf("NewWithWriter() ошибка = %v", err)
+			}
+			reg := metrics.NewRegistry()
+			h := tc.handler(RecoveryMiddleware(log, reg))
+
+			req := httptest.NewRequest(http.MethodGet, "/todos", nil)
+			req.Header.Set(requestIDHeader, "req-1")
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("ожидался статус %d, получено %d", tc.wantStatus, rec.Code)
+			}
+			if tc.wantStatus == http.StatusInternalServerError {
+				var body map[string]string
+				if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+					t.Fatalf("некорректное тело ответа %q: %v", rec.Body.String(), err)
+				}
+				if body[jsonErrorKey] != internalServerErrorMsg || body[jsonRequestIDKey] != "req-1" {
+					t.Fatalf("неожиданное тело ответа: %v", body)
+				}
+			}
+
+			var out strings.Builder
+			if _, err := reg.WriteTo(&out); err != nil {
+				t.Fatalf("WriteTo() ошибка = %v", err)
+			}
+			// Счетчик без значений не выводится, поэтому при отсутствии паник ищем только строку с именем.
+			sample := "\nhttp_panics_total "
+			if got := strings.Contains(out.String(), sample+strconv.Itoa(tc.wantPanics)+"\n"); tc.wantPanics > 0 && !got {
+				t.Fatalf("ожидалось http_panics_total %d:\n%s", tc.wantPanics, out.String())
+			}
+			if tc.wantPanics == 0 && strings.Contains(out.String(), sample) {
+				t.Fatalf("ожидалось отсутствие паник:\n%s", out.String())
+			}
+
+			if !tc.wantLogged {
+				if logs.Len() != 0 {
+					t.Fatalf("ожидался пустой лог, получено %q", logs.String())
+				}
+				return
+			}
+			var entry map[string]any
+			if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+				t.Fatalf("некорректная запись лога %q: %v", logs.String(), err)
+			}
+			if entry["msg"] != logPanicRecovered || entry["panic"] != "сломалось" || entry[logger.RequestIDKey] != "req-1" {
+				t.Fatalf("неожиданная запись лога: %v", entry)
+			}
+			if stack, _ := entry["stack"].(string); !strings.Contains(stack, "goroutine") {
+				t.Fatalf("запись лога не содержит стек: %v", entry)
+			}
+		})
+	}
+}
+
+func TestRecoveryMiddlewareAccessLog(t *testing.T) {
+	panicking := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("сломалось")
+	})
+
+	var logs bytes.Buffer
+	log, err := logger.NewWithWriter(&logs, logger.Options{Format: logger.FormatJSON})
+	if err != nil {
+		t.Fatalf("NewWithWriter() ошибка = %v", err)
+	}
+	h := Conveyor(panicking, RecoveryMiddleware(log, metrics.NewRegistry()), LoggingMiddleware(log), RequestIDMiddleware())
+
+	req := httptest.NewRequest(http.MethodGet, "/todos", nil)
+	req.Header.Set(requestIDHeader, "req-1")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	// Восстановленная паника попадает в журнал запросов, как любой другой ответ.
+	var access map[string]any
+	for line := range strings.Lines(logs.String()) {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("некорректная запись лога %q: %v", line, err)
+		}
+		if entry["msg"] == "http request" {
+			access = entry
+		}
+	}
+	if access == nil {
+		t.Fatalf("нет записи о запросе:\n%s", logs.String())
+	}
+	if access["status"] != float64(http.StatusInternalServerError) || access[logger.RequestIDKey] != "req-1" {
+		t.Fatalf("неожиданная запись о запросе: %v", access)
+	}
+	if _, ok := access["duration"]; !ok {
+		t.Fatalf("запись о запросе не содержит длительность: %v", access)
+	}
+}
+
+func TestRecoveryMiddlewareAbort(t *testing.T) {
+	cases := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{
+			name: "ErrAbortHandler передается net/http",
+			handler: func(http.ResponseWriter, *http.Request) {
+				panic(http.ErrAbortHandler)
+			},
+		},
+		{
+			name: "паника после отправки заголовков прерывает ответ",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				panic("сломалось")
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			log, _ := logger.NewWithWriter(&logs, logger.Options{Format: logger.FormatJSON})
+			h := RecoveryMiddleware(log, metrics.NewRegistry())(tc.handler)
+
+			defer func() {
+				recovered := recover()
+				if err, ok := recovered.(error); !ok || !errors.Is(err, http.ErrAbortHandler) {
+					t.Fatalf("ожидалась паника %v, получено %v", http.ErrAbortHandler, recovered)
+				}
+			}()
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/todos", nil))
+		})
+	}
+}
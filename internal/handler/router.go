@@ -4,30 +4,61 @@ import (
 	"context"
 	"net/http"
 
+	"github.com/RoGogDBD/ecom/internal/events"
 	"github.com/RoGogDBD/ecom/internal/models"
 )
 
 type (
 	TodoService interface {
-		Create(ctx context.Context, todo models.Todo) error
-		Update(ctx context.Context, todo models.Todo) error
-		Delete(ctx context.Context, id int) error
-		GetAll(ctx context.Context) ([]models.Todo, error)
+		Create(ctx context.Context, todo models.Todo) (models.Todo, error)
+		Update(ctx context.Context, todo models.Todo) (models.Todo, error)
+		Patch(ctx context.Context, id, version int, patchType models.PatchType, patch []byte) (models.Todo, error)
+		Delete(ctx context.Context, id, version int, children models.ChildrenMode) error
+		HardDelete(ctx context.Context, id, version int, children models.ChildrenMode) error
+		Restore(ctx context.Context, id, version int) (models.Todo, error)
+		ListTrash(ctx context.Context) ([]models.Todo, error)
+		Tags(ctx context.Context) ([]models.TagCount, error)
+		History(ctx context.Context, id int) ([]models.Revision, error)
+		Children(ctx context.Context, id int) ([]models.Todo, error)
+		Expand(ctx context.Context, todos []models.Todo) ([]models.TodoTree, error)
+		Revision(ctx context.Context, id, rev, from int) (models.Revision, error)
+		Revert(ctx context.Context, id, rev, version int) (models.Todo, error)
+		Batch(ctx context.Context, ops []models.BatchOperation, atomic bool) ([]models.BatchResult, error)
+		List(ctx context.Context, query models.TodoQuery) (models.TodoPage, error)
 		GetByID(ctx context.Context, id int) (models.Todo, error)
+		Subscribe(ctx context.Context, lastEventID uint64) (*events.Subscription, error)
 	}
 
 	Router struct {
 		service TodoService
 	}
+
+	// Option настраивает маршрутизатор.
+	Option func(mux *http.ServeMux)
 )
 
-func NewRouter(service TodoService) http.Handler {
+// WithMetrics публикует метрики по адресу /metrics.
+func WithMetrics(h http.Handler) Option {
+	return func(mux *http.ServeMux) {
+		mux.Handle(metricsPath, h)
+	}
+}
+
+func NewRouter(service TodoService, opts ...Option) http.Handler {
 	r := &Router{service: service}
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("/todos", r.handleTodos)
+	mux.HandleFunc(batchPath, r.handleBatch)
 	mux.HandleFunc("/todos/", r.handleTodoByID)
+	mux.HandleFunc(eventsPath, r.handleEvents)
+	mux.HandleFunc(trashPath, r.handleTrash)
+	mux.HandleFunc(tagsPath, r.handleTags)
 	// mux.HandleFunc("/swagger.json", swaggerHandler)
 
+	for _, opt := range opts {
+		opt(mux)
+	}
+
 	return mux
 }
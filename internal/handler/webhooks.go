@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RoGogDBD/ecom/internal/models"
+)
+
+const (
+	webhooksPath       = "/webhooks"
+	webhooksPathPrefix = "/webhooks/"
+	deliveriesSuffix   = "/deliveries"
+
+	webhookNotFoundMessage = "webhook not found"
+)
+
+type (
+	// WebhookService управляет подписками на webhook пользователя из контекста.
+	WebhookService interface {
+		Create(ctx context.Context, webhook models.Webhook) (models.Webhook, error)
+		Update(ctx context.Context, webhook models.Webhook) (models.Webhook, error)
+		Delete(ctx context.Context, id int) error
+		List(ctx context.Context) ([]models.Webhook, error)
+		GetByID(ctx context.Context, id int) (models.Webhook, error)
+		Deliveries(ctx context.Context, id int) ([]models.WebhookDelivery, error)
+	}
+
+	webhookHandler struct {
+		service WebhookService
+	}
+)
+
+// WithWebhooks публикует управление подписками на webhook по адресу /webhooks.
+func WithWebhooks(service WebhookService) Option {
+	return func(mux *http.ServeMux) {
+		h := &webhookHandler{service: service}
+		mux.HandleFunc(webhooksPath, h.handleWebhooks)
+		mux.HandleFunc(webhooksPathPrefix, h.handleWebhookByID)
+	}
+}
+
+func (h *webhookHandler) handleWebhooks(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		webhooks, err := h.service.List(req.Context())
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, webhooks)
+	case http.MethodPost:
+		webhook, err := decodeWebhook(w, req)
+		if err != nil {
+			writeBodyError(w, err)
+			return
+		}
+
+		created, err := h.service.Create(req.Context(), webhook)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		w.Header().Set(locationHeader, webhooksPathPrefix+strconv.Itoa(created.ID))
+		writeJSON(w, http.StatusCreated, created)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// handleWebhookByID обслуживает /webhooks/{id} и журнал доставок /webhooks/{id}/deliveries.
+func (h *webhookHandler) handleWebhookByID(w http.ResponseWriter, req *http.Request) {
+	rest := strings.TrimPrefix(req.URL.Path, webhooksPathPrefix)
+	rest, deliveries := strings.CutSuffix(rest, deliveriesSuffix)
+
+	id, err := strconv.Atoi(rest)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusNotFound, webhookNotFoundMessage)
+		return
+	}
+
+	if deliveries {
+		if req.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		h.handleDeliveries(w, req, id)
+		return
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		webhook, err := h.service.GetByID(req.Context(), id)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, webhook)
+	case http.MethodPut:
+		webhook, err := decodeWebhook(w, req)
+		if err != nil {
+			writeBodyError(w, err)
+			return
+		}
+		webhook.ID = id
+
+		updated, err := h.service.Update(req.Context(), webhook)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, updated)
+	case http.MethodDelete:
+		if err := h.service.Delete(req.Context(), id); err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *webhookHandler) handleDeliveries(w http.ResponseWriter, req *http.Request, id int) {
+	deliveries, err := h.service.Deliveries(req.Context(), id)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, deliveries)
+}
+
+// decodeWebhook читает подписку из тела запроса, ограниченного maxBodyBytes.
+func decodeWebhook(w http.ResponseWriter, req *http.Request) (models.Webhook, error) {
+	defer req.Body.Close()
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxBodyBytes))
+	dec.DisallowUnknownFields()
+
+	var webhook models.Webhook
+	if err := dec.Decode(&webhook); err != nil {
+		return models.Webhook{}, err
+	}
+
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		return models.Webhook{}, errors.New(invalidJSONPayloadMsg)
+	}
+
+	// Идентификатор, владельца и временные метки назначает сервер.
+	webhook.ID = 0
+	webhook.Owner = ""
+	webhook.CreatedAt = time.Time{}
+	webhook.UpdatedAt = time.Time{}
+
+	return webhook, nil
+}
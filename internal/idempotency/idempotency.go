@@ -0,0 +1,202 @@
+// Package idempotency запоминает результаты запросов по ключу идемпотентности,
+// чтобы повтор запроса с тем же ключом получил сохраненный ответ, а не выполнился заново.
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxKeyLength максимальная длина ключа идемпотентности.
+const maxKeyLength = 255
+
+// ErrMismatch ключ уже использован для запроса с другим содержимым.
+var ErrMismatch = errors.New("idempotency key reused with a different request")
+
+type (
+	// Response сохраненный ответ на запрос.
+	Response struct {
+		Status int
+		// Header заголовки, выставленные обработчиком запроса.
+		Header http.Header
+		Body   []byte
+	}
+
+	// Store хранит ответы по ключам. Запросы с одним ключом выполняются по очереди:
+	// пока первый не завершился, остальные ждут его ответа. Сохраненные ответы
+	// удаляются в фоне через ttl после сохранения.
+	Store struct {
+		ttl time.Duration
+		now func() time.Time
+
+		mu      sync.Mutex
+		entries map[string]*entry
+
+		stop      chan struct{}
+		done      chan struct{}
+		closeOnce sync.Once
+	}
+
+	entry struct {
+		// fingerprint отпечаток запроса, для которого занят ключ.
+		fingerprint string
+		// done закрывается, когда выполнение запроса завершено.
+		done chan struct{}
+		// response сохраненный ответ; nil, пока запрос выполняется.
+		response *Response
+		expires  time.Time
+	}
+)
+
+// ValidKey проверяет ключ: от 1 до 255 видимых символов ASCII.
+func ValidKey(key string) bool {
+	if key == "" || len(key) > maxKeyLength {
+		return false
+	}
+
+	for i := 0; i < len(key); i++ {
+		if key[i] < '!' || key[i] > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// New создает Store с временем хранения ответов ttl и запускает фоновое удаление устаревших.
+func New(ttl time.Duration) *Store {
+	s := newStore(ttl, time.Now)
+
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.evictLoop()
+
+	return s
+}
+
+func newStore(ttl time.Duration, now func() time.Time) *Store {
+	return &Store{
+		ttl:     ttl,
+		now:     now,
+		entries: make(map[string]*entry),
+	}
+}
+
+// Do выполняет fn для первого запроса с ключом key и возвращает сохраненный ответ
+// для повторов с тем же fingerprint; replayed сообщает, что ответ взят из сохраненных.
+// Пока fn выполняется, повторы ждут ее завершения или отмены ctx. fn возвращает ответ
+// и признак, сохранять ли его: несохраненный ответ освобождает ключ, и следующий
+// повтор выполнится заново. Повтор с другим fingerprint получает ErrMismatch.
+func (s *Store) Do(ctx context.Context, key, fingerprint string, fn func() (Response, bool)) (resp Response, replayed bool, err error) {
+	for {
+		s.mu.Lock()
+		e, ok := s.entries[key]
+		if ok && e.response != nil && !s.now().Before(e.expires) {
+			delete(s.entries, key)
+			ok = false
+		}
+
+		if !ok {
+			e = &entry{fingerprint: fingerprint, done: make(chan struct{})}
+			s.entries[key] = e
+			s.mu.Unlock()
+
+			return s.run(key, e, fn), false, nil
+		}
+
+		if e.fingerprint != fingerprint {
+			s.mu.Unlock()
+			return Response{}, false, ErrMismatch
+		}
+		if e.response != nil {
+			resp := *e.response
+			s.mu.Unlock()
+			return resp, true, nil
+		}
+
+		done := e.done
+		s.mu.Unlock()
+
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return Response{}, false, ctx.Err()
+		}
+	}
+}
+
+// run выполняет fn и сохраняет ее ответ либо освобождает ключ. Ключ освобождается
+// и при панике в fn, чтобы ожидающие повторы не зависли.
+func (s *Store) run(key string, e *entry, fn func() (Response, bool)) Response {
+	var (
+		resp Response
+		keep bool
+	)
+	defer func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if keep {
+			e.response = &resp
+			e.expires = s.now().Add(s.ttl)
+		} else {
+			delete(s.entries, key)
+		}
+		close(e.done)
+	}()
+
+	resp, keep = fn()
+	return resp
+}
+
+// Len возвращает количество занятых ключей.
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.entries)
+}
+
+// Close останавливает фоновое удаление ответов.
+func (s *Store) Close() {
+	s.closeOnce.Do(func() {
+		if s.stop != nil {
+			close(s.stop)
+			<-s.done
+		}
+	})
+}
+
+func (s *Store) evictLoop() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(max(s.ttl/2, time.Second))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.evict()
+		}
+	}
+}
+
+// evict удаляет сохраненные ответы с истекшим сроком хранения.
+// Ключи выполняющихся запросов не трогает.
+func (s *Store) evict() {
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, e := range s.entries {
+		if e.response != nil && !now.Before(e.expires) {
+			delete(s.entries, key)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestStore_Do(t *testing.T) {
+	clock := &testClock{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	s := newStore(time.Hour, clock.Now)
+	ctx := context.Background()
+
+	calls := 0
+	created := func() (Response, bool) {
+		calls++
+		return Response{Status: 201, Body: []byte(`{"id":1}`)}, true
+	}
+
+	steps := []struct {
+		name         string
+		advance      time.Duration
+		fingerprint  string
+		wantErr      error
+		wantReplayed bool
+		wantCalls    int
+	}{
+		{name: "первый запрос", fingerprint: "a", wantCalls: 1},
+		{name: "повтор", fingerprint: "a", wantReplayed: true, wantCalls: 1},
+		{name: "другое тело", fingerprint: "b", wantErr: ErrMismatch, wantCalls: 1},
+		{name: "повтор до истечения срока", advance: 59 * time.Minute, fingerprint: "a", wantReplayed: true, wantCalls: 1},
+		{name: "срок истек", advance: time.Minute, fingerprint: "b", wantCalls: 2},
+	}
+
+	for _, step := range steps {
+		clock.Advance(step.advance)
+		resp, replayed, err := s.Do(ctx, "key", step.fingerprint, created)
+		if !errors.Is(err, step.wantErr) {
+			t.Fatalf("%s: ожидалась ошибка %v, получено %v", step.name, step.wantErr, err)
+		}
+		if replayed != step.wantReplayed || calls != step.wantCalls {
+			t.Fatalf("%s: replayed=%v calls=%d, ожидалось replayed=%v calls=%d",
+				step.name, replayed, calls, step.wantReplayed, step.wantCalls)
+		}
+		if err == nil && (resp.Status != 201 || string(resp.Body) != `{"id":1}`) {
+			t.Fatalf("%s: неожиданный ответ %+v", step.name, resp)
+		}
+	}
+}
+
+func TestStore_DoWithoutKeeping(t *testing.T) {
+	s := newStore(time.Hour, time.Now)
+	ctx := context.Background()
+
+	calls := 0
+	failed := func() (Response, bool) {
+		calls++
+		return Response{Status: 500}, false
+	}
+
+	for range 2 {
+		if _, replayed, err := s.Do(ctx, "key", "a", failed); err != nil || replayed {
+			t.Fatalf("ожидалось выполнение без повтора ответа, получено replayed=%v err=%v", replayed, err)
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("несохраненный ответ должен освобождать ключ: ожидалось 2 вызова, получено %d", calls)
+	}
+	if s.Len() != 0 {
+		t.Fatalf("ожидалось 0 ключей, получено %d", s.Len())
+	}
+}
+
+func TestStore_DoSerializesConcurrentRequests(t *testing.T) {
+	s := newStore(time.Hour, time.Now)
+	ctx := context.Background()
+
+	release := make(chan struct{})
+	var calls atomic.Int32
+	slow := func() (Response, bool) {
+		calls.Add(1)
+		<-release
+		return Response{Status: 201}, true
+	}
+
+	const n = 8
+	var (
+		wg       sync.WaitGroup
+		replayed atomic.Int32
+	)
+	do := func() {
+		defer wg.Done()
+		if _, ok, err := s.Do(ctx, "key", "a", slow); err == nil && ok {
+			replayed.Add(1)
+		}
+	}
+
+	// Первый запрос занимает ключ до запуска остальных.
+	wg.Add(n)
+	go do()
+	for s.Len() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	for range n - 1 {
+		go do()
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Fatalf("запрос должен выполниться один раз, выполнен %d", calls.Load())
+	}
+	if replayed.Load() != n-1 {
+		t.Fatalf("ожидалось %d повторов, получено %d", n-1, replayed.Load())
+	}
+}
+
+func TestStore_DoWaitCanceled(t *testing.T) {
+	s := newStore(time.Hour, time.Now)
+
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _, _ = s.Do(context.Background(), "key", "a", func() (Response, bool) {
+			<-release
+			return Response{Status: 201}, true
+		})
+	}()
+	for s.Len() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := s.Do(ctx, "key", "a", nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("ожидалась ошибка %v, получено %v", context.Canceled, err)
+	}
+
+	close(release)
+	<-done
+}
+
+func TestStore_DoPanicReleasesKey(t *testing.T) {
+	s := newStore(time.Hour, time.Now)
+
+	func() {
+		defer func() { _ = recover() }()
+		_, _, _ = s.Do(context.Background(), "key", "a", func() (Response, bool) {
+			panic("сбой обработчика")
+		})
+	}()
+
+	if s.Len() != 0 {
+		t.Fatalf("паника должна освобождать ключ, занято %d", s.Len())
+	}
+}
+
+func TestStore_Evict(t *testing.T) {
+	clock := &testClock{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	s := newStore(time.Minute, clock.Now)
+	ok := func() (Response, bool) { return Response{Status: 200}, true }
+
+	_, _, _ = s.Do(context.Background(), "old", "a", ok)
+	clock.Advance(30 * time.Second)
+	_, _, _ = s.Do(context.Background(), "new", "a", ok)
+
+	clock.Advance(30 * time.Second)
+	s.evict()
+
+	if s.Len() != 1 {
+		t.Fatalf("ожидался 1 ключ после удаления устаревших, получено %d", s.Len())
+	}
+}
+
+func TestValidKey(t *testing.T) {
+	cases := map[string]bool{
+		"":                        false,
+		"7c9e6679-7425-40de-944b": true,
+		"ключ":                    false,
+		"with space":              false,
+		strings.Repeat("k", 255):  true,
+		strings.Repeat("k", 256):  false,
+	}
+
+	for key, want := range cases {
+		if got := ValidKey(key); got != want {
+			t.Errorf("ValidKey(%q) = %v, ожидалось %v", key, got, want)
+		}
+	}
+}
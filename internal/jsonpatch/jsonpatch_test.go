@@ -0,0 +1,179 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func assertJSONEqual(t *testing.T, want string, got []byte) {
+	t.Helper()
+
+	var w, g any
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatalf("некорректный ожидаемый JSON: %v", err)
+	}
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatalf("некорректный результат: %v", err)
+	}
+	if !reflect.DeepEqual(w, g) {
+		t.Fatalf("ожидалось %s, получено %s", want, got)
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	cases := []struct {
+		name    string
+		doc     string
+		patch   string
+		want    string
+		wantErr error
+	}{
+		{
+			name:  "изменение поля",
+			doc:   `{"title":"a","completed":false}`,
+			patch: `{"completed":true}`,
+			want:  `{"title":"a","completed":true}`,
+		},
+		{
+			name:  "null удаляет поле",
+			doc:   `{"title":"a","description":"b"}`,
+			patch: `{"description":null}`,
+			want:  `{"title":"a"}`,
+		},
+		{
+			name:  "вложенный объект",
+			doc:   `{"a":{"b":1,"c":2}}`,
+			patch: `{"a":{"c":null,"d":3}}`,
+			want:  `{"a":{"b":1,"d":3}}`,
+		},
+		{
+			name:  "не объект заменяет документ",
+			doc:   `{"a":1}`,
+			patch: `[1,2]`,
+			want:  `[1,2]`,
+		},
+		{
+			name:    "некорректный JSON",
+			doc:     `{}`,
+			patch:   `{`,
+			wantErr: ErrInvalidPatch,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := MergePatch([]byte(tc.doc), []byte(tc.patch))
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("ожидалась ошибка %v, получено %v", tc.wantErr, err)
+			}
+			if tc.wantErr == nil {
+				assertJSONEqual(t, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	cases := []struct {
+		name    string
+		doc     string
+		patch   string
+		want    string
+		wantErr error
+	}{
+		{
+			name:  "replace",
+			doc:   `{"title":"a","completed":false}`,
+			patch: `[{"op":"replace","path":"/completed","value":true}]`,
+			want:  `{"title":"a","completed":true}`,
+		},
+		{
+			name:  "add и remove",
+			doc:   `{"title":"a","description":"b"}`,
+			patch: `[{"op":"remove","path":"/description"},{"op":"add","path":"/x","value":1}]`,
+			want:  `{"title":"a","x":1}`,
+		},
+		{
+			name:  "вставка в массив и добавление в конец",
+			doc:   `{"list":[1,3]}`,
+			patch: `[{"op":"add","path":"/list/1","value":2},{"op":"add","path":"/list/-","value":4}]`,
+			want:  `{"list":[1,2,3,4]}`,
+		},
+		{
+			name:  "move и copy",
+			doc:   `{"a":"x","b":{}}`,
+			patch: `[{"op":"copy","from":"/a","path":"/b/c"},{"op":"move","from":"/a","path":"/d"}]`,
+			want:  `{"b":{"c":"x"},"d":"x"}`,
+		},
+		{
+			name:  "экранирование в указателе",
+			doc:   `{"a/b":1,"c~d":2}`,
+			patch: `[{"op":"remove","path":"/a~1b"},{"op":"replace","path":"/c~0d","value":3}]`,
+			want:  `{"c~d":3}`,
+		},
+		{
+			name:  "успешный test",
+			doc:   `{"title":"a"}`,
+			patch: `[{"op":"test","path":"/title","value":"a"},{"op":"replace","path":"/title","value":"b"}]`,
+			want:  `{"title":"b"}`,
+		},
+		{
+			name:    "неуспешный test",
+			doc:     `{"title":"a"}`,
+			patch:   `[{"op":"test","path":"/title","value":"b"}]`,
+			wantErr: ErrTestFailed,
+		},
+		{
+			name:    "replace несуществующего поля",
+			doc:     `{"title":"a"}`,
+			patch:   `[{"op":"replace","path":"/missing","value":1}]`,
+			wantErr: ErrInvalidPatch,
+		},
+		{
+			name:    "add без value",
+			doc:     `{}`,
+			patch:   `[{"op":"add","path":"/a"}]`,
+			wantErr: ErrInvalidPatch,
+		},
+		{
+			name:    "индекс за пределами массива",
+			doc:     `{"list":[1]}`,
+			patch:   `[{"op":"remove","path":"/list/5"}]`,
+			wantErr: ErrInvalidPatch,
+		},
+		{
+			name:    "перемещение в собственного потомка",
+			doc:     `{"a":{"b":1}}`,
+			patch:   `[{"op":"move","from":"/a","path":"/a/b/c"}]`,
+			wantErr: ErrInvalidPatch,
+		},
+		{
+			name:    "неизвестная операция",
+			doc:     `{}`,
+			patch:   `[{"op":"merge","path":"/a","value":1}]`,
+			wantErr: ErrInvalidPatch,
+		},
+		{
+			name:    "патч не массив",
+			doc:     `{}`,
+			patch:   `{"op":"add"}`,
+			wantErr: ErrInvalidPatch,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Apply([]byte(tc.doc), []byte(tc.patch))
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("ожидалась ошибка %v, получено %v", tc.wantErr, err)
+			}
+			if tc.wantErr == nil {
+				assertJSONEqual(t, tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/RoGogDBD/ecom/internal/requestid"
+)
+
+// RequestIDKey имя атрибута с идентификатором запроса.
+const RequestIDKey = "request_id"
+
+// contextHandler добавляет к каждой записи идентификатор запроса из контекста,
+// так что любой вызов логгера с контекстом запроса связан с этим запросом.
+type contextHandler struct {
+	slog.Handler
+}
+
+// Handle дополняет запись атрибутом request_id, если он есть в ctx.
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := requestid.FromContext(ctx); id != "" {
+		r.AddAttrs(slog.String(RequestIDKey, id))
+	}
+
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
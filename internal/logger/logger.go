@@ -3,10 +3,8 @@ package logger
 import (
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"os"
-	"path/filepath"
-	"time"
 )
 
 const (
@@ -15,47 +13,82 @@ const (
 	logDateFormat  = "2006-01-02"
 	logFileMode    = 0644
 	logsDirMode    = 0755
-	logFlags       = log.LstdFlags | log.Lshortfile
 	errCreateDir   = "failed to create logs directory: %w"
-	errCreateFile  = "failed to create log file: %w"
+	errParseLevel  = "invalid log level %q: %w"
+	errFormat      = "invalid log format %q"
 )
 
-// New создает и возвращает новый логгер, который пишет одновременно в stdout и в файл.
-// Файл логов создается в директории logs с именем app_YYYY-MM-DD.log.
-func New() (*log.Logger, error) {
-	if err := ensureLogsDir(); err != nil {
-		return nil, err
+// Форматы вывода логов.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Options параметры логгера.
+type Options struct {
+	// Level минимальный уровень: debug, info, warn или error. Пустой — info.
+	Level string
+	// Format формат записей: text (key=value) или json. Пустой — text.
+	Format string
+	// File параметры файла логов и его ротации.
+	File RotateOptions
+}
+
+// New создает и возвращает новый структурированный логгер, который пишет одновременно в stdout и в файл.
+// Файл логов создается в директории opts.File.Dir и ротируется согласно opts.File.
+// Возвращаемый io.Closer закрывает файл логов и должен быть вызван при завершении.
+func New(opts Options) (*slog.Logger, io.Closer, error) {
+	// Параметры проверяются до создания файла, чтобы не оставлять пустые файлы при ошибке.
+	if _, err := opts.handlerOptions(); err != nil {
+		return nil, nil, err
+	}
+	if opts.Format != "" && opts.Format != FormatText && opts.Format != FormatJSON {
+		return nil, nil, fmt.Errorf(errFormat, opts.Format)
 	}
 
-	logFile, err := createLogFile()
+	logFile, err := NewRotatingWriter(opts.File)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	multiWriter := io.MultiWriter(os.Stdout, logFile)
-	logger := log.New(multiWriter, "", logFlags)
+	log, err := NewWithWriter(io.MultiWriter(os.Stdout, logFile), opts)
+	if err != nil {
+		_ = logFile.Close()
+		return nil, nil, err
+	}
 
-	return logger, nil
+	return log, logFile, nil
 }
 
-// ensureLogsDir создает директорию logs, если она не существует.
-func ensureLogsDir() error {
-	if err := os.MkdirAll(logsDir, logsDirMode); err != nil {
-		return fmt.Errorf(errCreateDir, err)
+// NewWithWriter создает логгер, пишущий в w. Записи с контекстом запроса
+// дополняются атрибутом request_id.
+func NewWithWriter(w io.Writer, opts Options) (*slog.Logger, error) {
+	handlerOpts, err := opts.handlerOptions()
+	if err != nil {
+		return nil, err
 	}
-	return nil
-}
 
-// createLogFile создает файл для логов с именем, содержащим текущую дату.
-func createLogFile() (*os.File, error) {
-	currentDate := time.Now().Format(logDateFormat)
-	logFileName := fmt.Sprintf(logFilePattern, currentDate)
-	logFilePath := filepath.Join(logsDir, logFileName)
+	var handler slog.Handler
+	switch opts.Format {
+	case "", FormatText:
+		handler = slog.NewTextHandler(w, handlerOpts)
+	case FormatJSON:
+		handler = slog.NewJSONHandler(w, handlerOpts)
+	default:
+		return nil, fmt.Errorf(errFormat, opts.Format)
+	}
 
-	file, err := os.OpenFile(logFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, logFileMode)
-	if err != nil {
-		return nil, fmt.Errorf(errCreateFile, err)
+	return slog.New(contextHandler{handler}), nil
+}
+
+// handlerOptions преобразует параметры в настройки обработчика slog.
+func (o Options) handlerOptions() (*slog.HandlerOptions, error) {
+	var level slog.Level
+	if o.Level != "" {
+		if err := level.UnmarshalText([]byte(o.Level)); err != nil {
+			return nil, fmt.Errorf(errParseLevel, o.Level, err)
+		}
 	}
 
-	return file, nil
+	return &slog.HandlerOptions{Level: level}, nil
 }
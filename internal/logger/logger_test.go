@@ -0,0 +1,110 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/RoGogDBD/ecom/internal/requestid"
+)
+
+func TestNewWithWriter(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    Options
+		wantErr bool
+		check   func(t *testing.T, out string)
+	}{
+		{
+			name: "json формат",
+			opts: Options{Format: FormatJSON},
+			check: func(t *testing.T, out string) {
+				var entry map[string]any
+				if err := json.Unmarshal([]byte(out), &entry); err != nil {
+					t.Fatalf("ожидалась JSON-запись, получено %q: %v", out, err)
+				}
+				if entry["msg"] != "проверка" || entry["key"] != "value" {
+					t.Errorf("неожиданная запись: %v", entry)
+				}
+			},
+		},
+		{
+			name: "text формат по умолчанию",
+			opts: Options{},
+			check: func(t *testing.T, out string) {
+				if !strings.Contains(out, "key=value") {
+					t.Errorf("ожидалась запись key=value, получено %q", out)
+				}
+			},
+		},
+		{
+			name: "уровень отсекает info",
+			opts: Options{Level: "warn"},
+			check: func(t *testing.T, out string) {
+				if out != "" {
+					t.Errorf("ожидался пустой вывод, получено %q", out)
+				}
+			},
+		},
+		{
+			name:    "неизвестный формат",
+			opts:    Options{Format: "xml"},
+			wantErr: true,
+		},
+		{
+			name:    "неизвестный уровень",
+			opts:    Options{Level: "verbose"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger, err := NewWithWriter(&buf, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewWithWriter() ошибка = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			logger.Info("проверка", "key", "value")
+			tt.check(t, strings.TrimSpace(buf.String()))
+		})
+	}
+}
+
+func TestNewWithWriter_RequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := NewWithWriter(&buf, Options{Format: FormatJSON})
+	if err != nil {
+		t.Fatalf("NewWithWriter() ошибка = %v", err)
+	}
+
+	ctx := requestid.NewContext(context.Background(), "req-1")
+	logger.With("component", "test").InfoContext(ctx, "с контекстом")
+	logger.Info("без контекста")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("ожидалось 2 записи, получено %d: %q", len(lines), buf.String())
+	}
+
+	var withID, withoutID map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &withID); err != nil {
+		t.Fatalf("некорректная запись %q: %v", lines[0], err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &withoutID); err != nil {
+		t.Fatalf("некорректная запись %q: %v", lines[1], err)
+	}
+
+	if withID[RequestIDKey] != "req-1" || withID["component"] != "test" {
+		t.Errorf("неожиданная запись с контекстом: %v", withID)
+	}
+	if _, ok := withoutID[RequestIDKey]; ok {
+		t.Errorf("запись без контекста не должна содержать %s: %v", RequestIDKey, withoutID)
+	}
+}
@@ -0,0 +1,352 @@
+package logger
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	logFilePrefix       = "app"
+	logFileExt          = ".log"
+	compressedExt       = ".gz"
+	backupTimeFormat    = "2006-01-02T15-04-05.000"
+	errOpenRotateFile   = "failed to open log file: %w"
+	errRotateFile       = "failed to rotate log file: %w"
+	errWriterClosed     = "log writer is closed"
+	errCompressBackup   = "failed to compress log file %s: %w"
+	errRemoveOldBackups = "failed to remove old log file %s: %w"
+)
+
+type (
+	// RotateOptions параметры ротации файлов логов.
+	RotateOptions struct {
+		// Dir директория файлов логов. Пустая — logs.
+		Dir string
+		// Daily включает ротацию по дате: активный файл называется app_YYYY-MM-DD.log
+		// и сменяется в полночь. Без нее активный файл называется app.log.
+		Daily bool
+		// MaxSize размер активного файла в байтах, после которого он ротируется. 0 — без ограничения.
+		MaxSize int64
+		// Compress сжимает ротированные файлы в gzip.
+		Compress bool
+		// MaxBackups количество хранимых ротированных файлов. 0 — без ограничения.
+		MaxBackups int
+		// MaxAge срок хранения ротированных файлов. 0 — без ограничения.
+		MaxAge time.Duration
+	}
+
+	// RotatingWriter io.Writer, который пишет в файл и ротирует его по дате и/или размеру.
+	// Сжатие и удаление старых файлов выполняются в фоне, не блокируя запись.
+	RotatingWriter struct {
+		opts RotateOptions
+		now  func() time.Time
+
+		mu     sync.Mutex
+		file   *os.File
+		name   string
+		size   int64
+		closed bool
+
+		// mill сигнализирует фоновой горутине об изменении набора файлов.
+		mill chan struct{}
+		done chan struct{}
+		// onMillError вызывается при ошибке фонового обслуживания; по умолчанию пишет в stderr.
+		onMillError func(error)
+	}
+
+	backupFile struct {
+		path    string
+		modTime time.Time
+	}
+)
+
+// NewRotatingWriter открывает активный файл логов и запускает фоновое обслуживание.
+func NewRotatingWriter(opts RotateOptions) (*RotatingWriter, error) {
+	return newRotatingWriter(opts, time.Now)
+}
+
+func newRotatingWriter(opts RotateOptions, now func() time.Time) (*RotatingWriter, error) {
+	if opts.Dir == "" {
+		opts.Dir = logsDir
+	}
+	if err := os.MkdirAll(opts.Dir, logsDirMode); err != nil {
+		return nil, fmt.Errorf(errCreateDir, err)
+	}
+
+	w := &RotatingWriter{
+		opts: opts,
+		now:  now,
+		mill: make(chan struct{}, 1),
+		done: make(chan struct{}),
+		onMillError: func(err error) {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+		},
+	}
+
+	if err := w.openActive(); err != nil {
+		return nil, err
+	}
+
+	go w.millLoop()
+	// Старые файлы могли остаться с прошлого запуска.
+	w.signalMill()
+
+	return w, nil
+}
+
+// Write пишет p в активный файл, предварительно ротируя его при смене даты
+// или превышении размера.
+func (w *RotatingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, errors.New(errWriterClosed)
+	}
+
+	if w.activeName() != w.name {
+		if err := w.switchActive(); err != nil {
+			return 0, err
+		}
+	} else if w.opts.MaxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.opts.MaxSize {
+		if err := w.rotateBySize(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Close закрывает активный файл и дожидается завершения фонового обслуживания.
+func (w *RotatingWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	err := w.file.Close()
+	close(w.mill)
+	w.mu.Unlock()
+
+	<-w.done
+	return err
+}
+
+// activeName возвращает имя активного файла для текущего момента.
+func (w *RotatingWriter) activeName() string {
+	if w.opts.Daily {
+		return fmt.Sprintf(logFilePattern, w.now().Format(logDateFormat))
+	}
+	return logFilePrefix + logFileExt
+}
+
+// openActive открывает активный файл на дозапись. Вызывается под w.mu или при создании.
+func (w *RotatingWriter) openActive() error {
+	name := w.activeName()
+
+	file, err := os.OpenFile(filepath.Join(w.opts.Dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, logFileMode)
+	if err != nil {
+		return fmt.Errorf(errOpenRotateFile, err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf(errOpenRotateFile, err)
+	}
+
+	w.file = file
+	w.name = name
+	w.size = info.Size()
+	return nil
+}
+
+// switchActive переключается на файл нового дня; файл прошлого дня становится резервным.
+func (w *RotatingWriter) switchActive() error {
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf(errRotateFile, err)
+	}
+	if err := w.openActive(); err != nil {
+		return err
+	}
+
+	w.signalMill()
+	return nil
+}
+
+// rotateBySize переименовывает переполненный активный файл в резервный и открывает новый.
+func (w *RotatingWriter) rotateBySize() error {
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf(errRotateFile, err)
+	}
+
+	backup := logFilePrefix + "_" + w.now().Format(backupTimeFormat) + logFileExt
+	if err := os.Rename(filepath.Join(w.opts.Dir, w.name), filepath.Join(w.opts.Dir, backup)); err != nil {
+		return fmt.Errorf(errRotateFile, err)
+	}
+
+	if err := w.openActive(); err != nil {
+		return err
+	}
+
+	w.signalMill()
+	return nil
+}
+
+func (w *RotatingWriter) signalMill() {
+	select {
+	case w.mill <- struct{}{}:
+	default:
+	}
+}
+
+// millLoop сжимает и удаляет резервные файлы, пока writer не закрыт.
+func (w *RotatingWriter) millLoop() {
+	defer close(w.done)
+
+	for range w.mill {
+		if err := w.millOnce(); err != nil {
+			w.onMillError(err)
+		}
+	}
+}
+
+func (w *RotatingWriter) millOnce() error {
+	if !w.opts.Compress && w.opts.MaxBackups <= 0 && w.opts.MaxAge <= 0 {
+		return nil
+	}
+
+	backups, err := w.backups()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	if w.opts.Compress {
+		for i, backup := range backups {
+			if strings.HasSuffix(backup.path, compressedExt) {
+				continue
+			}
+			if err := compressFile(backup.path); err != nil {
+				errs = append(errs, fmt.Errorf(errCompressBackup, backup.path, err))
+				continue
+			}
+			backups[i].path += compressedExt
+		}
+	}
+
+	cutoff := time.Time{}
+	if w.opts.MaxAge > 0 {
+		cutoff = w.now().Add(-w.opts.MaxAge)
+	}
+	for i, backup := range backups {
+		expired := !cutoff.IsZero() && backup.modTime.Before(cutoff)
+		excess := w.opts.MaxBackups > 0 && i >= w.opts.MaxBackups
+		if !expired && !excess {
+			continue
+		}
+		if err := os.Remove(backup.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, fmt.Errorf(errRemoveOldBackups, backup.path, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// backups возвращает резервные файлы логов, от новых к старым. Активный файл не включается.
+func (w *RotatingWriter) backups() ([]backupFile, error) {
+	entries, err := os.ReadDir(w.opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	w.mu.Lock()
+	active := w.name
+	w.mu.Unlock()
+
+	var result []backupFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || name == active || !isLogFile(name) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		result = append(result, backupFile{path: filepath.Join(w.opts.Dir, name), modTime: info.ModTime()})
+	}
+
+	// При равном времени модификации порядок определяет имя: оно содержит дату ротации.
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].modTime.Equal(result[j].modTime) {
+			return result[i].modTime.After(result[j].modTime)
+		}
+		return result[i].path > result[j].path
+	})
+
+	return result, nil
+}
+
+func isLogFile(name string) bool {
+	if !strings.HasPrefix(name, logFilePrefix) {
+		return false
+	}
+	name = strings.TrimSuffix(name, compressedExt)
+	return strings.HasSuffix(name, logFileExt)
+}
+
+// compressFile сжимает файл в path+".gz" и удаляет исходный. Время модификации сохраняется,
+// чтобы сжатие не продлевало срок хранения.
+func compressFile(path string) (err error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	dstPath := path + compressedExt
+	dst, err := os.OpenFile(dstPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, logFileMode)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = dst.Close()
+			_ = os.Remove(dstPath)
+		}
+	}()
+
+	gz := gzip.NewWriter(dst)
+	if _, err = io.Copy(gz, src); err != nil {
+		return err
+	}
+	if err = gz.Close(); err != nil {
+		return err
+	}
+	if err = dst.Close(); err != nil {
+		return err
+	}
+	if err = os.Chtimes(dstPath, info.ModTime(), info.ModTime()); err != nil {
+		return err
+	}
+
+	return os.Remove(path)
+}
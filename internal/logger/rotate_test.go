@@ -0,0 +1,174 @@
+package logger
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testClock управляемые часы для проверки ротации по дате.
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func listLogs(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir() ошибка = %v", err)
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	slices.Sort(names)
+	return names
+}
+
+func writeLines(t *testing.T, w io.Writer, lines ...string) {
+	t.Helper()
+
+	for _, line := range lines {
+		if _, err := io.WriteString(w, line); err != nil {
+			t.Fatalf("Write() ошибка = %v", err)
+		}
+	}
+}
+
+func TestRotatingWriter_Size(t *testing.T) {
+	dir := t.TempDir()
+	clock := &testClock{now: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)}
+
+	w, err := newRotatingWriter(RotateOptions{Dir: dir, MaxSize: 10, MaxBackups: 2}, clock.Now)
+	if err != nil {
+		t.Fatalf("newRotatingWriter() ошибка = %v", err)
+	}
+
+	for range 4 {
+		clock.now = clock.now.Add(time.Second)
+		writeLines(t, w, "0123456789")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() ошибка = %v", err)
+	}
+
+	got := listLogs(t, dir)
+	want := []string{
+		"app.log",
+		"app_2024-05-01T10-00-03.000.log",
+		"app_2024-05-01T10-00-04.000.log",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("файлы = %v, ожидалось %v", got, want)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "app.log"))
+	if err != nil {
+		t.Fatalf("ReadFile() ошибка = %v", err)
+	}
+	if string(data) != "0123456789" {
+		t.Errorf("активный файл = %q, ожидалась последняя запись", data)
+	}
+}
+
+func TestRotatingWriter_Daily(t *testing.T) {
+	dir := t.TempDir()
+	clock := &testClock{now: time.Date(2024, 5, 1, 23, 59, 0, 0, time.UTC)}
+
+	w, err := newRotatingWriter(RotateOptions{Dir: dir, Daily: true, Compress: true}, clock.Now)
+	if err != nil {
+		t.Fatalf("newRotatingWriter() ошибка = %v", err)
+	}
+
+	writeLines(t, w, "вчера\n")
+	clock.now = clock.now.Add(2 * time.Minute)
+	writeLines(t, w, "сегодня\n")
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() ошибка = %v", err)
+	}
+
+	got := listLogs(t, dir)
+	want := []string{"app_2024-05-01.log.gz", "app_2024-05-02.log"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("файлы = %v, ожидалось %v", got, want)
+	}
+
+	file, err := os.Open(filepath.Join(dir, "app_2024-05-01.log.gz"))
+	if err != nil {
+		t.Fatalf("Open() ошибка = %v", err)
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatalf("gzip.NewReader() ошибка = %v", err)
+	}
+	data, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatalf("ReadAll() ошибка = %v", err)
+	}
+	if string(data) != "вчера\n" {
+		t.Errorf("сжатый файл = %q, ожидалось %q", data, "вчера\n")
+	}
+}
+
+func TestRotatingWriter_MaxAge(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	// Файлы прошлых запусков: один устарел, другой еще в пределах срока.
+	old := filepath.Join(dir, "app_2024-05-01.log")
+	recent := filepath.Join(dir, "app_2024-05-09.log.gz")
+	foreign := filepath.Join(dir, "other.txt")
+	for _, path := range []string{old, recent, foreign} {
+		if err := os.WriteFile(path, []byte("x"), logFileMode); err != nil {
+			t.Fatalf("WriteFile() ошибка = %v", err)
+		}
+	}
+	_ = os.Chtimes(old, now.Add(-9*24*time.Hour), now.Add(-9*24*time.Hour))
+	_ = os.Chtimes(recent, now.Add(-24*time.Hour), now.Add(-24*time.Hour))
+	_ = os.Chtimes(foreign, now.Add(-30*24*time.Hour), now.Add(-30*24*time.Hour))
+
+	w, err := newRotatingWriter(RotateOptions{Dir: dir, Daily: true, MaxAge: 7 * 24 * time.Hour}, func() time.Time { return now })
+	if err != nil {
+		t.Fatalf("newRotatingWriter() ошибка = %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() ошибка = %v", err)
+	}
+
+	got := listLogs(t, dir)
+	want := []string{"app_2024-05-09.log.gz", "app_2024-05-10.log", "other.txt"}
+	if !slices.Equal(got, want) {
+		t.Errorf("файлы = %v, ожидалось %v", got, want)
+	}
+}
+
+func TestRotatingWriter_Closed(t *testing.T) {
+	w, err := NewRotatingWriter(RotateOptions{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewRotatingWriter() ошибка = %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() ошибка = %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("повторный Close() ошибка = %v", err)
+	}
+
+	if _, err := w.Write([]byte("после закрытия")); err == nil || !strings.Contains(err.Error(), errWriterClosed) {
+		t.Errorf("Write() ошибка = %v, ожидалась %q", err, errWriterClosed)
+	}
+}
@@ -0,0 +1,296 @@
+// Package metrics реализует минимальный реестр метрик (счетчики, gauge, гистограммы)
+// с выводом в текстовом формате Prometheus без внешних зависимостей.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+
+	contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+	// labelSeparator разделяет значения меток в ключе серии; не встречается в корректном UTF-8.
+	labelSeparator = "\xff"
+
+	errDuplicateMetric = "metrics: метрика %q уже зарегистрирована"
+	errLabelCount      = "metrics: метрика %q ожидает %d значений меток, получено %d"
+)
+
+// DefBuckets границы гистограммы по умолчанию для длительностей в секундах.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type (
+	// Registry набор метрик, который выводится целиком по запросу.
+	Registry struct {
+		mu       sync.Mutex
+		families []*family
+		names    map[string]struct{}
+	}
+
+	// family метрика с одинаковым именем и набором меток.
+	family struct {
+		name    string
+		help    string
+		typ     string
+		labels  []string
+		buckets []float64
+		// fn вычисляет значение gauge в момент вывода.
+		fn func() float64
+
+		mu     sync.Mutex
+		series map[string]*series
+	}
+
+	// series значения метрики для конкретного набора значений меток.
+	series struct {
+		values []string
+		value  float64
+		counts []uint64
+		sum    float64
+		count  uint64
+	}
+
+	// Counter монотонно растущий счетчик.
+	Counter struct{ f *family }
+	// Gauge значение, которое может как расти, так и уменьшаться.
+	Gauge struct{ f *family }
+	// Histogram распределение наблюдений по корзинам.
+	Histogram struct{ f *family }
+)
+
+// NewRegistry создает пустой реестр.
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]struct{})}
+}
+
+// NewCounter регистрирует счетчик с указанными именами меток.
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{r.register(&family{name: name, help: help, typ: typeCounter, labels: labels})}
+}
+
+// NewGauge регистрирует gauge с указанными именами меток.
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{r.register(&family{name: name, help: help, typ: typeGauge, labels: labels})}
+}
+
+// NewGaugeFunc регистрирует gauge без меток, значение которого вычисляет fn при каждом выводе.
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(&family{name: name, help: help, typ: typeGauge, fn: fn})
+}
+
+// NewHistogram регистрирует гистограмму с возрастающими границами корзин buckets.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	buckets = slices.Clone(buckets)
+	slices.Sort(buckets)
+
+	return &Histogram{r.register(&family{name: name, help: help, typ: typeHistogram, labels: labels, buckets: buckets})}
+}
+
+// register добавляет метрику в реестр. Повторная регистрация имени — ошибка программиста.
+func (r *Registry) register(f *family) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.names[f.name]; exists {
+		panic(fmt.Sprintf(errDuplicateMetric, f.name))
+	}
+	r.names[f.name] = struct{}{}
+
+	f.series = make(map[string]*series)
+	r.families = append(r.families, f)
+	return f
+}
+
+// Inc увеличивает счетчик на 1.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add увеличивает счетчик на v. Отрицательные значения игнорируются.
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		return
+	}
+
+	c.f.update(labelValues, func(s *series) { s.value += v })
+}
+
+// Set устанавливает значение gauge.
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.f.update(labelValues, func(s *series) { s.value = v })
+}
+
+// Add изменяет значение gauge на v.
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	g.f.update(labelValues, func(s *series) { s.value += v })
+}
+
+// Observe добавляет наблюдение v.
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.f.update(labelValues, func(s *series) {
+		if s.counts == nil {
+			s.counts = make([]uint64, len(h.f.buckets))
+		}
+		// Корзины хранятся некумулятивно, суммы считаются при выводе.
+		if i, _ := slices.BinarySearch(h.f.buckets, v); i < len(h.f.buckets) {
+			s.counts[i]++
+		}
+		s.sum += v
+		s.count++
+	})
+}
+
+func (f *family) update(labelValues []string, fn func(*series)) {
+	if len(labelValues) != len(f.labels) {
+		panic(fmt.Sprintf(errLabelCount, f.name, len(f.labels), len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, labelSeparator)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{values: slices.Clone(labelValues)}
+		f.series[key] = s
+	}
+	fn(s)
+}
+
+// ServeHTTP отдает все метрики в текстовом формате Prometheus.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", contentType)
+	_, _ = r.WriteTo(w)
+}
+
+// WriteTo пишет все метрики в текстовом формате Prometheus в порядке регистрации.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	families := slices.Clone(r.families)
+	r.mu.Unlock()
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, f := range families {
+		f.write(bw)
+	}
+
+	err := bw.Flush()
+	return cw.n, err
+}
+
+func (f *family) write(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.typ)
+
+	if f.fn != nil {
+		fmt.Fprintf(w, "%s %s\n", f.name, formatFloat(f.fn()))
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		s := f.series[key]
+		if f.typ != typeHistogram {
+			fmt.Fprintf(w, "%s%s %s\n", f.name, f.labelSet(s.values, "", 0), formatFloat(s.value))
+			continue
+		}
+
+		var cumulative uint64
+		for i, bound := range f.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, f.labelSet(s.values, "le", bound), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, f.labelSet(s.values, "le", math.Inf(1)), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", f.name, f.labelSet(s.values, "", 0), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", f.name, f.labelSet(s.values, "", 0), s.count)
+	}
+}
+
+// labelSet форматирует набор меток {a="x",b="y"}; extra добавляет метку le гистограммы.
+func (f *family) labelSet(values []string, extra string, extraValue float64) string {
+	if len(values) == 0 && extra == "" {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range f.labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(values[i]))
+		b.WriteByte('"')
+	}
+	if extra != "" {
+		if len(f.labels) > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(extra)
+		b.WriteString(`="`)
+		b.WriteString(formatFloat(extraValue))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+
+	return b.String()
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+// countingWriter считает записанные байты для WriteTo.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistry_WriteTo(t *testing.T) {
+	reg := NewRegistry()
+
+	requests := reg.NewCounter("requests_total", "Количество запросов.", "method", "path")
+	requests.Inc("GET", "/todos")
+	requests.Inc("GET", "/todos")
+	requests.Add(3, "POST", `/a"b`)
+	requests.Add(-1, "GET", "/todos")
+
+	inflight := reg.NewGauge("inflight", "Запросы в обработке.")
+	inflight.Add(2)
+	inflight.Add(-1)
+
+	reg.NewGaugeFunc("items", "Количество задач.", func() float64 { return 42 })
+
+	latency := reg.NewHistogram("latency_seconds", "Длительность.", []float64{1, 0.1}, "method")
+	latency.Observe(0.05, "GET")
+	latency.Observe(0.5, "GET")
+	latency.Observe(5, "GET")
+
+	var b strings.Builder
+	if _, err := reg.WriteTo(&b); err != nil {
+		t.Fatalf("WriteTo() ошибка = %v", err)
+	}
+
+	want := `# HELP requests_total Количество запросов.
+# TYPE requests_total counter
+requests_total{method="GET",path="/todos"} 2
+requests_total{method="POST",path="/a\"b"} 3
+# HELP inflight Запросы в обработке.
+# TYPE inflight gauge
+inflight 1
+# HELP items Количество задач.
+# TYPE items gauge
+items 42
+# HELP latency_seconds Длительность.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{method="GET",le="0.1"} 1
+latency_seconds_bucket{method="GET",le="1"} 2
+latency_seconds_bucket{method="GET",le="+Inf"} 3
+latency_seconds_sum{method="GET"} 5.55
+latency_seconds_count{method="GET"} 3
+`
+	if got := b.String(); got != want {
+		t.Errorf("WriteTo() =\n%s\nожидалось\n%s", got, want)
+	}
+}
+
+func TestRegistry_ServeHTTP(t *testing.T) {
+	reg := NewRegistry()
+	reg.NewCounter("hits_total", "Попадания.").Inc()
+
+	rec := httptest.NewRecorder()
+	reg.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Content-Type = %q, ожидался text/plain", ct)
+	}
+	if !strings.Contains(rec.Body.String(), "hits_total 1\n") {
+		t.Errorf("тело не содержит счетчик: %q", rec.Body.String())
+	}
+}
+
+func TestRegistry_Panics(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(reg *Registry)
+	}{
+		{
+			name: "повторная регистрация",
+			fn: func(reg *Registry) {
+				reg.NewCounter("dup", "")
+				reg.NewGauge("dup", "")
+			},
+		},
+		{
+			name: "неверное число меток",
+			fn: func(reg *Registry) {
+				reg.NewCounter("c", "", "a").Inc()
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("ожидалась паника")
+				}
+			}()
+			tt.fn(NewRegistry())
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"slices"
+)
+
+// ErrRevisionNotFound ревизия отсутствует в истории задачи.
+var ErrRevisionNotFound = errors.New("ревизия todo не найдена")
+
+// diffIgnoredFields поля, которые не сравниваются в Diff: они меняются
+// при каждом изменении либо не меняются никогда.
+var diffIgnoredFields = map[string]bool{
+	"id":         true,
+	"owner":      true,
+	"version":    true,
+	"created_at": true,
+	"updated_at": true,
+}
+
+type (
+	// Revision одна версия задачи из истории ее изменений.
+	Revision struct {
+		// Rev номер ревизии, совпадает с версией задачи.
+		Rev  int  `json:"rev"`
+		Todo Todo `json:"todo"`
+		// Changes отличия от ревизии, с которой сравнивается эта: по умолчанию
+		// от предыдущей, у первой ревизии — от пустой задачи.
+		Changes []FieldChange `json:"changes"`
+	}
+
+	// FieldChange изменение одного поля задачи. From и To — значения в JSON,
+	// null для отсутствующего значения.
+	FieldChange struct {
+		Field string          `json:"field"`
+		From  json.RawMessage `json:"from"`
+		To    json.RawMessage `json:"to"`
+	}
+)
+
+// Diff возвращает изменения полей задачи между from и to в порядке имен полей.
+// Поля сравниваются по их JSON-представлению, поэтому новые поля задачи
+// учитываются без изменения Diff.
+func Diff(from, to Todo) []FieldChange {
+	before, after := fields(from), fields(to)
+
+	names := make([]string, 0, len(after))
+	for name := range after {
+		names = append(names, name)
+	}
+	for name := range before {
+		if _, ok := after[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	changes := []FieldChange{}
+	for _, name := range names {
+		if diffIgnoredFields[name] {
+			continue
+		}
+		oldValue, newValue := fieldValue(before, name), fieldValue(after, name)
+		if !bytes.Equal(oldValue, newValue) {
+			changes = append(changes, FieldChange{Field: name, From: oldValue, To: newValue})
+		}
+	}
+
+	return changes
+}
+
+// fields возвращает поля задачи в JSON-представлении.
+func fields(todo Todo) map[string]json.RawMessage {
+	data, err := json.Marshal(todo)
+	if err != nil {
+		return nil
+	}
+
+	var result map[string]json.RawMessage
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil
+	}
+
+	return result
+}
+
+func fieldValue(fields map[string]json.RawMessage, name string) json.RawMessage {
+	if value, ok := fields[name]; ok {
+		return value
+	}
+	return json.RawMessage("null")
+}
@@ -1,21 +1,264 @@
 package models
 
-import "errors"
+import (
+	"errors"
+	"time"
+)
 
 var (
 	// Ошибки валидации данных.
-	ErrInvalidID  = errors.New("id должен быть положительным числом")
-	ErrEmptyTitle = errors.New("title не может быть пустым")
+	ErrInvalidID       = errors.New("id должен быть положительным числом")
+	ErrEmptyTitle      = errors.New("title не может быть пустым")
+	ErrInvalidPriority = errors.New("priority должен быть одним из: low, normal, high, urgent")
+	ErrInvalidReminder = errors.New("remind_at не может быть позже due_at")
+	ErrInvalidTags     = errors.New("некорректные теги")
+	ErrInvalidParent   = errors.New("некорректная родительская задача")
+	ErrInvalidQuery    = errors.New("некорректные параметры запроса")
+	ErrInvalidPatch    = errors.New("некорректный патч")
+	ErrInvalidBatch    = errors.New("некорректная пакетная операция")
 	// Ошибки операций.
-	ErrDuplicateID = errors.New("todo с данным ID уже существует")
-	ErrNotFound    = errors.New("todo не найден")
+	ErrDuplicateID     = errors.New("todo с данным ID уже существует")
+	ErrNotFound        = errors.New("todo не найден")
+	ErrPatchTestFailed = errors.New("условие test в патче не выполнено")
+	ErrVersionMismatch = errors.New("версия todo не совпадает с ожидаемой")
+	ErrBatchAborted    = errors.New("операция отменена из-за ошибки в другой операции пакета")
+	ErrHasChildren     = errors.New("у задачи есть подзадачи")
+	// Ошибки доступа.
+	ErrForbidden = errors.New("операция запрещена")
+)
+
+// Виды операций пакетной обработки.
+const (
+	BatchCreate = "create"
+	BatchUpdate = "update"
+	BatchDelete = "delete"
+)
+
+// PatchType формат частичного обновления задачи.
+type PatchType string
+
+const (
+	// PatchMerge JSON Merge Patch (RFC 7396).
+	PatchMerge PatchType = "merge"
+	// PatchJSON JSON Patch (RFC 6902).
+	PatchJSON PatchType = "json"
+)
+
+// Поля сортировки списка задач. Префикс "-" означает сортировку по убыванию.
+const (
+	SortByID          = "id"
+	SortByTitle       = "title"
+	SortByCreatedAt   = "created_at"
+	SortByUpdatedAt   = "updated_at"
+	SortByCompletedAt = "completed_at"
+	SortByDueAt       = "due_at"
+	SortByPriority    = "priority"
+
+	SortDescPrefix = "-"
+)
+
+// ChildrenMode определяет, что происходит с подзадачами удаляемой задачи.
+type ChildrenMode string
+
+const (
+	// ChildrenReject запрещает удалять задачу с активными подзадачами.
+	ChildrenReject ChildrenMode = "reject"
+	// ChildrenCascade удаляет задачу вместе со всеми подзадачами.
+	ChildrenCascade ChildrenMode = "cascade"
+	// ChildrenDetach делает подзадачи задачами верхнего уровня.
+	ChildrenDetach ChildrenMode = "detach"
+)
+
+// Priority важность задачи.
+type Priority string
+
+const (
+	PriorityLow    Priority = "low"
+	PriorityNormal Priority = "normal"
+	PriorityHigh   Priority = "high"
+	PriorityUrgent Priority = "urgent"
 )
 
+// priorityRanks порядок важности для сортировки: чем больше, тем важнее.
+var priorityRanks = map[Priority]int{
+	PriorityLow:    1,
+	PriorityNormal: 2,
+	PriorityHigh:   3,
+	PriorityUrgent: 4,
+}
+
 type (
 	Todo struct {
 		ID          int    `json:"id"`
 		Title       string `json:"title"`
 		Description string `json:"description"`
 		Completed   bool   `json:"completed"`
+		// Priority важность задачи; пустая равнозначна normal.
+		Priority Priority `json:"priority,omitempty"`
+		// DueAt срок выполнения задачи; nil — без срока.
+		DueAt *time.Time `json:"due_at,omitempty"`
+		// RemindAt время напоминания о задаче, не позже DueAt; nil — без напоминания.
+		RemindAt *time.Time `json:"remind_at,omitempty"`
+		// Tags метки задачи в каноническом виде: в нижнем регистре, без повторов, по алфавиту.
+		Tags []string `json:"tags,omitempty"`
+		// ParentID родительская задача того же владельца; 0 — задача верхнего уровня.
+		ParentID int `json:"parent_id,omitempty"`
+		// Owner владелец задачи: аутентифицированный пользователь, создавший ее.
+		// Назначается сервисом; без аутентификации все задачи принадлежат владельцу "".
+		Owner string `json:"owner,omitempty"`
+		// Version номер версии задачи. Назначается хранилищем: 1 при создании
+		// и +1 при каждом изменении. Используется как ETag.
+		Version int `json:"version"`
+		// Временные метки проставляются сервисом и недоступны клиенту для изменения.
+		CreatedAt   time.Time  `json:"created_at"`
+		UpdatedAt   time.Time  `json:"updated_at"`
+		CompletedAt *time.Time `json:"completed_at"`
+		// DeletedAt время перемещения задачи в корзину; nil — задача не удалена.
+		DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	}
+
+	// TimeRange полуоткрытый интервал времени [After, Before). Нулевые границы не ограничивают.
+	TimeRange struct {
+		After  time.Time
+		Before time.Time
+	}
+
+	// TodoQuery параметры выборки списка задач.
+	TodoQuery struct {
+		// Limit максимальное количество задач на странице.
+		Limit int
+		// Offset количество пропускаемых задач. Не совместим с Cursor.
+		Offset int
+		// Cursor непрозрачный курсор, полученный из TodoPage.NextCursor.
+		Cursor string
+		// Sort поле сортировки, например "id", "title" или "-id".
+		Sort string
+		// Completed фильтр по признаку завершенности; nil — без фильтра.
+		Completed *bool
+		// Search подстрока для поиска в заголовке и описании без учета регистра.
+		Search string
+		// CreatedAt, UpdatedAt, CompletedAt фильтры по временным меткам.
+		CreatedAt   TimeRange
+		UpdatedAt   TimeRange
+		CompletedAt TimeRange
+		// Priority фильтр по важности; пустой — без фильтра.
+		Priority Priority
+		// DueAt фильтр по сроку выполнения: задачи без срока в него не попадают.
+		DueAt TimeRange
+		// Overdue фильтр просроченных задач: незавершенных, чей срок наступил раньше Now; nil — без фильтра.
+		Overdue *bool
+		// Now момент, относительно которого определяется просрочка. Назначается сервисом.
+		Now time.Time
+		// Tags задачи должны иметь все перечисленные теги.
+		Tags []string
+		// AnyTags задачи должны иметь хотя бы один из перечисленных тегов.
+		AnyTags []string
+	}
+
+	// TodoTree задача с подзадачами всех уровней.
+	TodoTree struct {
+		Todo
+		Subtasks []TodoTree `json:"subtasks"`
+	}
+
+	// Removal результат удаления задачи.
+	Removal struct {
+		// Deleted удаленные задачи: сама задача, затем ее подзадачи при каскадном удалении.
+		Deleted []Todo
+		// Detached подзадачи, ставшие задачами верхнего уровня.
+		Detached []Todo
+	}
+
+	// TagCount тег и количество задач с ним.
+	TagCount struct {
+		Tag   string `json:"tag"`
+		Count int    `json:"count"`
+	}
+
+	// BatchOperation одна операция пакетного запроса.
+	BatchOperation struct {
+		// Op вид операции: create, update или delete.
+		Op string `json:"op"`
+		// ID задачи для update и delete.
+		ID int `json:"id,omitempty"`
+		// Version ожидаемая версия для update и delete; 0 — без проверки.
+		Version int `json:"version,omitempty"`
+		// Todo данные задачи для create и update.
+		Todo *Todo `json:"todo,omitempty"`
+	}
+
+	// BatchResult результат одной операции пакета.
+	BatchResult struct {
+		Todo Todo
+		Err  error
+	}
+
+	// Mutation изменение одной задачи, подготовленное сервисом для применения в хранилище.
+	Mutation struct {
+		Kind string
+		// ID задачи для update и delete.
+		ID int
+		// Version ожидаемая версия для delete; 0 — без проверки.
+		Version int
+		// DeletedAt время перемещения задачи в корзину для delete.
+		DeletedAt time.Time
+		// Todo новая задача для create.
+		Todo Todo
+		// Modify вычисляет новое состояние задачи для update.
+		Modify func(Todo) (Todo, error)
+	}
+
+	// TodoPage страница результатов выборки.
+	TodoPage struct {
+		Items []Todo
+		// Total количество задач, удовлетворяющих фильтрам, без учета пагинации.
+		Total int
+		// NextCursor курсор следующей страницы; пустой, если страница последняя.
+		NextCursor string
 	}
 )
+
+// Valid сообщает, что режим — одно из известных значений.
+func (m ChildrenMode) Valid() bool {
+	switch m {
+	case ChildrenReject, ChildrenCascade, ChildrenDetach:
+		return true
+	default:
+		return false
+	}
+}
+
+// Valid сообщает, что приоритет — одно из известных значений.
+func (p Priority) Valid() bool {
+	_, ok := priorityRanks[p]
+	return ok
+}
+
+// Rank возвращает место приоритета в порядке важности; пустой приоритет равен normal.
+func (p Priority) Rank() int {
+	if p == "" {
+		return priorityRanks[PriorityNormal]
+	}
+	return priorityRanks[p]
+}
+
+// Overdue сообщает, что задача не завершена, а ее срок наступил раньше now.
+func (t Todo) Overdue(now time.Time) bool {
+	return !t.Completed && t.DueAt != nil && t.DueAt.Before(now)
+}
+
+// IsZero сообщает, что интервал не задает ограничений.
+func (r TimeRange) IsZero() bool {
+	return r.After.IsZero() && r.Before.IsZero()
+}
+
+// Contains проверяет, что t попадает в интервал.
+func (r TimeRange) Contains(t time.Time) bool {
+	if !r.After.IsZero() && t.Before(r.After) {
+		return false
+	}
+	if !r.Before.IsZero() && !t.Before(r.Before) {
+		return false
+	}
+	return true
+}
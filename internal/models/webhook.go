@@ -0,0 +1,64 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrInvalidWebhook некорректная подписка на webhook.
+	ErrInvalidWebhook = errors.New("некорректная подписка на webhook")
+	// ErrWebhookNotFound подписка не найдена.
+	ErrWebhookNotFound = errors.New("webhook не найден")
+)
+
+// События задач, на которые можно подписать webhook.
+const (
+	WebhookEventCreated = "created"
+	WebhookEventUpdated = "updated"
+	WebhookEventDeleted = "deleted"
+	// WebhookEventCompleted задача переведена в завершенные.
+	WebhookEventCompleted = "completed"
+	// WebhookEventReminder наступило время напоминания о задаче.
+	WebhookEventReminder = "reminder"
+)
+
+// Состояния доставки webhook.
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+type (
+	// Webhook подписка на события задач владельца.
+	Webhook struct {
+		ID  int    `json:"id"`
+		URL string `json:"url"`
+		// Events события, о которых сообщается на URL.
+		Events []string `json:"events"`
+		// Secret ключ подписи HMAC-SHA256. Возвращается клиенту только при создании.
+		Secret    string    `json:"secret,omitempty"`
+		Owner     string    `json:"owner,omitempty"`
+		CreatedAt time.Time `json:"created_at"`
+		UpdatedAt time.Time `json:"updated_at"`
+	}
+
+	// WebhookDelivery доставка одного события на webhook со всеми ее попытками.
+	WebhookDelivery struct {
+		// ID идентификатор доставки; одинаков во всех попытках.
+		ID     string `json:"id"`
+		Event  string `json:"event"`
+		TodoID int    `json:"todo_id"`
+		// Status pending, succeeded или failed.
+		Status   string `json:"status"`
+		Attempts int    `json:"attempts"`
+		// ResponseCode HTTP-статус последней попытки; 0, если ответ не получен.
+		ResponseCode int `json:"response_code,omitempty"`
+		// Error причина неудачи последней попытки.
+		Error         string     `json:"error,omitempty"`
+		CreatedAt     time.Time  `json:"created_at"`
+		UpdatedAt     time.Time  `json:"updated_at"`
+		NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+	}
+)
@@ -0,0 +1,292 @@
+// Package policy проверяет права пользователей на операции с задачами.
+// TodoService и WebhookService оборачивают сервисы задач и подписок на webhook
+// и пропускают к ним только операции, разрешенные роли аутентифицированного пользователя.
+package policy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/RoGogDBD/ecom/internal/auth"
+	"github.com/RoGogDBD/ecom/internal/events"
+	"github.com/RoGogDBD/ecom/internal/models"
+)
+
+// Role набор прав пользователя.
+type Role string
+
+// Роли пользователей: каждая следующая включает права предыдущей.
+const (
+	// RoleViewer только чтение задач.
+	RoleViewer Role = "viewer"
+	// RoleEditor чтение и изменение задач.
+	RoleEditor Role = "editor"
+	// RoleAdmin все операции, включая явные ID, пакетное и окончательное удаление.
+	RoleAdmin Role = "admin"
+)
+
+// permission право на класс операций.
+type permission uint8
+
+const (
+	permRead permission = 1 << iota
+	permWrite
+	// permExplicitID создание задач с явно указанным ID (импорт).
+	permExplicitID
+	// permBulkDelete удаление задач пакетом.
+	permBulkDelete
+	// permHardDelete окончательное удаление задач в обход корзины.
+	permHardDelete
+)
+
+var rolePermissions = map[Role]permission{
+	RoleViewer: permRead,
+	RoleEditor: permRead | permWrite,
+	RoleAdmin:  permRead | permWrite | permExplicitID | permBulkDelete | permHardDelete,
+}
+
+var permissionNames = map[permission]string{
+	permRead:       "чтение задач",
+	permWrite:      "изменение задач",
+	permExplicitID: "создание задач с явным id",
+	permBulkDelete: "пакетное удаление задач",
+	permHardDelete: "окончательное удаление задач",
+}
+
+type (
+	// Service операции с задачами, которые защищает TodoService.
+	Service interface {
+		Create(ctx context.Context, todo models.Todo) (models.Todo, error)
+		Update(ctx context.Context, todo models.Todo) (models.Todo, error)
+		Patch(ctx context.Context, id, version int, patchType models.PatchType, patch []byte) (models.Todo, error)
+		Delete(ctx context.Context, id, version int, children models.ChildrenMode) error
+		HardDelete(ctx context.Context, id, version int, children models.ChildrenMode) error
+		Restore(ctx context.Context, id, version int) (models.Todo, error)
+		ListTrash(ctx context.Context) ([]models.Todo, error)
+		Tags(ctx context.Context) ([]models.TagCount, error)
+		History(ctx context.Context, id int) ([]models.Revision, error)
+		Children(ctx context.Context, id int) ([]models.Todo, error)
+		Expand(ctx context.Context, todos []models.Todo) ([]models.TodoTree, error)
+		Revision(ctx context.Context, id, rev, from int) (models.Revision, error)
+		Revert(ctx context.Context, id, rev, version int) (models.Todo, error)
+		Batch(ctx context.Context, ops []models.BatchOperation, atomic bool) ([]models.BatchResult, error)
+		List(ctx context.Context, query models.TodoQuery) (models.TodoPage, error)
+		GetByID(ctx context.Context, id int) (models.Todo, error)
+		Subscribe(ctx context.Context, lastEventID uint64) (*events.Subscription, error)
+	}
+
+	// Roles сопоставляет пользователей ролям.
+	Roles struct {
+		// Subjects роли пользователей по Principal.Subject.
+		Subjects map[string]Role
+		// Default роль пользователей, которых нет в Subjects. Пустая — у них нет прав.
+		Default Role
+	}
+
+	// TodoService проверяет права пользователя из контекста перед вызовом next.
+	// Отказ возвращается как models.ErrForbidden.
+	TodoService struct {
+		next  Service
+		roles Roles
+	}
+)
+
+// Of возвращает роль пользователя.
+func (r Roles) Of(p auth.Principal) Role {
+	if role, ok := r.Subjects[p.Subject]; ok {
+		return role
+	}
+	return r.Default
+}
+
+// NewTodoService оборачивает next проверкой прав по ролям roles.
+func NewTodoService(next Service, roles Roles) *TodoService {
+	return &TodoService{next: next, roles: roles}
+}
+
+func (s *TodoService) Create(ctx context.Context, todo models.Todo) (models.Todo, error) {
+	need := permWrite
+	if todo.ID != 0 {
+		need |= permExplicitID
+	}
+	if err := s.roles.authorize(ctx, need); err != nil {
+		return models.Todo{}, err
+	}
+
+	return s.next.Create(ctx, todo)
+}
+
+func (s *TodoService) Update(ctx context.Context, todo models.Todo) (models.Todo, error) {
+	if err := s.roles.authorize(ctx, permWrite); err != nil {
+		return models.Todo{}, err
+	}
+
+	return s.next.Update(ctx, todo)
+}
+
+func (s *TodoService) Patch(ctx context.Context, id, version int, patchType models.PatchType, patch []byte) (models.Todo, error) {
+	if err := s.roles.authorize(ctx, permWrite); err != nil {
+		return models.Todo{}, err
+	}
+
+	return s.next.Patch(ctx, id, version, patchType, patch)
+}
+
+// Delete с каскадным удалением подзадач удаляет несколько задач сразу,
+// поэтому требует права на пакетное удаление.
+func (s *TodoService) Delete(ctx context.Context, id, version int, children models.ChildrenMode) error {
+	need := permWrite
+	if children == models.ChildrenCascade {
+		need |= permBulkDelete
+	}
+	if err := s.roles.authorize(ctx, need); err != nil {
+		return err
+	}
+
+	return s.next.Delete(ctx, id, version, children)
+}
+
+func (s *TodoService) HardDelete(ctx context.Context, id, version int, children models.ChildrenMode) error {
+	need := permWrite | permHardDelete
+	if children == models.ChildrenCascade {
+		need |= permBulkDelete
+	}
+	if err := s.roles.authorize(ctx, need); err != nil {
+		return err
+	}
+
+	return s.next.HardDelete(ctx, id, version, children)
+}
+
+func (s *TodoService) Restore(ctx context.Context, id, version int) (models.Todo, error) {
+	if err := s.roles.authorize(ctx, permWrite); err != nil {
+		return models.Todo{}, err
+	}
+
+	return s.next.Restore(ctx, id, version)
+}
+
+func (s *TodoService) ListTrash(ctx context.Context) ([]models.Todo, error) {
+	if err := s.roles.authorize(ctx, permRead); err != nil {
+		return nil, err
+	}
+
+	return s.next.ListTrash(ctx)
+}
+
+func (s *TodoService) Tags(ctx context.Context) ([]models.TagCount, error) {
+	if err := s.roles.authorize(ctx, permRead); err != nil {
+		return nil, err
+	}
+
+	return s.next.Tags(ctx)
+}
+
+func (s *TodoService) History(ctx context.Context, id int) ([]models.Revision, error) {
+	if err := s.roles.authorize(ctx, permRead); err != nil {
+		return nil, err
+	}
+
+	return s.next.History(ctx, id)
+}
+
+func (s *TodoService) Children(ctx context.Context, id int) ([]models.Todo, error) {
+	if err := s.roles.authorize(ctx, permRead); err != nil {
+		return nil, err
+	}
+
+	return s.next.Children(ctx, id)
+}
+
+func (s *TodoService) Expand(ctx context.Context, todos []models.Todo) ([]models.TodoTree, error) {
+	if err := s.roles.authorize(ctx, permRead); err != nil {
+		return nil, err
+	}
+
+	return s.next.Expand(ctx, todos)
+}
+
+func (s *TodoService) Revision(ctx context.Context, id, rev, from int) (models.Revision, error) {
+	if err := s.roles.authorize(ctx, permRead); err != nil {
+		return models.Revision{}, err
+	}
+
+	return s.next.Revision(ctx, id, rev, from)
+}
+
+func (s *TodoService) Revert(ctx context.Context, id, rev, version int) (models.Todo, error) {
+	if err := s.roles.authorize(ctx, permWrite); err != nil {
+		return models.Todo{}, err
+	}
+
+	return s.next.Revert(ctx, id, rev, version)
+}
+
+// Batch проверяет права сразу на весь пакет: если хотя бы одна операция
+// не разрешена, пакет отклоняется целиком и не выполняется.
+func (s *TodoService) Batch(ctx context.Context, ops []models.BatchOperation, atomic bool) ([]models.BatchResult, error) {
+	need := permWrite
+	for _, op := range ops {
+		switch {
+		case op.Op == models.BatchDelete:
+			need |= permBulkDelete
+		case op.Op == models.BatchCreate && op.Todo != nil && op.Todo.ID != 0:
+			need |= permExplicitID
+		}
+	}
+	if err := s.roles.authorize(ctx, need); err != nil {
+		return nil, err
+	}
+
+	return s.next.Batch(ctx, ops, atomic)
+}
+
+func (s *TodoService) List(ctx context.Context, query models.TodoQuery) (models.TodoPage, error) {
+	if err := s.roles.authorize(ctx, permRead); err != nil {
+		return models.TodoPage{}, err
+	}
+
+	return s.next.List(ctx, query)
+}
+
+func (s *TodoService) GetByID(ctx context.Context, id int) (models.Todo, error) {
+	if err := s.roles.authorize(ctx, permRead); err != nil {
+		return models.Todo{}, err
+	}
+
+	return s.next.GetByID(ctx, id)
+}
+
+func (s *TodoService) Subscribe(ctx context.Context, lastEventID uint64) (*events.Subscription, error) {
+	if err := s.roles.authorize(ctx, permRead); err != nil {
+		return nil, err
+	}
+
+	return s.next.Subscribe(ctx, lastEventID)
+}
+
+// authorize проверяет, что роль пользователя из ctx включает все права need.
+// Запрос без пользователя не имеет прав.
+func (r Roles) authorize(ctx context.Context, need permission) error {
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		return fmt.Errorf("%w: пользователь не аутентифицирован", models.ErrForbidden)
+	}
+
+	granted := rolePermissions[r.Of(principal)]
+	if missing := need &^ granted; missing != 0 {
+		return fmt.Errorf("%w: %s", models.ErrForbidden, describe(missing))
+	}
+
+	return nil
+}
+
+// describe называет первое из недостающих прав.
+func describe(missing permission) string {
+	for p := permRead; p <= permHardDelete; p <<= 1 {
+		if missing&p != 0 {
+			return "нет права на " + permissionNames[p]
+		}
+	}
+	return "нет прав"
+}
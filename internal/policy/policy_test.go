@@ -0,0 +1,327 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/RoGogDBD/ecom/internal/auth"
+	"github.com/RoGogDBD/ecom/internal/events"
+	"github.com/RoGogDBD/ecom/internal/models"
+)
+
+// stubService считает вызовы, дошедшие до защищаемого сервиса.
+type stubService struct {
+	calls int
+}
+
+func (s *stubService) Create(_ context.Context, todo models.Todo) (models.Todo, error) {
+	s.calls++
+	return todo, nil
+}
+
+func (s *stubService) Update(_ context.Context, todo models.Todo) (models.Todo, error) {
+	s.calls++
+	return todo, nil
+}
+
+func (s *stubService) Patch(_ context.Context, _, _ int, _ models.PatchType, _ []byte) (models.Todo, error) {
+	s.calls++
+	return models.Todo{}, nil
+}
+
+func (s *stubService) Delete(_ context.Context, _, _ int, _ models.ChildrenMode) error {
+	s.calls++
+	return nil
+}
+
+func (s *stubService) HardDelete(_ context.Context, _, _ int, _ models.ChildrenMode) error {
+	s.calls++
+	return nil
+}
+
+func (s *stubService) Restore(_ context.Context, _, _ int) (models.Todo, error) {
+	s.calls++
+	return models.Todo{}, nil
+}
+
+func (s *stubService) ListTrash(_ context.Context) ([]models.Todo, error) {
+	s.calls++
+	return nil, nil
+}
+
+func (s *stubService) Tags(_ context.Context) ([]models.TagCount, error) {
+	s.calls++
+	return nil, nil
+}
+
+func (s *stubService) History(_ context.Context, _ int) ([]models.Revision, error) {
+	s.calls++
+	return nil, nil
+}
+
+func (s *stubService) Children(_ context.Context, _ int) ([]models.Todo, error) {
+	s.calls++
+	return nil, nil
+}
+
+func (s *stubService) Expand(_ context.Context, todos []models.Todo) ([]models.TodoTree, error) {
+	s.calls++
+	return make([]models.TodoTree, len(todos)), nil
+}
+
+func (s *stubService) Revision(_ context.Context, _, _, _ int) (models.Revision, error) {
+	s.calls++
+	return models.Revision{}, nil
+}
+
+func (s *stubService) Revert(_ context.Context, _, _, _ int) (models.Todo, error) {
+	s.calls++
+	return models.Todo{}, nil
+}
+
+func (s *stubService) Batch(_ context.Context, ops []models.BatchOperation, _ bool) ([]models.BatchResult, error) {
+	s.calls++
+	return make([]models.BatchResult, len(ops)), nil
+}
+
+func (s *stubService) List(_ context.Context, _ models.TodoQuery) (models.TodoPage, error) {
+	s.calls++
+	return models.TodoPage{}, nil
+}
+
+func (s *stubService) GetByID(_ context.Context, _ int) (models.Todo, error) {
+	s.calls++
+	return models.Todo{}, nil
+}
+
+func (s *stubService) Subscribe(_ context.Context, _ uint64) (*events.Subscription, error) {
+	s.calls++
+	return nil, nil
+}
+
+func TestTodoService(t *testing.T) {
+	roles := Roles{
+		Subjects: map[string]Role{"vera": RoleViewer, "ed": RoleEditor, "root": RoleAdmin},
+		Default:  RoleViewer,
+	}
+
+	read := func(s *TodoService, ctx context.Context) error {
+		_, err := s.List(ctx, models.TodoQuery{})
+		return err
+	}
+	create := func(s *TodoService, ctx context.Context) error {
+		_, err := s.Create(ctx, models.Todo{Title: "задача"})
+		return err
+	}
+	createWithID := func(s *TodoService, ctx context.Context) error {
+		_, err := s.Create(ctx, models.Todo{ID: 10, Title: "импорт"})
+		return err
+	}
+	deleteOne := func(s *TodoService, ctx context.Context) error {
+		return s.Delete(ctx, 1, 0, "")
+	}
+	deleteCascade := func(s *TodoService, ctx context.Context) error {
+		return s.Delete(ctx, 1, 0, models.ChildrenCascade)
+	}
+	batchUpdate := func(s *TodoService, ctx context.Context) error {
+		_, err := s.Batch(ctx, []models.BatchOperation{
+			{Op: models.BatchCreate, Todo: &models.Todo{Title: "задача"}},
+			{Op: models.BatchUpdate, ID: 1, Todo: &models.Todo{Title: "другая"}},
+		}, true)
+		return err
+	}
+	batchDelete := func(s *TodoService, ctx context.Context) error {
+		_, err := s.Batch(ctx, []models.BatchOperation{
+			{Op: models.BatchCreate, Todo: &models.Todo{Title: "задача"}},
+			{Op: models.BatchDelete, ID: 1},
+		}, false)
+		return err
+	}
+	batchWithID := func(s *TodoService, ctx context.Context) error {
+		_, err := s.Batch(ctx, []models.BatchOperation{
+			{Op: models.BatchCreate, Todo: &models.Todo{ID: 5, Title: "импорт"}},
+		}, false)
+		return err
+	}
+
+	hardDelete := func(s *TodoService, ctx context.Context) error {
+		return s.HardDelete(ctx, 1, 0, models.ChildrenDetach)
+	}
+	restore := func(s *TodoService, ctx context.Context) error {
+		_, err := s.Restore(ctx, 1, 0)
+		return err
+	}
+
+	tags := func(s *TodoService, ctx context.Context) error {
+		_, err := s.Tags(ctx)
+		return err
+	}
+	history := func(s *TodoService, ctx context.Context) error {
+		_, err := s.History(ctx, 1)
+		return err
+	}
+	children := func(s *TodoService, ctx context.Context) error {
+		_, err := s.Children(ctx, 1)
+		return err
+	}
+	revert := func(s *TodoService, ctx context.Context) error {
+		_, err := s.Revert(ctx, 1, 1, 0)
+		return err
+	}
+
+	subscribe := func(s *TodoService, ctx context.Context) error {
+		_, err := s.Subscribe(ctx, 0)
+		return err
+	}
+
+	cases := []struct {
+		name      string
+		subject   string
+		anonymous bool
+		call      func(*TodoService, context.Context) error
+		wantErr   error
+	}{
+		{name: "viewer читает", subject: "vera", call: read},
+		{name: "viewer подписывается на события", subject: "vera", call: subscribe},
+		{name: "viewer не создает", subject: "vera", call: create, wantErr: models.ErrForbidden},
+		{name: "viewer не удаляет", subject: "vera", call: deleteOne, wantErr: models.ErrForbidden},
+		{name: "editor создает", subject: "ed", call: create},
+		{name: "editor удаляет одну задачу", subject: "ed", call: deleteOne},
+		{name: "editor не удаляет каскадом", subject: "ed", call: deleteCascade, wantErr: models.ErrForbidden},
+		{name: "admin удаляет каскадом", subject: "root", call: deleteCascade},
+		{name: "editor изменяет пакетом", subject: "ed", call: batchUpdate},
+		{name: "editor не удаляет пакетом", subject: "ed", call: batchDelete, wantErr: models.ErrForbidden},
+		{name: "editor не задает id", subject: "ed", call: createWithID, wantErr: models.ErrForbidden},
+		{name: "editor не задает id в пакете", subject: "ed", call: batchWithID, wantErr: models.ErrForbidden},
+		{name: "admin задает id", subject: "root", call: createWithID},
+		{name: "admin удаляет пакетом", subject: "root", call: batchDelete},
+		{name: "editor восстанавливает из корзины", subject: "ed", call: restore},
+		{name: "viewer не восстанавливает из корзины", subject: "vera", call: restore, wantErr: models.ErrForbidden},
+		{name: "editor не удаляет окончательно", subject: "ed", call: hardDelete, wantErr: models.ErrForbidden},
+		{name: "admin удаляет окончательно", subject: "root", call: hardDelete},
+		{name: "viewer читает теги", subject: "vera", call: tags},
+		{name: "viewer читает историю", subject: "vera", call: history},
+		{name: "viewer читает подзадачи", subject: "vera", call: children},
+		{name: "viewer не откатывает к ревизии", subject: "vera", call: revert, wantErr: models.ErrForbidden},
+		{name: "editor откатывает к ревизии", subject: "ed", call: revert},
+		{name: "роль по умолчанию", subject: "guest", call: read},
+		{name: "роль по умолчанию не пишет", subject: "guest", call: create, wantErr: models.ErrForbidden},
+		{name: "без пользователя", anonymous: true, call: read, wantErr: models.ErrForbidden},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			next := &stubService{}
+			service := NewTodoService(next, roles)
+
+			ctx := context.Background()
+			if !tc.anonymous {
+				ctx = auth.NewContext(ctx, auth.Principal{Subject: tc.subject, Method: auth.MethodAPIKey})
+			}
+
+			err := tc.call(service, ctx)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("ожидалась ошибка %v, получено %v", tc.wantErr, err)
+			}
+
+			wantCalls := 1
+			if tc.wantErr != nil {
+				wantCalls = 0
+			}
+			if next.calls != wantCalls {
+				t.Fatalf("ожидалось %d вызовов сервиса, получено %d", wantCalls, next.calls)
+			}
+		})
+	}
+}
+
+func TestRolesWithoutDefault(t *testing.T) {
+	service := NewTodoService(&stubService{}, Roles{Subjects: map[string]Role{"root": RoleAdmin}})
+	ctx := auth.NewContext(context.Background(), auth.Principal{Subject: "guest", Method: auth.MethodJWT})
+
+	if _, err := service.GetByID(ctx, 1); !errors.Is(err, models.ErrForbidden) {
+		t.Fatalf("ожидалась ошибка %v для пользователя без роли, получено %v", models.ErrForbidden, err)
+	}
+}
+
+// stubWebhooks считает вызовы, дошедшие до сервиса подписок.
+type stubWebhooks struct {
+	calls int
+}
+
+func (s *stubWebhooks) Create(_ context.Context, webhook models.Webhook) (models.Webhook, error) {
+	s.calls++
+	return webhook, nil
+}
+
+func (s *stubWebhooks) Update(_ context.Context, webhook models.Webhook) (models.Webhook, error) {
+	s.calls++
+	return webhook, nil
+}
+
+func (s *stubWebhooks) Delete(_ context.Context, _ int) error {
+	s.calls++
+	return nil
+}
+
+func (s *stubWebhooks) List(_ context.Context) ([]models.Webhook, error) {
+	s.calls++
+	return nil, nil
+}
+
+func (s *stubWebhooks) GetByID(_ context.Context, _ int) (models.Webhook, error) {
+	s.calls++
+	return models.Webhook{}, nil
+}
+
+func (s *stubWebhooks) Deliveries(_ context.Context, _ int) ([]models.WebhookDelivery, error) {
+	s.calls++
+	return nil, nil
+}
+
+func TestWebhookService(t *testing.T) {
+	roles := Roles{Subjects: map[string]Role{"vera": RoleViewer, "ed": RoleEditor}}
+
+	create := func(s *WebhookService, ctx context.Context) error {
+		_, err := s.Create(ctx, models.Webhook{URL: "https://example.com/hook"})
+		return err
+	}
+	deliveries := func(s *WebhookService, ctx context.Context) error {
+		_, err := s.Deliveries(ctx, 1)
+		return err
+	}
+
+	cases := []struct {
+		name    string
+		subject string
+		call    func(*WebhookService, context.Context) error
+		wantErr error
+	}{
+		{name: "viewer читает журнал доставок", subject: "vera", call: deliveries},
+		{name: "viewer не создает подписку", subject: "vera", call: create, wantErr: models.ErrForbidden},
+		{name: "editor создает подписку", subject: "ed", call: create},
+		{name: "пользователь без роли", subject: "guest", call: deliveries, wantErr: models.ErrForbidden},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			next := &stubWebhooks{}
+			service := NewWebhookService(next, roles)
+			ctx := auth.NewContext(context.Background(), auth.Principal{Subject: tc.subject, Method: auth.MethodAPIKey})
+
+			err := tc.call(service, ctx)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("ожидалась ошибка %v, получено %v", tc.wantErr, err)
+			}
+
+			wantCalls := 1
+			if tc.wantErr != nil {
+				wantCalls = 0
+			}
+			if next.calls != wantCalls {
+				t.Fatalf("ожидалось %d вызовов сервиса, получено %d", wantCalls, next.calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package policy
+
+import (
+	"context"
+
+	"github.com/RoGogDBD/ecom/internal/models"
+)
+
+type (
+	// Webhooks операции с подписками на webhook, которые защищает WebhookService.
+	Webhooks interface {
+		Create(ctx context.Context, webhook models.Webhook) (models.Webhook, error)
+		Update(ctx context.Context, webhook models.Webhook) (models.Webhook, error)
+		Delete(ctx context.Context, id int) error
+		List(ctx context.Context) ([]models.Webhook, error)
+		GetByID(ctx context.Context, id int) (models.Webhook, error)
+		Deliveries(ctx context.Context, id int) ([]models.WebhookDelivery, error)
+	}
+
+	// WebhookService проверяет права пользователя из контекста перед вызовом next:
+	// просмотр подписок и журнала доставок требует права на чтение задач,
+	// управление подписками — права на изменение.
+	WebhookService struct {
+		next  Webhooks
+		roles Roles
+	}
+)
+
+// NewWebhookService оборачивает next проверкой прав по ролям roles.
+func NewWebhookService(next Webhooks, roles Roles) *WebhookService {
+	return &WebhookService{next: next, roles: roles}
+}
+
+func (s *WebhookService) Create(ctx context.Context, webhook models.Webhook) (models.Webhook, error) {
+	if err := s.roles.authorize(ctx, permWrite); err != nil {
+		return models.Webhook{}, err
+	}
+
+	return s.next.Create(ctx, webhook)
+}
+
+func (s *WebhookService) Update(ctx context.Context, webhook models.Webhook) (models.Webhook, error) {
+	if err := s.roles.authorize(ctx, permWrite); err != nil {
+		return models.Webhook{}, err
+	}
+
+	return s.next.Update(ctx, webhook)
+}
+
+func (s *WebhookService) Delete(ctx context.Context, id int) error {
+	if err := s.roles.authorize(ctx, permWrite); err != nil {
+		return err
+	}
+
+	return s.next.Delete(ctx, id)
+}
+
+func (s *WebhookService) List(ctx context.Context) ([]models.Webhook, error) {
+	if err := s.roles.authorize(ctx, permRead); err != nil {
+		return nil, err
+	}
+
+	return s.next.List(ctx)
+}
+
+func (s *WebhookService) GetByID(ctx context.Context, id int) (models.Webhook, error) {
+	if err := s.roles.authorize(ctx, permRead); err != nil {
+		return models.Webhook{}, err
+	}
+
+	return s.next.GetByID(ctx, id)
+}
+
+func (s *WebhookService) Deliveries(ctx context.Context, id int) ([]models.WebhookDelivery, error) {
+	if err := s.roles.authorize(ctx, permRead); err != nil {
+		return nil, err
+	}
+
+	return s.next.Deliveries(ctx, id)
+}
@@ -0,0 +1,178 @@
+// Package ratelimit реализует ограничение частоты запросов алгоритмом token bucket.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+type (
+	// Limit параметры корзины: Rate токенов в секунду, не более Burst токенов в запасе.
+	// Нулевой Rate означает отсутствие ограничения.
+	Limit struct {
+		Rate  float64
+		Burst int
+	}
+
+	// Decision результат проверки запроса.
+	Decision struct {
+		// Allowed запрос разрешен и списал один токен.
+		Allowed bool
+		// Remaining целое число токенов, оставшихся после запроса.
+		Remaining int
+		// Reset через сколько корзина наполнится полностью.
+		Reset time.Duration
+		// RetryAfter через сколько появится токен; ненулевой только для отклоненных запросов.
+		RetryAfter time.Duration
+	}
+
+	// Limiter набор корзин, по одной на ключ (например, клиент и маршрут).
+	// Корзины, к которым не обращались дольше idleTTL, удаляются в фоне.
+	Limiter struct {
+		idleTTL time.Duration
+		now     func() time.Time
+
+		mu      sync.Mutex
+		buckets map[string]*bucket
+
+		stop      chan struct{}
+		done      chan struct{}
+		closeOnce sync.Once
+	}
+
+	bucket struct {
+		tokens float64
+		last   time.Time
+	}
+)
+
+// Unlimited сообщает, что лимит не ограничивает запросы.
+func (l Limit) Unlimited() bool {
+	return l.Rate <= 0
+}
+
+// New создает Limiter и запускает фоновое удаление простаивающих корзин.
+// idleTTL стоит выбирать не меньше времени полного наполнения самой медленной корзины
+// (Burst / Rate): тогда удаляется только корзина, которая и так была бы полной.
+func New(idleTTL time.Duration) *Limiter {
+	l := newLimiter(idleTTL, time.Now)
+
+	l.stop = make(chan struct{})
+	l.done = make(chan struct{})
+	go l.evictLoop()
+
+	return l
+}
+
+func newLimiter(idleTTL time.Duration, now func() time.Time) *Limiter {
+	return &Limiter{
+		idleTTL: idleTTL,
+		now:     now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Allow списывает токен из корзины key с параметрами limit, если он есть.
+func (l *Limiter) Allow(key string, limit Limit) Decision {
+	if limit.Unlimited() {
+		return Decision{Allowed: true, Remaining: limit.Burst}
+	}
+
+	burst := float64(max(limit.Burst, 1))
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[key] = b
+	}
+
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(burst, b.tokens+elapsed*limit.Rate)
+	}
+	b.last = now
+
+	var d Decision
+	if b.tokens >= 1 {
+		b.tokens--
+		d.Allowed = true
+	} else {
+		d.RetryAfter = seconds((1 - b.tokens) / limit.Rate)
+	}
+	d.Remaining = int(b.tokens)
+	d.Reset = seconds((burst - b.tokens) / limit.Rate)
+
+	return d
+}
+
+// Refund возвращает в корзину key токен, списанный Allow, не превышая Burst. Так запрос,
+// который в итоге не должен учитываться, не расходует лимит, а одновременные запросы
+// все равно не могут превысить Burst, пока выполняются.
+func (l *Limiter) Refund(key string, limit Limit) {
+	if limit.Unlimited() {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if b, ok := l.buckets[key]; ok {
+		b.tokens = math.Min(float64(max(limit.Burst, 1)), b.tokens+1)
+	}
+}
+
+// Len возвращает количество корзин.
+func (l *Limiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return len(l.buckets)
+}
+
+// Close останавливает фоновое удаление корзин.
+func (l *Limiter) Close() {
+	l.closeOnce.Do(func() {
+		if l.stop != nil {
+			close(l.stop)
+			<-l.done
+		}
+	})
+}
+
+func (l *Limiter) evictLoop() {
+	defer close(l.done)
+
+	ticker := time.NewTicker(max(l.idleTTL/2, time.Second))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			l.evict()
+		}
+	}
+}
+
+// evict удаляет корзины, к которым не обращались дольше idleTTL.
+func (l *Limiter) evict() {
+	cutoff := l.now().Add(-l.idleTTL)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for key, b := range l.buckets {
+		if b.last.Before(cutoff) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,128 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func TestLimiter_Allow(t *testing.T) {
+	clock := &testClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := newLimiter(time.Minute, clock.Now)
+	limit := Limit{Rate: 2, Burst: 3}
+
+	steps := []struct {
+		name        string
+		advance     time.Duration
+		wantAllowed bool
+		wantRemain  int
+		wantRetry   time.Duration
+	}{
+		{name: "полная корзина", wantAllowed: true, wantRemain: 2},
+		{name: "второй запрос", wantAllowed: true, wantRemain: 1},
+		{name: "третий запрос", wantAllowed: true, wantRemain: 0},
+		{name: "корзина пуста", wantAllowed: false, wantRemain: 0, wantRetry: 500 * time.Millisecond},
+		{name: "токен восстановился", advance: 500 * time.Millisecond, wantAllowed: true, wantRemain: 0},
+		{name: "наполнение не выше burst", advance: time.Hour, wantAllowed: true, wantRemain: 2},
+	}
+
+	for _, step := range steps {
+		clock.now = clock.now.Add(step.advance)
+		d := l.Allow("client", limit)
+		if d.Allowed != step.wantAllowed || d.Remaining != step.wantRemain || d.RetryAfter != step.wantRetry {
+			t.Errorf("%s: Allow() = %+v, ожидалось allowed=%v remaining=%d retry=%v",
+				step.name, d, step.wantAllowed, step.wantRemain, step.wantRetry)
+		}
+	}
+}
+
+func TestLimiter_KeysAreIndependent(t *testing.T) {
+	clock := &testClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := newLimiter(time.Minute, clock.Now)
+	limit := Limit{Rate: 1, Burst: 1}
+
+	if !l.Allow("a", limit).Allowed {
+		t.Fatal("первый запрос клиента a должен быть разрешен")
+	}
+	if l.Allow("a", limit).Allowed {
+		t.Fatal("второй запрос клиента a должен быть отклонен")
+	}
+	if !l.Allow("b", limit).Allowed {
+		t.Fatal("запрос клиента b не должен зависеть от клиента a")
+	}
+}
+
+func TestLimiter_Unlimited(t *testing.T) {
+	l := newLimiter(time.Minute, time.Now)
+	for range 100 {
+		if !l.Allow("client", Limit{}).Allowed {
+			t.Fatal("запрос без лимита должен быть разрешен")
+		}
+	}
+	if l.Len() != 0 {
+		t.Errorf("для запросов без лимита не должны создаваться корзины, получено %d", l.Len())
+	}
+}
+
+func TestLimiter_Evict(t *testing.T) {
+	clock := &testClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := newLimiter(time.Minute, clock.Now)
+	limit := Limit{Rate: 1, Burst: 1}
+
+	l.Allow("idle", limit)
+	clock.now = clock.now.Add(45 * time.Second)
+	l.Allow("active", limit)
+	clock.now = clock.now.Add(30 * time.Second)
+
+	l.evict()
+	if l.Len() != 1 {
+		t.Fatalf("ожидалась 1 корзина после удаления простаивающих, получено %d", l.Len())
+	}
+	if l.Allow("active", limit).Remaining != 0 {
+		t.Error("активная корзина должна сохранить состояние")
+	}
+}
+
+func TestLimiter_Close(t *testing.T) {
+	l := New(time.Minute)
+	l.Close()
+	l.Close()
+}
+
+func TestLimiter_Refund(t *testing.T) {
+	clock := &testClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := newLimiter(time.Minute, clock.Now)
+	limit := Limit{Rate: 1, Burst: 2}
+
+	l.Allow("client", limit)
+	l.Allow("client", limit)
+	if l.Allow("client", limit).Allowed {
+		t.Fatal("запрос сверх burst должен быть отклонен")
+	}
+
+	l.Refund("client", limit)
+	if d := l.Allow("client", limit); !d.Allowed || d.Remaining != 0 {
+		t.Fatalf("после возврата токена Allow() = %+v, ожидался разрешенный запрос", d)
+	}
+
+	// Возврат не наполняет корзину выше burst.
+	for range 5 {
+		l.Refund("client", limit)
+	}
+	if d := l.Allow("client", limit); d.Remaining != 1 {
+		t.Fatalf("Allow() = %+v, ожидалось remaining=1", d)
+	}
+
+	// Возврат в отсутствующую корзину ничего не создает.
+	l.Refund("other", limit)
+	if l.Len() != 1 {
+		t.Fatalf("ожидалась 1 корзина, получено %d", l.Len())
+	}
+}
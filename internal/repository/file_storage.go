@@ -0,0 +1,463 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/RoGogDBD/ecom/internal/models"
+)
+
+const (
+	journalFileName  = "journal.log"
+	snapshotFileName = "snapshot.json"
+	snapshotTmpName  = "snapshot.json.tmp"
+	dataDirMode      = 0755
+	dataFileMode     = 0644
+
+	errCreateDataDir  = "failed to create storage directory: %w"
+	errOpenJournal    = "failed to open journal: %w"
+	errReadJournal    = "failed to read journal: %w"
+	errCorruptJournal = "journal is corrupted at offset %d: %w"
+	errWriteJournal   = "failed to write journal: %w"
+	errSyncJournal    = "failed to sync journal: %w"
+	errReadSnapshot   = "failed to read snapshot: %w"
+	errWriteSnapshot  = "failed to write snapshot: %w"
+)
+
+// SyncPolicy определяет, когда журнал сбрасывается на диск через fsync.
+type SyncPolicy string
+
+const (
+	// SyncAlways fsync после каждой записи: максимальная надежность, минимальная скорость.
+	SyncAlways SyncPolicy = "always"
+	// SyncInterval fsync в фоне раз в FileOptions.SyncInterval.
+	SyncInterval SyncPolicy = "interval"
+	// SyncNever сброс на диск остается на усмотрение ОС.
+	SyncNever SyncPolicy = "never"
+)
+
+// ErrStorageClosed возвращается при записи в закрытое файловое хранилище.
+var ErrStorageClosed = errors.New("storage is closed")
+
+type (
+	// FileOptions параметры файлового хранилища.
+	FileOptions struct {
+		// Dir директория для журнала и снимка.
+		Dir string
+		// Sync политика fsync журнала.
+		Sync SyncPolicy
+		// SyncInterval период фонового fsync для SyncInterval.
+		SyncInterval time.Duration
+		// SnapshotEvery количество записей журнала, после которого он сжимается в снимок.
+		// 0 отключает автоматическое сжатие (снимок пишется только при Close).
+		SnapshotEvery int
+	}
+
+	// FileStorage долговременное хранилище поверх TodoStorage.
+	// Каждое изменение дописывается в журнал (write-ahead log) до применения в памяти,
+	// журнал периодически сжимается в снимок, при старте снимок и журнал проигрываются заново.
+	FileStorage struct {
+		*TodoStorage
+
+		opts FileOptions
+
+		// fileMu защищает поля ниже. Захватывается после TodoStorage.mu.
+		fileMu  sync.Mutex
+		file    journalFile
+		size    int64
+		entries int
+		dirty   bool
+		// failed первая ошибка фонового fsync. После нее запись запрещена,
+		// так как неизвестно, какие данные реально попали на диск.
+		failed error
+
+		stop      chan struct{}
+		done      chan struct{}
+		closeOnce sync.Once
+	}
+
+	// journalFile открытый файл журнала; в тестах подменяется для имитации сбоев диска.
+	journalFile interface {
+		io.WriteCloser
+		Truncate(size int64) error
+		Sync() error
+	}
+
+	// snapshot содержимое файла снимка.
+	snapshot struct {
+		// LastID последний ID владельца по умолчанию в снимках, записанных
+		// до появления владельцев. Новые снимки используют LastIDs.
+		LastID int `json:"last_id,omitempty"`
+		// LastIDs последние выданные ID по владельцам.
+		LastIDs map[string]int `json:"last_ids,omitempty"`
+		Items   []models.Todo  `json:"items"`
+		// History предыдущие ревизии задач; текущие ревизии хранятся в Items.
+		History []models.Todo `json:"history,omitempty"`
+	}
+)
+
+// OpenFileStorage открывает (или создает) файловое хранилище в opts.Dir
+// и восстанавливает состояние из снимка и журнала.
+func OpenFileStorage(opts FileOptions) (*FileStorage, error) {
+	if err := os.MkdirAll(opts.Dir, dataDirMode); err != nil {
+		return nil, fmt.Errorf(errCreateDataDir, err)
+	}
+
+	s := &FileStorage{
+		TodoStorage: NewTodoStorage(),
+		opts:        opts,
+	}
+
+	if err := s.loadSnapshot(); err != nil {
+		return nil, err
+	}
+
+	if err := s.openJournal(); err != nil {
+		return nil, err
+	}
+
+	s.journal = s
+
+	if opts.Sync == SyncInterval && opts.SyncInterval > 0 {
+		s.stop = make(chan struct{})
+		s.done = make(chan struct{})
+		go s.syncLoop()
+	}
+
+	return s, nil
+}
+
+// Close останавливает фоновый fsync, сжимает журнал в снимок и закрывает файлы.
+func (s *FileStorage) Close() error {
+	var err error
+
+	s.closeOnce.Do(func() {
+		if s.stop != nil {
+			close(s.stop)
+			<-s.done
+		}
+
+		s.lock(metricOpClose)
+		defer s.mu.Unlock()
+		s.fileMu.Lock()
+		defer s.fileMu.Unlock()
+
+		err = errors.Join(s.compactLocked(), s.file.Close())
+		s.file = nil
+	})
+
+	return err
+}
+
+// Check сообщает о состоянии хранилища: ошибка, если оно закрыто или запись
+// в журнал запрещена после сбоя fsync.
+func (s *FileStorage) Check(_ context.Context) error {
+	s.fileMu.Lock()
+	defer s.fileMu.Unlock()
+
+	if s.file == nil {
+		return ErrStorageClosed
+	}
+
+	return s.failed
+}
+
+// append реализует journal: дописывает изменения в конец журнала.
+func (s *FileStorage) append(records ...record) error {
+	s.fileMu.Lock()
+	defer s.fileMu.Unlock()
+
+	if s.file == nil {
+		return ErrStorageClosed
+	}
+	if s.failed != nil {
+		return s.failed
+	}
+
+	// Снимок делается до записи текущего изменения: он отражает состояние в памяти,
+	// а само изменение попадает уже в новый, пустой журнал.
+	if s.opts.SnapshotEvery > 0 && s.entries >= s.opts.SnapshotEvery {
+		if err := s.compactLocked(); err != nil {
+			return err
+		}
+	}
+
+	// Несколько изменений пишутся одной строкой: при обрыве записи недописанная
+	// строка отбрасывается целиком, и набор не применяется частично.
+	line := records[0]
+	if len(records) > 1 {
+		line = record{Op: opBatch, Records: records}
+	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(line); err != nil {
+		return fmt.Errorf(errWriteJournal, err)
+	}
+
+	n, err := s.file.Write(buf.Bytes())
+	if err != nil {
+		// Отрезаем частично записанную строку, чтобы следующие записи не оказались за ней.
+		if n > 0 {
+			_ = s.file.Truncate(s.size)
+		}
+		return fmt.Errorf(errWriteJournal, err)
+	}
+
+	if s.opts.Sync == SyncAlways {
+		if err := s.file.Sync(); err != nil {
+			// Изменение не применяется в памяти, поэтому его строка убирается и из журнала,
+			// иначе она вернулась бы после перезапуска.
+			_ = s.file.Truncate(s.size)
+			s.failed = fmt.Errorf(errSyncJournal, err)
+			return s.failed
+		}
+	} else {
+		s.dirty = true
+	}
+
+	s.size += int64(n)
+	s.entries++
+	return nil
+}
+
+// syncLoop периодически сбрасывает журнал на диск для политики SyncInterval.
+func (s *FileStorage) syncLoop() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.opts.SyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.syncIfDirty()
+		}
+	}
+}
+
+func (s *FileStorage) syncIfDirty() {
+	s.fileMu.Lock()
+	defer s.fileMu.Unlock()
+
+	if s.file == nil || !s.dirty || s.failed != nil {
+		return
+	}
+
+	if err := s.file.Sync(); err != nil {
+		s.failed = fmt.Errorf(errSyncJournal, err)
+		return
+	}
+	s.dirty = false
+}
+
+// compactLocked записывает текущее состояние в снимок и очищает журнал.
+// Вызывается под s.mu и s.fileMu.
+func (s *FileStorage) compactLocked() error {
+	snap := snapshot{
+		LastIDs: make(map[string]int, len(s.tenants)),
+		Items:   make([]models.Todo, 0, s.count()+s.countTrash()),
+	}
+	for owner, tn := range s.tenants {
+		snap.LastIDs[owner] = tn.lastID
+		for _, todo := range tn.items {
+			snap.Items = append(snap.Items, todo)
+		}
+		// Задачи в корзине отличаются по deleted_at и при загрузке возвращаются в корзину.
+		for _, todo := range tn.trash {
+			snap.Items = append(snap.Items, todo)
+		}
+		for _, revisions := range tn.history {
+			snap.History = append(snap.History, revisions[:len(revisions)-1]...)
+		}
+	}
+	sortSnapshotItems(snap.Items)
+	sortSnapshotItems(snap.History)
+
+	if err := s.writeSnapshot(snap); err != nil {
+		return err
+	}
+
+	// Если процесс упадет между записью снимка и очисткой журнала, при старте журнал
+	// проиграется поверх снимка повторно. Это безопасно: записи содержат итоговое
+	// состояние объекта, а не дельту, поэтому их повторное применение идемпотентно.
+	if err := s.file.Truncate(0); err != nil {
+		return fmt.Errorf(errWriteJournal, err)
+	}
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf(errSyncJournal, err)
+	}
+
+	s.size = 0
+	s.entries = 0
+	s.dirty = false
+	return nil
+}
+
+// sortSnapshotItems упорядочивает задачи снимка по владельцу, ID и версии,
+// чтобы содержимое снимка не зависело от порядка обхода map.
+func sortSnapshotItems(items []models.Todo) {
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Owner != items[j].Owner {
+			return items[i].Owner < items[j].Owner
+		}
+		if items[i].ID != items[j].ID {
+			return items[i].ID < items[j].ID
+		}
+		return items[i].Version < items[j].Version
+	})
+}
+
+// writeSnapshot атомарно заменяет файл снимка: пишет во временный файл и переименовывает его.
+func (s *FileStorage) writeSnapshot(snap snapshot) (err error) {
+	tmpPath := filepath.Join(s.opts.Dir, snapshotTmpName)
+
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, dataFileMode)
+	if err != nil {
+		return fmt.Errorf(errWriteSnapshot, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmpPath)
+		}
+	}()
+
+	if err = json.NewEncoder(tmp).Encode(snap); err != nil {
+		return fmt.Errorf(errWriteSnapshot, err)
+	}
+	if err = tmp.Sync(); err != nil {
+		return fmt.Errorf(errWriteSnapshot, err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf(errWriteSnapshot, err)
+	}
+	if err = os.Rename(tmpPath, filepath.Join(s.opts.Dir, snapshotFileName)); err != nil {
+		return fmt.Errorf(errWriteSnapshot, err)
+	}
+
+	return syncDir(s.opts.Dir)
+}
+
+// loadSnapshot загружает последний снимок, если он есть.
+func (s *FileStorage) loadSnapshot() error {
+	data, err := os.ReadFile(filepath.Join(s.opts.Dir, snapshotFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf(errReadSnapshot, err)
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf(errReadSnapshot, err)
+	}
+
+	// История восстанавливается первой: текущие ревизии из Items дописываются после нее.
+	for _, todo := range snap.History {
+		s.tenant(todo.Owner).remember(todo)
+	}
+	for _, todo := range snap.Items {
+		s.apply(record{Op: opPut, Todo: todo})
+	}
+	if snap.LastID > 0 {
+		s.restoreLastID("", snap.LastID)
+	}
+	for owner, lastID := range snap.LastIDs {
+		s.restoreLastID(owner, lastID)
+	}
+
+	return nil
+}
+
+// restoreLastID восстанавливает последовательность ID владельца, если она ушла дальше его задач.
+func (s *FileStorage) restoreLastID(owner string, lastID int) {
+	if tn := s.tenant(owner); lastID > tn.lastID {
+		tn.lastID = lastID
+	}
+}
+
+// openJournal открывает журнал, проигрывает его записи и оставляет файл открытым для дозаписи.
+// Недописанная последняя строка (например, после падения посреди записи) отбрасывается.
+func (s *FileStorage) openJournal() error {
+	path := filepath.Join(s.opts.Dir, journalFileName)
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, dataFileMode)
+	if err != nil {
+		return fmt.Errorf(errOpenJournal, err)
+	}
+
+	size, entries, err := s.replay(file)
+	if err == nil {
+		err = file.Truncate(size)
+	}
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	s.file = file
+	s.size = size
+	s.entries = entries
+	return nil
+}
+
+// replay применяет записи журнала и возвращает длину корректной части файла.
+func (s *FileStorage) replay(r io.Reader) (int64, int, error) {
+	reader := bufio.NewReader(r)
+
+	var (
+		offset  int64
+		entries int
+	)
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// Хвост без перевода строки — незавершенная запись, отбрасываем.
+			return offset, entries, nil
+		}
+		if err != nil {
+			return 0, 0, fmt.Errorf(errReadJournal, err)
+		}
+
+		var rec record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			if _, peekErr := reader.Peek(1); errors.Is(peekErr, io.EOF) {
+				return offset, entries, nil
+			}
+			return 0, 0, fmt.Errorf(errCorruptJournal, offset, err)
+		}
+
+		s.apply(rec)
+		offset += int64(len(line))
+		entries++
+	}
+}
+
+// syncDir сбрасывает на диск метаданные директории, чтобы переименование файла пережило сбой.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf(errWriteSnapshot, err)
+	}
+	defer d.Close()
+
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf(errWriteSnapshot, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,394 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/RoGogDBD/ecom/internal/models"
+)
+
+func openTestFileStorage(t *testing.T, dir string, snapshotEvery int) *FileStorage {
+	t.Helper()
+
+	storage, err := OpenFileStorage(FileOptions{
+		Dir:           dir,
+		Sync:          SyncAlways,
+		SnapshotEvery: snapshotEvery,
+	})
+	if err != nil {
+		t.Fatalf("не удалось открыть хранилище: %v", err)
+	}
+
+	return storage
+}
+
+func TestFileStorageRestoresState(t *testing.T) {
+	cases := []struct {
+		name          string
+		snapshotEvery int
+		closeBefore   bool
+	}{
+		{name: "только журнал, без закрытия", snapshotEvery: 0, closeBefore: false},
+		{name: "со сжатием в снимок", snapshotEvery: 2, closeBefore: false},
+		{name: "снимок при закрытии", snapshotEvery: 0, closeBefore: true},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			ctx := context.Background()
+
+			storage := openTestFileStorage(t, dir, tc.snapshotEvery)
+			for _, title := range []string{"первая", "вторая", "третья"} {
+				if _, err := storage.Create(ctx, models.Todo{Title: title}); err != nil {
+					t.Fatalf("ошибка создания: %v", err)
+				}
+			}
+			complete := func(todo models.Todo) (models.Todo, error) {
+				todo.Completed = true
+				return todo, nil
+			}
+			if _, err := storage.Modify(ctx, "", 2, complete); err != nil {
+				t.Fatalf("ошибка обновления: %v", err)
+			}
+			if _, err := storage.Delete(ctx, "", 3, 0, time.Time{}, models.ChildrenReject); err != nil {
+				t.Fatalf("ошибка удаления: %v", err)
+			}
+			if tc.closeBefore {
+				if err := storage.Close(); err != nil {
+					t.Fatalf("ошибка закрытия: %v", err)
+				}
+			}
+
+			reopened := openTestFileStorage(t, dir, tc.snapshotEvery)
+			defer reopened.Close()
+
+			items, err := reopened.GetAll(ctx, "")
+			if err != nil {
+				t.Fatalf("неожиданная ошибка: %v", err)
+			}
+			if len(items) != 2 {
+				t.Fatalf("ожидалось 2 задачи, получено %d", len(items))
+			}
+
+			got, err := reopened.GetByID(ctx, "", 2)
+			if err != nil {
+				t.Fatalf("ожидалась задача 2, получена ошибка: %v", err)
+			}
+			if !got.Completed || got.Version != 2 {
+				t.Fatalf("ожидалась завершенная задача версии 2, получено %+v", got)
+			}
+
+			// Удаленный ID не должен выдаваться повторно.
+			next, err := reopened.Create(ctx, models.Todo{Title: "четвертая"})
+			if err != nil {
+				t.Fatalf("ошибка создания: %v", err)
+			}
+			if next.ID != 4 {
+				t.Fatalf("ожидался id 4, получено %d", next.ID)
+			}
+		})
+	}
+}
+
+func TestFileStorageDropsTornTail(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	storage := openTestFileStorage(t, dir, 0)
+	if _, err := storage.Create(ctx, models.Todo{Title: "целая"}); err != nil {
+		t.Fatalf("ошибка создания: %v", err)
+	}
+
+	// Имитируем падение посреди записи: в конце журнала оказывается обрывок строки.
+	journalPath := filepath.Join(dir, journalFileName)
+	file, err := os.OpenFile(journalPath, os.O_WRONLY|os.O_APPEND, dataFileMode)
+	if err != nil {
+		t.Fatalf("не удалось открыть журнал: %v", err)
+	}
+	if _, err := file.WriteString(`{"op":"put","todo":{"id":2,"ti`); err != nil {
+		t.Fatalf("не удалось дописать журнал: %v", err)
+	}
+	_ = file.Close()
+
+	reopened := openTestFileStorage(t, dir, 0)
+	defer reopened.Close()
+
+	if _, err := reopened.GetByID(ctx, "", 2); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("ожидалась ошибка %v, получено %v", models.ErrNotFound, err)
+	}
+
+	created, err := reopened.Create(ctx, models.Todo{Title: "после сбоя"})
+	if err != nil {
+		t.Fatalf("ошибка создания: %v", err)
+	}
+	if created.ID != 2 {
+		t.Fatalf("ожидался id 2, получено %d", created.ID)
+	}
+}
+
+// failingSyncFile файл журнала, fsync которого всегда завершается ошибкой.
+type failingSyncFile struct {
+	journalFile
+}
+
+func (f failingSyncFile) Sync() error {
+	return errors.New("ошибка ввода-вывода")
+}
+
+func TestFileStorageSyncFailure(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	storage := openTestFileStorage(t, dir, 0)
+	file := storage.file
+	defer file.Close()
+	if _, err := storage.Create(ctx, models.Todo{Title: "сохранена"}); err != nil {
+		t.Fatalf("ошибка создания: %v", err)
+	}
+
+	storage.file = failingSyncFile{journalFile: file}
+	if _, err := storage.Create(ctx, models.Todo{Title: "не сохранена"}); err == nil {
+		t.Fatal("ожидалась ошибка fsync")
+	}
+	if _, err := storage.GetByID(ctx, "", 2); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("ожидалась ошибка %v, получено %v", models.ErrNotFound, err)
+	}
+	if err := storage.Check(ctx); err == nil {
+		t.Fatal("после сбоя fsync хранилище должно быть неисправно")
+	}
+	if _, err := storage.Create(ctx, models.Todo{Title: "после сбоя"}); err == nil {
+		t.Fatal("после сбоя fsync запись должна быть запрещена")
+	}
+
+	// Имитируем перезапуск после сбоя без Close: состояние восстанавливается только из журнала.
+	reopened := openTestFileStorage(t, dir, 0)
+	defer reopened.Close()
+
+	if _, err := reopened.GetByID(ctx, "", 1); err != nil {
+		t.Fatalf("ожидалась сохраненная задача, получена ошибка: %v", err)
+	}
+	if _, err := reopened.GetByID(ctx, "", 2); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("неудавшаяся запись вернулась после перезапуска: ожидалась ошибка %v, получено %v", models.ErrNotFound, err)
+	}
+}
+
+func TestFileStorageBatchIsSingleRecord(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	storage := openTestFileStorage(t, dir, 0)
+	muts := []models.Mutation{
+		{Kind: models.BatchCreate, Todo: models.Todo{Title: "первая"}},
+		{Kind: models.BatchCreate, Todo: models.Todo{Title: "вторая"}},
+	}
+	if _, err := storage.Apply(ctx, "", muts, true); err != nil {
+		t.Fatalf("ошибка пакета: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, journalFileName))
+	if err != nil {
+		t.Fatalf("не удалось прочитать журнал: %v", err)
+	}
+	if lines := bytes.Count(data, []byte("\n")); lines != 1 {
+		t.Fatalf("ожидалась одна строка журнала, получено %d", lines)
+	}
+
+	reopened := openTestFileStorage(t, dir, 0)
+	defer reopened.Close()
+
+	items, err := reopened.GetAll(ctx, "")
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("ожидалось 2 задачи, получено %d", len(items))
+	}
+}
+
+func TestFileStorageRestoresOwners(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	// Старый снимок без владельцев: last_id относится к владельцу по умолчанию.
+	legacy := `{"last_id":5,"items":[{"id":2,"title":"старая","version":1}]}`
+	if err := os.WriteFile(filepath.Join(dir, snapshotFileName), []byte(legacy), dataFileMode); err != nil {
+		t.Fatalf("не удалось записать снимок: %v", err)
+	}
+
+	storage := openTestFileStorage(t, dir, 1)
+	for _, owner := range []string{"", "alice", "alice"} {
+		if _, err := storage.Create(ctx, models.Todo{Title: "новая", Owner: owner}); err != nil {
+			t.Fatalf("ошибка создания: %v", err)
+		}
+	}
+	if _, err := storage.Delete(ctx, "alice", 2, 0, time.Time{}, models.ChildrenReject); err != nil {
+		t.Fatalf("ошибка удаления: %v", err)
+	}
+	if err := storage.Close(); err != nil {
+		t.Fatalf("ошибка закрытия: %v", err)
+	}
+
+	reopened := openTestFileStorage(t, dir, 0)
+	defer reopened.Close()
+
+	cases := []struct {
+		owner  string
+		wantID int
+	}{
+		{owner: "", wantID: 7},
+		{owner: "alice", wantID: 3},
+		{owner: "bob", wantID: 1},
+	}
+	for _, tc := range cases {
+		created, err := reopened.Create(ctx, models.Todo{Title: "после перезапуска", Owner: tc.owner})
+		if err != nil {
+			t.Fatalf("ошибка создания: %v", err)
+		}
+		if created.ID != tc.wantID {
+			t.Errorf("владелец %q: ожидался id %d, получено %d", tc.owner, tc.wantID, created.ID)
+		}
+	}
+
+	if _, err := reopened.GetByID(ctx, "alice", 1); err != nil {
+		t.Fatalf("ожидалась задача alice, получена ошибка: %v", err)
+	}
+	if _, err := reopened.GetByID(ctx, "alice", 2); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("ожидалась ошибка %v, получено %v", models.ErrNotFound, err)
+	}
+}
+
+func TestFileStorageClosed(t *testing.T) {
+	storage := openTestFileStorage(t, t.TempDir(), 0)
+	if err := storage.Check(context.Background()); err != nil {
+		t.Fatalf("открытое хранилище должно быть исправно, получено %v", err)
+	}
+	if err := storage.Close(); err != nil {
+		t.Fatalf("ошибка закрытия: %v", err)
+	}
+
+	_, err := storage.Create(context.Background(), models.Todo{Title: "поздно"})
+	if !errors.Is(err, ErrStorageClosed) {
+		t.Fatalf("ожидалась ошибка %v, получено %v", ErrStorageClosed, err)
+	}
+
+	if err := storage.Check(context.Background()); !errors.Is(err, ErrStorageClosed) {
+		t.Fatalf("Check() = %v, ожидалась ошибка %v", err, ErrStorageClosed)
+	}
+}
+
+func TestFileStorageRestoresTrash(t *testing.T) {
+	for _, snapshotEvery := range []int{0, 1} {
+		dir := t.TempDir()
+		ctx := context.Background()
+		deletedAt := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+
+		storage := openTestFileStorage(t, dir, snapshotEvery)
+		for _, title := range []string{"первая", "вторая", "третья"} {
+			if _, err := storage.Create(ctx, models.Todo{Title: title}); err != nil {
+				t.Fatalf("ошибка создания: %v", err)
+			}
+		}
+		for _, id := range []int{1, 2} {
+			if _, err := storage.SoftDelete(ctx, "", id, 0, deletedAt, models.ChildrenReject); err != nil {
+				t.Fatalf("ошибка удаления: %v", err)
+			}
+		}
+		if _, err := storage.Restore(ctx, "", 2, 0, deletedAt); err != nil {
+			t.Fatalf("ошибка восстановления: %v", err)
+		}
+		if err := storage.Close(); err != nil {
+			t.Fatalf("ошибка закрытия: %v", err)
+		}
+
+		reopened := openTestFileStorage(t, dir, 0)
+		items, _ := reopened.GetAll(ctx, "")
+		trash, _ := reopened.ListTrash(ctx, "")
+		if len(items) != 2 || len(trash) != 1 || trash[0].ID != 1 || !trash[0].DeletedAt.Equal(deletedAt) {
+			t.Fatalf("snapshot_every=%d: ожидались 2 активные задачи и задача 1 в корзине, получено %+v и %+v",
+				snapshotEvery, items, trash)
+		}
+		_ = reopened.Close()
+	}
+}
+
+func TestFileStorageRestoresHistory(t *testing.T) {
+	for _, snapshotEvery := range []int{0, 2} {
+		dir := t.TempDir()
+		ctx := context.Background()
+
+		storage := openTestFileStorage(t, dir, snapshotEvery)
+		if _, err := storage.Create(ctx, models.Todo{Title: "v1"}); err != nil {
+			t.Fatalf("ошибка создания: %v", err)
+		}
+		for _, title := range []string{"v2", "v3", "v4"} {
+			if _, err := storage.Modify(ctx, "", 1, func(todo models.Todo) (models.Todo, error) {
+				todo.Title = title
+				return todo, nil
+			}); err != nil {
+				t.Fatalf("ошибка изменения: %v", err)
+			}
+		}
+		if err := storage.Close(); err != nil {
+			t.Fatalf("ошибка закрытия: %v", err)
+		}
+
+		reopened := openTestFileStorage(t, dir, 0)
+		history, err := reopened.History(ctx, "", 1)
+		if err != nil {
+			t.Fatalf("snapshot_every=%d: неожиданная ошибка: %v", snapshotEvery, err)
+		}
+		var titles []string
+		for _, todo := range history {
+			titles = append(titles, todo.Title)
+		}
+		if !slices.Equal(titles, []string{"v1", "v2", "v3", "v4"}) {
+			t.Fatalf("snapshot_every=%d: ожидалась история v1..v4, получено %v", snapshotEvery, titles)
+		}
+		_ = reopened.Close()
+	}
+}
+
+func TestFileStorageRestoresSubtasks(t *testing.T) {
+	for _, snapshotEvery := range []int{0, 2} {
+		dir := t.TempDir()
+		ctx := context.Background()
+
+		storage := openTestFileStorage(t, dir, snapshotEvery)
+		for _, todo := range []models.Todo{
+			{Title: "проект"},
+			{Title: "этап", ParentID: 1},
+			{Title: "шаг", ParentID: 2},
+		} {
+			if _, err := storage.Create(ctx, todo); err != nil {
+				t.Fatalf("ошибка создания: %v", err)
+			}
+		}
+		if err := storage.Close(); err != nil {
+			t.Fatalf("ошибка закрытия: %v", err)
+		}
+
+		reopened := openTestFileStorage(t, dir, 0)
+		children, err := reopened.Children(ctx, "", 1)
+		if err != nil {
+			t.Fatalf("snapshot_every=%d: неожиданная ошибка: %v", snapshotEvery, err)
+		}
+		if got := ids(children); !slices.Equal(got, []int{2}) {
+			t.Fatalf("snapshot_every=%d: ожидалась подзадача 2, получено %v", snapshotEvery, got)
+		}
+		if _, err := reopened.Modify(ctx, "", 1, func(todo models.Todo) (models.Todo, error) {
+			todo.ParentID = 3
+			return todo, nil
+		}); !errors.Is(err, models.ErrInvalidParent) {
+			t.Fatalf("snapshot_every=%d: ожидалась ошибка %v, получено %v", snapshotEvery, models.ErrInvalidParent, err)
+		}
+		_ = reopened.Close()
+	}
+}
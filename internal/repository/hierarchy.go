@@ -0,0 +1,212 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/RoGogDBD/ecom/internal/models"
+)
+
+// Children возвращает активные подзадачи первого уровня задачи владельца в порядке ID.
+// Для отсутствующей или удаленной в корзину задачи возвращает models.ErrNotFound.
+func (s *TodoStorage) Children(_ context.Context, owner string, id int) ([]models.Todo, error) {
+	s.rlock(metricOpChildren)
+	defer s.mu.RUnlock()
+
+	tn := s.tenants[owner]
+	if tn == nil {
+		return nil, models.ErrNotFound
+	}
+	if _, ok := tn.items[id]; !ok {
+		return nil, models.ErrNotFound
+	}
+
+	return tn.childrenOf(id), nil
+}
+
+// Descendants возвращает активные подзадачи всех уровней задач ids владельца, каждую один раз.
+// Сами задачи ids в результат не входят, если не являются подзадачами друг друга.
+func (s *TodoStorage) Descendants(_ context.Context, owner string, ids []int) ([]models.Todo, error) {
+	s.rlock(metricOpChildren)
+	defer s.mu.RUnlock()
+
+	tn := s.tenants[owner]
+	if tn == nil {
+		return []models.Todo{}, nil
+	}
+
+	var (
+		result = []models.Todo{}
+		seen   = make(map[int]struct{})
+		queue  = slices.Clone(ids)
+	)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+
+		for _, child := range tn.childrenOf(id) {
+			if _, ok := seen[child.ID]; ok {
+				continue
+			}
+			seen[child.ID] = struct{}{}
+			result = append(result, child)
+			queue = append(queue, child.ID)
+		}
+	}
+
+	return result, nil
+}
+
+// CompleteParent атомарно изменяет задачу владельца через fn, если она не завершена,
+// у нее есть активные подзадачи и все они завершены. ok == false означает, что условие
+// не выполнено и задача не изменена.
+func (s *TodoStorage) CompleteParent(_ context.Context, owner string, id int, fn func(models.Todo) models.Todo) (todo models.Todo, ok bool, err error) {
+	s.lock(metricOpModify)
+	defer s.mu.Unlock()
+
+	tn := s.tenants[owner]
+	if tn == nil {
+		return models.Todo{}, false, nil
+	}
+	parent, exists := tn.items[id]
+	children := tn.childrenOf(id)
+	if !exists || parent.Completed || len(children) == 0 {
+		return models.Todo{}, false, nil
+	}
+	for _, child := range children {
+		if !child.Completed {
+			return models.Todo{}, false, nil
+		}
+	}
+
+	t := s.begin(owner)
+	updated, err := t.modify(id, func(current models.Todo) (models.Todo, error) {
+		return fn(current), nil
+	})
+	if err != nil {
+		return models.Todo{}, false, err
+	}
+
+	if err := s.commit(t.records...); err != nil {
+		return models.Todo{}, false, err
+	}
+
+	return updated, true, nil
+}
+
+// childrenOf возвращает активные подзадачи первого уровня по индексу в порядке ID. Вызывается под s.mu.
+func (tn *tenant) childrenOf(id int) []models.Todo {
+	children := make([]models.Todo, 0, len(tn.children[id]))
+	for childID := range tn.children[id] {
+		children = append(children, tn.items[childID])
+	}
+	slices.SortFunc(children, compareByID)
+
+	return children
+}
+
+// checkParent проверяет, что родитель задачи — активная задача того же владельца
+// и что задача не становится подзадачей самой себя или своей подзадачи.
+func (t *tx) checkParent(todo models.Todo) error {
+	if todo.ParentID == 0 {
+		return nil
+	}
+	if _, ok := t.get(todo.ParentID); !ok {
+		return fmt.Errorf("%w: задача %d не найдена", models.ErrInvalidParent, todo.ParentID)
+	}
+
+	// Связи без циклов гарантированы для уже сохраненных задач, поэтому подъем по предкам конечен.
+	for id := todo.ParentID; id != 0; {
+		if id == todo.ID {
+			return fmt.Errorf("%w: задача не может быть подзадачей самой себя или своей подзадачи", models.ErrInvalidParent)
+		}
+		parent, _ := t.get(id)
+		id = parent.ParentID
+	}
+
+	return nil
+}
+
+// children возвращает подзадачи первого уровня с учетом изменений транзакции в порядке ID:
+// активные и, если trashed, находящиеся в корзине.
+func (t *tx) children(id int, trashed bool) []models.Todo {
+	candidates := make(map[int]struct{}, len(t.childIndex[id]))
+	for childID := range t.childIndex[id] {
+		candidates[childID] = struct{}{}
+	}
+	for childID, staged := range t.staged {
+		if staged != nil && staged.ParentID == id {
+			candidates[childID] = struct{}{}
+		}
+	}
+	if trashed {
+		for childID, todo := range t.trash {
+			if todo.ParentID == id {
+				candidates[childID] = struct{}{}
+			}
+		}
+	}
+
+	children := make([]models.Todo, 0, len(candidates))
+	for childID := range candidates {
+		child, ok := t.lookup(childID)
+		if !ok || child.ParentID != id || (!trashed && child.DeletedAt != nil) {
+			continue
+		}
+		children = append(children, child)
+	}
+	slices.SortFunc(children, compareByID)
+
+	return children
+}
+
+// release поступает с подзадачами удаляемой задачи id согласно mode и дописывает
+// затронутые задачи в removal. hard — задача удаляется окончательно: тогда каскад
+// удаляет и подзадачи из корзины. at — время перемещения в корзину и изменения отвязанных подзадач.
+func (t *tx) release(id int, at time.Time, mode models.ChildrenMode, hard bool, removal *models.Removal) error {
+	children := t.children(id, hard)
+
+	switch mode {
+	case models.ChildrenCascade:
+		for _, child := range children {
+			if hard {
+				t.drop(child)
+			} else {
+				child.DeletedAt = &at
+				child.Version++
+				t.put(child)
+			}
+			removal.Deleted = append(removal.Deleted, child)
+
+			if err := t.release(child.ID, at, mode, hard, removal); err != nil {
+				return err
+			}
+		}
+	case models.ChildrenDetach:
+		for _, child := range children {
+			if child.DeletedAt != nil {
+				continue
+			}
+			child.ParentID = 0
+			child.UpdatedAt = at
+			child.Version++
+			t.put(child)
+			removal.Detached = append(removal.Detached, child)
+		}
+	default:
+		for _, child := range children {
+			if child.DeletedAt == nil {
+				return models.ErrHasChildren
+			}
+		}
+	}
+
+	return nil
+}
+
+func compareByID(a, b models.Todo) int {
+	return cmp.Compare(a.ID, b.ID)
+}
@@ -0,0 +1,387 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/RoGogDBD/ecom/internal/models"
+)
+
+// newHierarchyTestStorage создает дерево задач: 1 ← 2 ← 3, 1 ← 4 и отдельную задачу 5.
+func newHierarchyTestStorage(t *testing.T) *TodoStorage {
+	t.Helper()
+
+	storage := NewTodoStorage()
+	for _, todo := range []models.Todo{
+		{Title: "проект"},
+		{Title: "этап", ParentID: 1},
+		{Title: "шаг", ParentID: 2},
+		{Title: "второй этап", ParentID: 1},
+		{Title: "отдельная"},
+	} {
+		if _, err := storage.Create(context.Background(), todo); err != nil {
+			t.Fatalf("ошибка подготовки данных: %v", err)
+		}
+	}
+
+	return storage
+}
+
+func TestTodoStorageParent(t *testing.T) {
+	cases := []struct {
+		name    string
+		change  func(s *TodoStorage) error
+		wantErr error
+	}{
+		{
+			name: "подзадача существующей задачи",
+			change: func(s *TodoStorage) error {
+				_, err := s.Create(context.Background(), models.Todo{Title: "новая", ParentID: 3})
+				return err
+			},
+		},
+		{
+			name: "несуществующий родитель",
+			change: func(s *TodoStorage) error {
+				_, err := s.Create(context.Background(), models.Todo{Title: "новая", ParentID: 42})
+				return err
+			},
+			wantErr: models.ErrInvalidParent,
+		},
+		{
+			name: "родитель другого владельца",
+			change: func(s *TodoStorage) error {
+				_, err := s.Create(context.Background(), models.Todo{Title: "чужая", Owner: "bob", ParentID: 1})
+				return err
+			},
+			wantErr: models.ErrInvalidParent,
+		},
+		{
+			name: "родитель в корзине",
+			change: func(s *TodoStorage) error {
+				if _, err := s.SoftDelete(context.Background(), "", 5, 0, time.Now(), models.ChildrenReject); err != nil {
+					return err
+				}
+				_, err := s.Create(context.Background(), models.Todo{Title: "новая", ParentID: 5})
+				return err
+			},
+			wantErr: models.ErrInvalidParent,
+		},
+		{
+			name: "цикл через подзадачу",
+			change: func(s *TodoStorage) error {
+				_, err := s.Modify(context.Background(), "", 1, func(todo models.Todo) (models.Todo, error) {
+					todo.ParentID = 3
+					return todo, nil
+				})
+				return err
+			},
+			wantErr: models.ErrInvalidParent,
+		},
+		{
+			name: "перенос в другую ветку",
+			change: func(s *TodoStorage) error {
+				_, err := s.Modify(context.Background(), "", 3, func(todo models.Todo) (models.Todo, error) {
+					todo.ParentID = 4
+					return todo, nil
+				})
+				return err
+			},
+		},
+		{
+			name: "цикл внутри пакета",
+			change: func(s *TodoStorage) error {
+				reparent := func(parentID int) func(models.Todo) (models.Todo, error) {
+					return func(todo models.Todo) (models.Todo, error) {
+						todo.ParentID = parentID
+						return todo, nil
+					}
+				}
+				results, err := s.Apply(context.Background(), "", []models.Mutation{
+					{Kind: models.BatchUpdate, ID: 5, Modify: reparent(3)},
+					{Kind: models.BatchUpdate, ID: 1, Modify: reparent(5)},
+				}, true)
+				if err != nil {
+					return err
+				}
+				for _, result := range results {
+					if result.Err != nil && !errors.Is(result.Err, models.ErrBatchAborted) {
+						return result.Err
+					}
+				}
+				return nil
+			},
+			wantErr: models.ErrInvalidParent,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			storage := newHierarchyTestStorage(t)
+			if err := tc.change(storage); !errors.Is(err, tc.wantErr) {
+				t.Fatalf("ожидалась ошибка %v, получено %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestTodoStorageDeleteChildren(t *testing.T) {
+	cases := []struct {
+		name         string
+		id           int
+		hard         bool
+		mode         models.ChildrenMode
+		wantErr      error
+		wantDeleted  []int
+		wantDetached []int
+		wantActive   []int
+		wantTrash    []int
+	}{
+		{
+			name:       "задача с подзадачами не удаляется",
+			id:         1,
+			mode:       models.ChildrenReject,
+			wantErr:    models.ErrHasChildren,
+			wantActive: []int{1, 2, 3, 4, 5},
+			wantTrash:  []int{},
+		},
+		{
+			name:        "задача без подзадач",
+			id:          3,
+			mode:        models.ChildrenReject,
+			wantDeleted: []int{3},
+			wantActive:  []int{1, 2, 4, 5},
+			wantTrash:   []int{3},
+		},
+		{
+			name:        "каскад в корзину",
+			id:          1,
+			mode:        models.ChildrenCascade,
+			wantDeleted: []int{1, 2, 3, 4},
+			wantActive:  []int{5},
+			wantTrash:   []int{1, 2, 3, 4},
+		},
+		{
+			name:         "отвязка подзадач",
+			id:           1,
+			mode:         models.ChildrenDetach,
+			wantDeleted:  []int{1},
+			wantDetached: []int{2, 4},
+			wantActive:   []int{2, 3, 4, 5},
+			wantTrash:    []int{1},
+		},
+		{
+			name:        "окончательный каскад",
+			id:          2,
+			hard:        true,
+			mode:        models.ChildrenCascade,
+			wantDeleted: []int{2, 3},
+			wantActive:  []int{1, 4, 5},
+			wantTrash:   []int{},
+		},
+		{
+			name:         "окончательное удаление с отвязкой",
+			id:           2,
+			hard:         true,
+			mode:         models.ChildrenDetach,
+			wantDeleted:  []int{2},
+			wantDetached: []int{3},
+			wantActive:   []int{1, 3, 4, 5},
+			wantTrash:    []int{},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			storage := newHierarchyTestStorage(t)
+			ctx := context.Background()
+			at := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+
+			del := storage.SoftDelete
+			if tc.hard {
+				del = storage.Delete
+			}
+			removal, err := del(ctx, "", tc.id, 0, at, tc.mode)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("ожидалась ошибка %v, получено %v", tc.wantErr, err)
+			}
+			if got := ids(removal.Deleted); len(got) != 0 || len(tc.wantDeleted) != 0 {
+				if !slices.Equal(got, tc.wantDeleted) {
+					t.Fatalf("ожидались удаленные %v, получено %v", tc.wantDeleted, got)
+				}
+			}
+			if got := ids(removal.Detached); len(got) != 0 || len(tc.wantDetached) != 0 {
+				if !slices.Equal(got, tc.wantDetached) {
+					t.Fatalf("ожидались отвязанные %v, получено %v", tc.wantDetached, got)
+				}
+			}
+			for _, detached := range removal.Detached {
+				if detached.ParentID != 0 || !detached.UpdatedAt.Equal(at) {
+					t.Fatalf("отвязанная задача %+v: ожидались parent_id 0 и updated_at %v", detached, at)
+				}
+			}
+
+			active, _ := storage.GetAll(ctx, "")
+			got := ids(active)
+			slices.Sort(got)
+			if !slices.Equal(got, tc.wantActive) {
+				t.Fatalf("ожидались активные %v, получено %v", tc.wantActive, got)
+			}
+			trash, _ := storage.ListTrash(ctx, "")
+			got = ids(trash)
+			slices.Sort(got)
+			if !slices.Equal(got, tc.wantTrash) {
+				t.Fatalf("ожидалась корзина %v, получено %v", tc.wantTrash, got)
+			}
+		})
+	}
+}
+
+func TestTodoStorageRestoreSubtask(t *testing.T) {
+	storage := newHierarchyTestStorage(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	if _, err := storage.SoftDelete(ctx, "", 2, 0, now, models.ChildrenCascade); err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if _, err := storage.Restore(ctx, "", 3, 0, now); !errors.Is(err, models.ErrInvalidParent) {
+		t.Fatalf("подзадача при родителе в корзине: ожидалась ошибка %v, получено %v", models.ErrInvalidParent, err)
+	}
+	if _, err := storage.Restore(ctx, "", 2, 0, now); err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	restored, err := storage.Restore(ctx, "", 3, 0, now)
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if restored.ParentID != 2 {
+		t.Fatalf("ожидался родитель 2, получено %d", restored.ParentID)
+	}
+
+	// Без окончательно удаленного родителя подзадача восстанавливается задачей верхнего уровня.
+	if _, err := storage.SoftDelete(ctx, "", 3, 0, now, models.ChildrenReject); err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if _, err := storage.Delete(ctx, "", 2, 0, now, models.ChildrenReject); err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if restored, err = storage.Restore(ctx, "", 3, 0, now); err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if restored.ParentID != 0 {
+		t.Fatalf("ожидалась задача верхнего уровня, получен родитель %d", restored.ParentID)
+	}
+}
+
+func TestTodoStorageChildren(t *testing.T) {
+	storage := newHierarchyTestStorage(t)
+	ctx := context.Background()
+
+	cases := []struct {
+		name    string
+		id      int
+		wantIDs []int
+		wantErr error
+	}{
+		{name: "подзадачи первого уровня", id: 1, wantIDs: []int{2, 4}},
+		{name: "без подзадач", id: 5, wantIDs: []int{}},
+		{name: "несуществующая задача", id: 42, wantErr: models.ErrNotFound},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			children, err := storage.Children(ctx, "", tc.id)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("ожидалась ошибка %v, получено %v", tc.wantErr, err)
+			}
+			if err == nil && !slices.Equal(ids(children), tc.wantIDs) {
+				t.Fatalf("ожидались id %v, получено %v", tc.wantIDs, ids(children))
+			}
+		})
+	}
+
+	descendants, err := storage.Descendants(ctx, "", []int{1, 2})
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	got := ids(descendants)
+	slices.Sort(got)
+	if !slices.Equal(got, []int{2, 3, 4}) {
+		t.Fatalf("ожидались потомки [2 3 4] без повторов, получено %v", got)
+	}
+}
+
+func TestTodoStorageCompleteParent(t *testing.T) {
+	storage := newHierarchyTestStorage(t)
+	ctx := context.Background()
+	complete := func(todo models.Todo) models.Todo {
+		todo.Completed = true
+		return todo
+	}
+
+	if _, ok, err := storage.CompleteParent(ctx, "", 2, complete); err != nil || ok {
+		t.Fatalf("при незавершенной подзадаче ожидалось ok=false, получено ok=%v, ошибка %v", ok, err)
+	}
+	if _, ok, _ := storage.CompleteParent(ctx, "", 5, complete); ok {
+		t.Fatal("задача без подзадач не должна завершаться")
+	}
+
+	if _, err := storage.Modify(ctx, "", 3, func(todo models.Todo) (models.Todo, error) {
+		return complete(todo), nil
+	}); err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	completed, ok, err := storage.CompleteParent(ctx, "", 2, complete)
+	if err != nil || !ok {
+		t.Fatalf("ожидалось завершение задачи 2, получено ok=%v, ошибка %v", ok, err)
+	}
+	if !completed.Completed || completed.Version != 2 {
+		t.Fatalf("ожидалась завершенная задача версии 2, получено %+v", completed)
+	}
+	if _, ok, _ := storage.CompleteParent(ctx, "", 2, complete); ok {
+		t.Fatal("завершенная задача не должна завершаться повторно")
+	}
+	if _, ok, _ := storage.CompleteParent(ctx, "", 1, complete); ok {
+		t.Fatal("задача 1 с незавершенной подзадачей 4 не должна завершаться")
+	}
+}
+
+func TestTodoStorageApplyDeleteParent(t *testing.T) {
+	storage := newHierarchyTestStorage(t)
+	ctx := context.Background()
+
+	results, err := storage.Apply(ctx, "", []models.Mutation{
+		{Kind: models.BatchDelete, ID: 2},
+		{Kind: models.BatchDelete, ID: 5},
+	}, false)
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if !errors.Is(results[0].Err, models.ErrHasChildren) || results[1].Err != nil {
+		t.Fatalf("ожидались ошибки [%v <nil>], получено [%v %v]", models.ErrHasChildren, results[0].Err, results[1].Err)
+	}
+
+	// Отклоненное удаление не оставляет родителя в корзине с активной подзадачей.
+	parent, err := storage.GetByID(ctx, "", 2)
+	if err != nil {
+		t.Fatalf("задача 2 должна остаться активной, получена ошибка %v", err)
+	}
+	if parent.Version != 1 || parent.DeletedAt != nil {
+		t.Fatalf("задача 2 не должна измениться, получено %+v", parent)
+	}
+	children, err := storage.Children(ctx, "", 2)
+	if err != nil || !slices.Equal(ids(children), []int{3}) {
+		t.Fatalf("ожидалась подзадача 3, получено %v, ошибка %v", ids(children), err)
+	}
+	trash, _ := storage.ListTrash(ctx, "")
+	if !slices.Equal(ids(trash), []int{5}) {
+		t.Fatalf("ожидалась корзина [5], получено %v", ids(trash))
+	}
+}
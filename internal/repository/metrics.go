@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/RoGogDBD/ecom/internal/metrics"
+)
+
+// Операции хранилища для метрик.
+const (
+	metricOpCreate     = "create"
+	metricOpModify     = "modify"
+	metricOpDelete     = "delete"
+	metricOpSoftDelete = "soft_delete"
+	metricOpRestore    = "restore"
+	metricOpListTrash  = "list_trash"
+	metricOpPurgeTrash = "purge_trash"
+	metricOpHistory    = "history"
+	metricOpReminders  = "reminders"
+	metricOpTags       = "tags"
+	metricOpChildren   = "children"
+	metricOpApply      = "apply"
+	metricOpGetAll     = "get_all"
+	metricOpGet        = "get"
+	metricOpList       = "list"
+	metricOpClose      = "close"
+)
+
+// lockWaitBuckets границы гистограммы ожидания блокировки: от микросекунд до секунды.
+var lockWaitBuckets = []float64{1e-6, 1e-5, 1e-4, 1e-3, 1e-2, 1e-1, 1}
+
+// storageMetrics метрики хранилища. Нулевой указатель отключает их сбор.
+type storageMetrics struct {
+	operations *metrics.Counter
+	lockWait   *metrics.Histogram
+}
+
+// Instrument регистрирует метрики хранилища в reg: количество задач и задач в корзине, количество операций
+// и время ожидания блокировки. Вызывается до начала работы с хранилищем.
+func (s *TodoStorage) Instrument(reg *metrics.Registry) {
+	reg.NewGaugeFunc("todo_storage_items", "Количество задач в хранилище.", func() float64 {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		return float64(s.count())
+	})
+	reg.NewGaugeFunc("todo_storage_trash_items", "Количество задач в корзине.", func() float64 {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		return float64(s.countTrash())
+	})
+
+	s.metrics = &storageMetrics{
+		operations: reg.NewCounter("todo_storage_operations_total",
+			"Количество операций хранилища.", "op"),
+		lockWait: reg.NewHistogram("todo_storage_lock_wait_seconds",
+			"Время ожидания блокировки хранилища в секундах.", lockWaitBuckets, "op"),
+	}
+}
+
+// lock захватывает блокировку на запись и учитывает операцию op в метриках.
+func (s *TodoStorage) lock(op string) {
+	start := time.Now()
+	s.mu.Lock()
+	s.metrics.observe(op, start)
+}
+
+// rlock захватывает блокировку на чтение и учитывает операцию op в метриках.
+func (s *TodoStorage) rlock(op string) {
+	start := time.Now()
+	s.mu.RLock()
+	s.metrics.observe(op, start)
+}
+
+func (m *storageMetrics) observe(op string, start time.Time) {
+	if m == nil {
+		return
+	}
+
+	m.operations.Inc(op)
+	m.lockWait.Observe(time.Since(start).Seconds(), op)
+}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RoGogDBD/ecom/internal/metrics"
+	"github.com/RoGogDBD/ecom/internal/models"
+)
+
+func TestTodoStorageInstrument(t *testing.T) {
+	reg := metrics.NewRegistry()
+	storage := NewTodoStorage()
+	storage.Instrument(reg)
+
+	ctx := context.Background()
+	for _, title := range []string{"первая", "вторая"} {
+		if _, err := storage.Create(ctx, models.Todo{Title: title}); err != nil {
+			t.Fatalf("Create() ошибка = %v", err)
+		}
+	}
+	if _, err := storage.GetByID(ctx, "", 1); err != nil {
+		t.Fatalf("GetByID() ошибка = %v", err)
+	}
+	if _, err := storage.Delete(ctx, "", 2, 0, time.Time{}, models.ChildrenReject); err != nil {
+		t.Fatalf("Delete() ошибка = %v", err)
+	}
+
+	var b strings.Builder
+	if _, err := reg.WriteTo(&b); err != nil {
+		t.Fatalf("WriteTo() ошибка = %v", err)
+	}
+	out := b.String()
+
+	for _, want := range []string{
+		"todo_storage_items 1\n",
+		`todo_storage_operations_total{op="create"} 2` + "\n",
+		`todo_storage_operations_total{op="get"} 1` + "\n",
+		`todo_storage_operations_total{op="delete"} 1` + "\n",
+		`todo_storage_lock_wait_seconds_count{op="create"} 2` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("метрики не содержат %q:\n%s", want, out)
+		}
+	}
+}
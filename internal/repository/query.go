@@ -0,0 +1,261 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/RoGogDBD/ecom/internal/models"
+)
+
+type (
+	// order задает порядок сортировки задач.
+	order struct {
+		field string
+		desc  bool
+	}
+
+	// cursor содержимое непрозрачного курсора: поле сортировки и ключ последней
+	// выданной задачи. Следующая страница начинается строго после этого ключа,
+	// поэтому вставки и удаления между запросами не сдвигают выдачу.
+	cursor struct {
+		Sort     string          `json:"s"`
+		ID       int             `json:"i"`
+		Title    string          `json:"t,omitempty"`
+		At       *time.Time      `json:"a,omitempty"`
+		Priority models.Priority `json:"p,omitempty"`
+	}
+)
+
+// List возвращает отфильтрованную, отсортированную и разбитую на страницы выборку задач владельца.
+// Нулевой q.Limit означает выборку без ограничения.
+func (s *TodoStorage) List(_ context.Context, owner string, q models.TodoQuery) (models.TodoPage, error) {
+	ord, err := parseOrder(q.Sort)
+	if err != nil {
+		return models.TodoPage{}, err
+	}
+
+	var after *models.Todo
+	if q.Cursor != "" {
+		pivot, err := ord.decodeCursor(q.Cursor, q.Sort)
+		if err != nil {
+			return models.TodoPage{}, err
+		}
+		after = &pivot
+	}
+
+	matched := s.filter(owner, q)
+	slices.SortFunc(matched, ord.compare)
+
+	start := min(q.Offset, len(matched))
+	if after != nil {
+		start, _ = slices.BinarySearchFunc(matched, *after, func(item, pivot models.Todo) int {
+			// Ищем первый элемент строго больше курсора.
+			if ord.compare(item, pivot) <= 0 {
+				return -1
+			}
+			return 1
+		})
+	}
+
+	end := len(matched)
+	if q.Limit > 0 && start+q.Limit < end {
+		end = start + q.Limit
+	}
+
+	page := models.TodoPage{
+		Items: matched[start:end],
+		Total: len(matched),
+	}
+	if end < len(matched) && end > start {
+		page.NextCursor = ord.encodeCursor(q.Sort, matched[end-1])
+	}
+
+	return page, nil
+}
+
+// filter возвращает копии задач владельца, удовлетворяющих фильтрам запроса.
+// При фильтре по тегам перебираются только задачи из индекса тегов.
+func (s *TodoStorage) filter(owner string, q models.TodoQuery) []models.Todo {
+	search := strings.ToLower(q.Search)
+
+	s.rlock(metricOpList)
+	defer s.mu.RUnlock()
+
+	tn := s.tenants[owner]
+	if tn == nil {
+		return []models.Todo{}
+	}
+
+	ids, indexed := tn.tagged(q.Tags, q.AnyTags)
+	if !indexed {
+		result := make([]models.Todo, 0, len(tn.items))
+		for _, todo := range tn.items {
+			if matches(todo, q, search) {
+				result = append(result, todo)
+			}
+		}
+		return result
+	}
+
+	result := make([]models.Todo, 0, len(ids))
+	for _, id := range ids {
+		if todo := tn.items[id]; matches(todo, q, search) {
+			result = append(result, todo)
+		}
+	}
+
+	return result
+}
+
+// matches проверяет задачу по фильтрам запроса, кроме тегов. search — строка поиска в нижнем регистре.
+func matches(todo models.Todo, q models.TodoQuery, search string) bool {
+	if q.Completed != nil && todo.Completed != *q.Completed {
+		return false
+	}
+	if search != "" &&
+		!strings.Contains(strings.ToLower(todo.Title), search) &&
+		!strings.Contains(strings.ToLower(todo.Description), search) {
+		return false
+	}
+	if !q.CreatedAt.Contains(todo.CreatedAt) || !q.UpdatedAt.Contains(todo.UpdatedAt) {
+		return false
+	}
+	if !q.CompletedAt.IsZero() && (todo.CompletedAt == nil || !q.CompletedAt.Contains(*todo.CompletedAt)) {
+		return false
+	}
+	if q.Priority != "" && todo.Priority.Rank() != q.Priority.Rank() {
+		return false
+	}
+	if !q.DueAt.IsZero() && (todo.DueAt == nil || !q.DueAt.Contains(*todo.DueAt)) {
+		return false
+	}
+	if q.Overdue != nil && todo.Overdue(q.Now) != *q.Overdue {
+		return false
+	}
+
+	return true
+}
+
+// parseOrder разбирает параметр сортировки. Пустая строка означает сортировку по ID.
+func parseOrder(sort string) (order, error) {
+	ord := order{field: strings.TrimPrefix(sort, models.SortDescPrefix)}
+	ord.desc = ord.field != sort
+
+	switch ord.field {
+	case "":
+		ord.field = models.SortByID
+	case models.SortByID, models.SortByTitle,
+		models.SortByCreatedAt, models.SortByUpdatedAt, models.SortByCompletedAt,
+		models.SortByDueAt, models.SortByPriority:
+	default:
+		return order{}, fmt.Errorf("%w: неизвестное поле сортировки %q", models.ErrInvalidQuery, sort)
+	}
+
+	return ord, nil
+}
+
+// compare сравнивает задачи по полю сортировки; при равенстве порядок определяет ID,
+// так что порядок всегда строгий и курсор однозначно указывает позицию.
+func (o order) compare(a, b models.Todo) int {
+	var c int
+	switch o.field {
+	case models.SortByTitle:
+		c = cmp.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title))
+		if c == 0 {
+			c = cmp.Compare(a.Title, b.Title)
+		}
+	case models.SortByCreatedAt:
+		c = a.CreatedAt.Compare(b.CreatedAt)
+	case models.SortByUpdatedAt:
+		c = a.UpdatedAt.Compare(b.UpdatedAt)
+	case models.SortByCompletedAt:
+		c = compareTimePtr(a.CompletedAt, b.CompletedAt)
+	case models.SortByDueAt:
+		c = compareTimePtr(a.DueAt, b.DueAt)
+	case models.SortByPriority:
+		c = cmp.Compare(a.Priority.Rank(), b.Priority.Rank())
+	}
+	if c == 0 {
+		c = cmp.Compare(a.ID, b.ID)
+	}
+
+	if o.desc {
+		return -c
+	}
+	return c
+}
+
+// compareTimePtr сравнивает необязательные метки времени; отсутствующая метка меньше любой.
+func compareTimePtr(a, b *time.Time) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	default:
+		return a.Compare(*b)
+	}
+}
+
+// encodeCursor сохраняет в курсоре только ключ сортировки последней задачи.
+func (o order) encodeCursor(sort string, last models.Todo) string {
+	c := cursor{Sort: sort, ID: last.ID}
+	switch o.field {
+	case models.SortByTitle:
+		c.Title = last.Title
+	case models.SortByCreatedAt:
+		c.At = &last.CreatedAt
+	case models.SortByUpdatedAt:
+		c.At = &last.UpdatedAt
+	case models.SortByCompletedAt:
+		c.At = last.CompletedAt
+	case models.SortByDueAt:
+		c.At = last.DueAt
+	case models.SortByPriority:
+		c.Priority = last.Priority
+	}
+
+	// Маршалинг структуры из строк, чисел и времени не может завершиться ошибкой.
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor восстанавливает из курсора задачу-ориентир с заполненным ключом сортировки.
+func (o order) decodeCursor(token, sort string) (models.Todo, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return models.Todo{}, fmt.Errorf("%w: некорректный курсор", models.ErrInvalidQuery)
+	}
+
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return models.Todo{}, fmt.Errorf("%w: некорректный курсор", models.ErrInvalidQuery)
+	}
+	if c.Sort != sort {
+		return models.Todo{}, fmt.Errorf("%w: курсор получен для другой сортировки", models.ErrInvalidQuery)
+	}
+
+	pivot := models.Todo{ID: c.ID, Title: c.Title, Priority: c.Priority}
+	if c.At != nil {
+		switch o.field {
+		case models.SortByCreatedAt:
+			pivot.CreatedAt = *c.At
+		case models.SortByUpdatedAt:
+			pivot.UpdatedAt = *c.At
+		case models.SortByCompletedAt:
+			pivot.CompletedAt = c.At
+		case models.SortByDueAt:
+			pivot.DueAt = c.At
+		}
+	}
+
+	return pivot, nil
+}
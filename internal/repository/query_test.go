@@ -0,0 +1,324 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/RoGogDBD/ecom/internal/models"
+)
+
+func newQueryTestStorage(t *testing.T) *TodoStorage {
+	t.Helper()
+
+	storage := NewTodoStorage()
+	ctx := context.Background()
+	for _, todo := range []models.Todo{
+		{Title: "Купить молоко", Description: "в магазине у дома"},
+		{Title: "allocate склад", Completed: true},
+		{Title: "Banana", Description: "купить к завтраку"},
+		{Title: "отчет", Completed: true},
+		{Title: "Cancel подписку"},
+	} {
+		if _, err := storage.Create(ctx, todo); err != nil {
+			t.Fatalf("ошибка подготовки данных: %v", err)
+		}
+	}
+
+	return storage
+}
+
+func ids(items []models.Todo) []int {
+	result := make([]int, 0, len(items))
+	for _, item := range items {
+		result = append(result, item.ID)
+	}
+	return result
+}
+
+func TestTodoStorageList(t *testing.T) {
+	completed := true
+
+	cases := []struct {
+		name      string
+		query     models.TodoQuery
+		wantIDs   []int
+		wantTotal int
+		wantNext  bool
+		wantErr   error
+	}{
+		{
+			name:      "по умолчанию сортировка по id",
+			query:     models.TodoQuery{},
+			wantIDs:   []int{1, 2, 3, 4, 5},
+			wantTotal: 5,
+		},
+		{
+			name:      "по убыванию id с лимитом",
+			query:     models.TodoQuery{Sort: "-id", Limit: 2},
+			wantIDs:   []int{5, 4},
+			wantTotal: 5,
+			wantNext:  true,
+		},
+		{
+			name:      "по заголовку без учета регистра",
+			query:     models.TodoQuery{Sort: "title"},
+			wantIDs:   []int{2, 3, 5, 1, 4},
+			wantTotal: 5,
+		},
+		{
+			name:      "offset",
+			query:     models.TodoQuery{Offset: 3, Limit: 10},
+			wantIDs:   []int{4, 5},
+			wantTotal: 5,
+		},
+		{
+			name:      "offset за пределами выборки",
+			query:     models.TodoQuery{Offset: 10},
+			wantIDs:   []int{},
+			wantTotal: 5,
+		},
+		{
+			name:      "фильтр по завершенности",
+			query:     models.TodoQuery{Completed: &completed},
+			wantIDs:   []int{2, 4},
+			wantTotal: 2,
+		},
+		{
+			name:      "поиск по заголовку и описанию",
+			query:     models.TodoQuery{Search: "купить"},
+			wantIDs:   []int{1, 3},
+			wantTotal: 2,
+		},
+		{
+			name:    "неизвестное поле сортировки",
+			query:   models.TodoQuery{Sort: "color"},
+			wantErr: models.ErrInvalidQuery,
+		},
+		{
+			name:    "некорректный курсор",
+			query:   models.TodoQuery{Cursor: "???"},
+			wantErr: models.ErrInvalidQuery,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			storage := newQueryTestStorage(t)
+
+			page, err := storage.List(context.Background(), "", tc.query)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("ожидалась ошибка %v, получено %v", tc.wantErr, err)
+			}
+			if tc.wantErr != nil {
+				return
+			}
+
+			if got := ids(page.Items); !slices.Equal(got, tc.wantIDs) {
+				t.Fatalf("ожидались id %v, получено %v", tc.wantIDs, got)
+			}
+			if page.Total != tc.wantTotal {
+				t.Fatalf("ожидалось total %d, получено %d", tc.wantTotal, page.Total)
+			}
+			if (page.NextCursor != "") != tc.wantNext {
+				t.Fatalf("наличие курсора: ожидалось %v, получено %q", tc.wantNext, page.NextCursor)
+			}
+		})
+	}
+}
+
+func TestTodoStorageListCursor(t *testing.T) {
+	storage := newQueryTestStorage(t)
+	ctx := context.Background()
+
+	query := models.TodoQuery{Sort: "-title", Limit: 2}
+	var got []int
+	for {
+		page, err := storage.List(ctx, "", query)
+		if err != nil {
+			t.Fatalf("неожиданная ошибка: %v", err)
+		}
+		got = append(got, ids(page.Items)...)
+
+		// Удаление уже выданной задачи не должно сдвигать следующие страницы.
+		if len(got) == 2 {
+			if _, err := storage.Delete(ctx, "", got[0], 0, time.Time{}, models.ChildrenReject); err != nil {
+				t.Fatalf("ошибка удаления: %v", err)
+			}
+		}
+
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+
+	if want := []int{4, 1, 5, 3, 2}; !slices.Equal(got, want) {
+		t.Fatalf("ожидались id %v, получено %v", want, got)
+	}
+
+	_, err := storage.List(ctx, "", models.TodoQuery{Sort: "id", Cursor: query.Cursor})
+	if !errors.Is(err, models.ErrInvalidQuery) {
+		t.Fatalf("курсор другой сортировки: ожидалась ошибка %v, получено %v", models.ErrInvalidQuery, err)
+	}
+}
+
+func TestTodoStorageListByTimestamps(t *testing.T) {
+	storage := NewTodoStorage()
+	ctx := context.Background()
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, offset := range []int{3, 1, 2, 1} {
+		at := base.Add(time.Duration(offset) * time.Hour)
+		todo := models.Todo{Title: "задача", CreatedAt: at, UpdatedAt: at}
+		if i%2 == 0 {
+			todo.Completed = true
+			todo.CompletedAt = &at
+		}
+		if _, err := storage.Create(ctx, todo); err != nil {
+			t.Fatalf("ошибка подготовки данных: %v", err)
+		}
+	}
+
+	cases := []struct {
+		name    string
+		query   models.TodoQuery
+		wantIDs []int
+	}{
+		{
+			name:    "по убыванию created_at, при равенстве по id",
+			query:   models.TodoQuery{Sort: "-created_at"},
+			wantIDs: []int{1, 3, 4, 2},
+		},
+		{
+			name:    "по completed_at: незавершенные первыми",
+			query:   models.TodoQuery{Sort: "completed_at"},
+			wantIDs: []int{2, 4, 3, 1},
+		},
+		{
+			name:    "created_after включительно, created_before исключительно",
+			query:   models.TodoQuery{CreatedAt: models.TimeRange{After: base.Add(2 * time.Hour), Before: base.Add(3 * time.Hour)}},
+			wantIDs: []int{3},
+		},
+		{
+			name:    "фильтр по completed_at исключает незавершенные",
+			query:   models.TodoQuery{CompletedAt: models.TimeRange{After: base}},
+			wantIDs: []int{1, 3},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			page, err := storage.List(ctx, "", tc.query)
+			if err != nil {
+				t.Fatalf("неожиданная ошибка: %v", err)
+			}
+			if got := ids(page.Items); !slices.Equal(got, tc.wantIDs) {
+				t.Fatalf("ожидались id %v, получено %v", tc.wantIDs, got)
+			}
+		})
+	}
+
+	// Курсор по времени продолжает выдачу с правильной позиции.
+	first, err := storage.List(ctx, "", models.TodoQuery{Sort: "-created_at", Limit: 2})
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	second, err := storage.List(ctx, "", models.TodoQuery{Sort: "-created_at", Limit: 2, Cursor: first.NextCursor})
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if got := ids(second.Items); !slices.Equal(got, []int{4, 2}) {
+		t.Fatalf("ожидались id [4 2], получено %v", got)
+	}
+}
+
+func TestTodoStorageListByDueAndPriority(t *testing.T) {
+	storage := NewTodoStorage()
+	ctx := context.Background()
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	due := func(hours int) *time.Time {
+		at := base.Add(time.Duration(hours) * time.Hour)
+		return &at
+	}
+	for _, todo := range []models.Todo{
+		{Title: "просрочена", Priority: models.PriorityHigh, DueAt: due(1)},
+		{Title: "не скоро", Priority: models.PriorityLow, DueAt: due(3)},
+		{Title: "без срока"},
+		{Title: "выполнена", Completed: true, Priority: models.PriorityUrgent, DueAt: due(1)},
+		{Title: "срок сейчас", Priority: models.PriorityNormal, DueAt: due(2)},
+	} {
+		if _, err := storage.Create(ctx, todo); err != nil {
+			t.Fatalf("ошибка подготовки данных: %v", err)
+		}
+	}
+
+	now := base.Add(2 * time.Hour)
+	overdue, notOverdue := true, false
+
+	cases := []struct {
+		name    string
+		query   models.TodoQuery
+		wantIDs []int
+	}{
+		{
+			name:    "просроченные: незавершенные со сроком раньше now",
+			query:   models.TodoQuery{Overdue: &overdue, Now: now},
+			wantIDs: []int{1},
+		},
+		{
+			name:    "непросроченные",
+			query:   models.TodoQuery{Overdue: &notOverdue, Now: now},
+			wantIDs: []int{2, 3, 4, 5},
+		},
+		{
+			name:    "приоритет normal включает задачи без приоритета",
+			query:   models.TodoQuery{Priority: models.PriorityNormal},
+			wantIDs: []int{3, 5},
+		},
+		{
+			name:    "due_before исключает задачи без срока",
+			query:   models.TodoQuery{DueAt: models.TimeRange{Before: base.Add(3 * time.Hour)}},
+			wantIDs: []int{1, 4, 5},
+		},
+		{
+			name:    "по убыванию приоритета",
+			query:   models.TodoQuery{Sort: "-priority"},
+			wantIDs: []int{4, 1, 5, 3, 2},
+		},
+		{
+			name:    "по сроку: задачи без срока первыми",
+			query:   models.TodoQuery{Sort: "due_at"},
+			wantIDs: []int{3, 1, 4, 5, 2},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			page, err := storage.List(ctx, "", tc.query)
+			if err != nil {
+				t.Fatalf("неожиданная ошибка: %v", err)
+			}
+			if got := ids(page.Items); !slices.Equal(got, tc.wantIDs) {
+				t.Fatalf("ожидались id %v, получено %v", tc.wantIDs, got)
+			}
+		})
+	}
+
+	// Курсор по приоритету продолжает выдачу с правильной позиции.
+	first, err := storage.List(ctx, "", models.TodoQuery{Sort: "-priority", Limit: 2})
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	second, err := storage.List(ctx, "", models.TodoQuery{Sort: "-priority", Limit: 2, Cursor: first.NextCursor})
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if got := ids(second.Items); !slices.Equal(got, []int{5, 3}) {
+		t.Fatalf("ожидались id [5 3], получено %v", got)
+	}
+}
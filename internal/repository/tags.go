@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"slices"
+
+	"github.com/RoGogDBD/ecom/internal/models"
+)
+
+// Tags возвращает теги активных задач владельца с количеством задач у каждого,
+// начиная с самых частых; теги с одинаковым количеством упорядочены по алфавиту.
+func (s *TodoStorage) Tags(_ context.Context, owner string) ([]models.TagCount, error) {
+	s.rlock(metricOpTags)
+	defer s.mu.RUnlock()
+
+	tn := s.tenants[owner]
+	if tn == nil {
+		return []models.TagCount{}, nil
+	}
+
+	result := make([]models.TagCount, 0, len(tn.tags))
+	for tag, ids := range tn.tags {
+		result = append(result, models.TagCount{Tag: tag, Count: len(ids)})
+	}
+	slices.SortFunc(result, func(a, b models.TagCount) int {
+		if c := cmp.Compare(b.Count, a.Count); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Tag, b.Tag)
+	})
+
+	return result, nil
+}
+
+// tagged возвращает по индексу ID активных задач, у которых есть все теги all
+// и хотя бы один из some. ok == false означает, что фильтра по тегам нет. Вызывается под s.mu.
+func (tn *tenant) tagged(all, some []string) (ids []int, ok bool) {
+	if len(all) == 0 && len(some) == 0 {
+		return nil, false
+	}
+
+	// Перебираем самое узкое из множеств, остальные только проверяем.
+	var smallest map[int]struct{}
+	for _, tag := range all {
+		set := tn.tags[tag]
+		if len(set) == 0 {
+			return []int{}, true
+		}
+		if smallest == nil || len(set) < len(smallest) {
+			smallest = set
+		}
+	}
+
+	if smallest == nil {
+		union := make(map[int]struct{})
+		for _, tag := range some {
+			for id := range tn.tags[tag] {
+				union[id] = struct{}{}
+			}
+		}
+		smallest = union
+	}
+
+	ids = make([]int, 0, len(smallest))
+	for id := range smallest {
+		if tn.hasTags(id, all, some) {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, true
+}
+
+// hasTags проверяет по индексу, что задача id имеет все теги all и хотя бы один из some.
+func (tn *tenant) hasTags(id int, all, some []string) bool {
+	for _, tag := range all {
+		if _, ok := tn.tags[tag][id]; !ok {
+			return false
+		}
+	}
+
+	if len(some) == 0 {
+		return true
+	}
+	for _, tag := range some {
+		if _, ok := tn.tags[tag][id]; ok {
+			return true
+		}
+	}
+
+	return false
+}
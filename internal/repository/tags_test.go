@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/RoGogDBD/ecom/internal/models"
+)
+
+func TestTodoStorageListByTags(t *testing.T) {
+	storage := NewTodoStorage()
+	ctx := context.Background()
+	for _, todo := range []models.Todo{
+		{Title: "релиз", Tags: []string{"backend", "work"}},
+		{Title: "макет", Tags: []string{"frontend", "work"}},
+		{Title: "продукты", Tags: []string{"home"}},
+		{Title: "без тегов"},
+		{Title: "ревью", Tags: []string{"backend", "frontend", "work"}},
+		{Title: "чужая", Owner: "bob", Tags: []string{"work"}},
+	} {
+		if _, err := storage.Create(ctx, todo); err != nil {
+			t.Fatalf("ошибка подготовки данных: %v", err)
+		}
+	}
+
+	cases := []struct {
+		name    string
+		query   models.TodoQuery
+		wantIDs []int
+	}{
+		{
+			name:    "все теги",
+			query:   models.TodoQuery{Tags: []string{"work", "backend"}},
+			wantIDs: []int{1, 5},
+		},
+		{
+			name:    "любой из тегов",
+			query:   models.TodoQuery{AnyTags: []string{"home", "frontend"}},
+			wantIDs: []int{2, 3, 5},
+		},
+		{
+			name:    "все и любой вместе",
+			query:   models.TodoQuery{Tags: []string{"work"}, AnyTags: []string{"frontend", "home"}},
+			wantIDs: []int{2, 5},
+		},
+		{
+			name:    "неизвестный тег",
+			query:   models.TodoQuery{Tags: []string{"work", "missing"}},
+			wantIDs: []int{},
+		},
+		{
+			name:    "теги вместе с остальными фильтрами",
+			query:   models.TodoQuery{Tags: []string{"work"}, Search: "ре"},
+			wantIDs: []int{1, 5},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			page, err := storage.List(ctx, "", tc.query)
+			if err != nil {
+				t.Fatalf("неожиданная ошибка: %v", err)
+			}
+			if got := ids(page.Items); !slices.Equal(got, tc.wantIDs) {
+				t.Fatalf("ожидались id %v, получено %v", tc.wantIDs, got)
+			}
+		})
+	}
+}
+
+func TestTodoStorageTags(t *testing.T) {
+	storage := NewTodoStorage()
+	ctx := context.Background()
+	for _, todo := range []models.Todo{
+		{Title: "релиз", Tags: []string{"backend", "work"}},
+		{Title: "макет", Tags: []string{"frontend", "work"}},
+		{Title: "продукты", Tags: []string{"home"}},
+	} {
+		if _, err := storage.Create(ctx, todo); err != nil {
+			t.Fatalf("ошибка подготовки данных: %v", err)
+		}
+	}
+
+	steps := []struct {
+		name   string
+		change func() error
+		want   []models.TagCount
+	}{
+		{
+			name:   "после создания",
+			change: func() error { return nil },
+			want: []models.TagCount{
+				{Tag: "work", Count: 2},
+				{Tag: "backend", Count: 1},
+				{Tag: "frontend", Count: 1},
+				{Tag: "home", Count: 1},
+			},
+		},
+		{
+			name: "изменение тегов",
+			change: func() error {
+				_, err := storage.Modify(ctx, "", 2, func(todo models.Todo) (models.Todo, error) {
+					todo.Tags = []string{"home"}
+					return todo, nil
+				})
+				return err
+			},
+			want: []models.TagCount{
+				{Tag: "home", Count: 2},
+				{Tag: "backend", Count: 1},
+				{Tag: "work", Count: 1},
+			},
+		},
+		{
+			name: "перемещение в корзину",
+			change: func() error {
+				_, err := storage.SoftDelete(ctx, "", 3, 0, time.Now(), models.ChildrenReject)
+				return err
+			},
+			want: []models.TagCount{
+				{Tag: "backend", Count: 1},
+				{Tag: "home", Count: 1},
+				{Tag: "work", Count: 1},
+			},
+		},
+		{
+			name: "восстановление из корзины",
+			change: func() error {
+				_, err := storage.Restore(ctx, "", 3, 0, time.Now())
+				return err
+			},
+			want: []models.TagCount{
+				{Tag: "home", Count: 2},
+				{Tag: "backend", Count: 1},
+				{Tag: "work", Count: 1},
+			},
+		},
+		{
+			name: "окончательное удаление",
+			change: func() error {
+				_, err := storage.Delete(ctx, "", 1, 0, time.Time{}, models.ChildrenReject)
+				return err
+			},
+			want: []models.TagCount{
+				{Tag: "home", Count: 2},
+			},
+		},
+	}
+
+	for _, step := range steps {
+		if err := step.change(); err != nil {
+			t.Fatalf("%s: неожиданная ошибка: %v", step.name, err)
+		}
+
+		got, err := storage.Tags(ctx, "")
+		if err != nil {
+			t.Fatalf("%s: неожиданная ошибка: %v", step.name, err)
+		}
+		if !reflect.DeepEqual(got, step.want) {
+			t.Fatalf("%s: ожидались теги %+v, получено %+v", step.name, step.want, got)
+		}
+	}
+
+	if got, _ := storage.Tags(ctx, "bob"); len(got) != 0 {
+		t.Fatalf("у владельца без задач ожидался пустой список тегов, получено %+v", got)
+	}
+}
@@ -2,7 +2,10 @@ package repository
 
 import (
 	"context"
+	"slices"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/RoGogDBD/ecom/internal/models"
 )
@@ -10,79 +13,422 @@ import (
 type (
 	// TodoStorage потокобезопасное хранилище для объектов.
 	TodoStorage struct {
-		mu    sync.RWMutex
+		mu sync.RWMutex
+		// tenants задачи по владельцам. Владельцы изолированы: у каждого своя
+		// последовательность ID, и операции одного владельца не видят задачи другого.
+		tenants map[string]*tenant
+		// metrics метрики хранилища; nil, если Instrument не вызывался.
+		metrics *storageMetrics
+		// journal получает каждое изменение до его применения в памяти.
+		// Для чисто in-memory хранилища равен nil.
+		journal journal
+	}
+
+	// tenant задачи одного владельца.
+	tenant struct {
 		items map[int]models.Todo
+		// trash задачи в корзине: удаленные, но еще не удаленные окончательно.
+		trash map[int]models.Todo
+		// history ревизии задач в порядке версий, включая текущую. Пополняется
+		// при каждом изменении задачи и очищается только при ее окончательном удалении.
+		history map[int][]models.Todo
+		// tags инвертированный индекс тегов активных задач: тег -> ID задач с ним.
+		tags map[string]map[int]struct{}
+		// children индекс подзадач: ID задачи -> ID ее активных подзадач.
+		children map[int]map[int]struct{}
+		// lastID последний выданный идентификатор. Изменяется только под mu,
+		// поэтому выдача следующего ID атомарна относительно остальных операций.
+		lastID int
+	}
+
+	// journal принимает изменения хранилища для долговременного сохранения.
+	// append вызывается под s.mu; если он вернул ошибку, изменение не применяется.
+	journal interface {
+		append(records ...record) error
 	}
+
+	// record описывает одно изменение хранилища.
+	record struct {
+		Op   string      `json:"op"`
+		Todo models.Todo `json:"todo,omitzero"`
+		// Records вложенные изменения для opBatch.
+		Records []record `json:"records,omitempty"`
+	}
+)
+
+// Операции записи журнала.
+const (
+	opPut    = "put"
+	opDelete = "delete"
+	// opBatch группа изменений, которая применяется целиком.
+	opBatch = "batch"
 )
 
 // NewTodoStorage создает и возвращает новый экземпляр ToDoStorage.
 func NewTodoStorage() *TodoStorage {
 	return &TodoStorage{
-		items: make(map[int]models.Todo),
+		tenants: make(map[string]*tenant),
 	}
 }
 
-// Create добавляет новый объект в хранилище.
-func (s *TodoStorage) Create(_ context.Context, todo models.Todo) error {
-	s.mu.Lock()
+// Create добавляет новый объект в хранилище владельца todo.Owner.
+// Если ID не указан (равен 0), хранилище выделяет следующий свободный идентификатор владельца.
+// Явно указанный ID сохраняется как есть (например, при импорте), а последовательность
+// сдвигается так, чтобы последующие автоматические ID с ним не пересекались.
+func (s *TodoStorage) Create(_ context.Context, todo models.Todo) (models.Todo, error) {
+	s.lock(metricOpCreate)
 	defer s.mu.Unlock()
 
-	if _, exists := s.items[todo.ID]; exists {
-		return models.ErrDuplicateID
+	t := s.begin(todo.Owner)
+	created, err := t.create(todo)
+	if err != nil {
+		return models.Todo{}, err
 	}
 
-	s.items[todo.ID] = todo
-	return nil
+	if err := s.commit(t.records...); err != nil {
+		return models.Todo{}, err
+	}
+
+	return created, nil
 }
 
-// Update обновляет существующий объект в хранилище.
-func (s *TodoStorage) Update(_ context.Context, todo models.Todo) error {
-	s.mu.Lock()
+// Modify атомарно изменяет объект: fn получает текущее состояние и возвращает новое.
+// fn выполняется под блокировкой хранилища, поэтому между чтением и записью объект
+// не может измениться. Если fn вернул ошибку, объект остается прежним.
+func (s *TodoStorage) Modify(_ context.Context, owner string, id int, fn func(models.Todo) (models.Todo, error)) (models.Todo, error) {
+	s.lock(metricOpModify)
 	defer s.mu.Unlock()
 
-	if _, exists := s.items[todo.ID]; !exists {
-		return models.ErrNotFound
+	t := s.begin(owner)
+	updated, err := t.modify(id, fn)
+	if err != nil {
+		return models.Todo{}, err
 	}
 
-	s.items[todo.ID] = todo
-	return nil
+	if err := s.commit(t.records...); err != nil {
+		return models.Todo{}, err
+	}
+
+	return updated, nil
 }
 
-// Delete удаляет объект из хранилища по его ID.
-func (s *TodoStorage) Delete(_ context.Context, id int) error {
-	s.mu.Lock()
+// SoftDelete перемещает объект в корзину, отмечая время удаления at. Объект в корзине
+// не виден остальным операциям, кроме Restore, Delete и ListTrash. Активные подзадачи
+// обрабатываются согласно children: удаление отклоняется с models.ErrHasChildren,
+// подзадачи перемещаются в корзину вместе с объектом или становятся объектами верхнего уровня.
+// Ненулевой version должен совпадать с текущей версией объекта. Возвращает затронутые объекты.
+func (s *TodoStorage) SoftDelete(_ context.Context, owner string, id, version int, at time.Time, children models.ChildrenMode) (models.Removal, error) {
+	s.lock(metricOpSoftDelete)
 	defer s.mu.Unlock()
 
-	if _, exists := s.items[id]; !exists {
-		return models.ErrNotFound
+	t := s.begin(owner)
+	removal, err := t.softDelete(id, version, at, children)
+	if err != nil {
+		return models.Removal{}, err
 	}
 
-	delete(s.items, id)
-	return nil
+	if err := s.commit(t.records...); err != nil {
+		return models.Removal{}, err
+	}
+
+	return removal, nil
+}
+
+// Restore возвращает объект из корзины, отмечая время восстановления at как время изменения.
+// Ненулевой version должен совпадать с текущей версией объекта.
+func (s *TodoStorage) Restore(_ context.Context, owner string, id, version int, at time.Time) (models.Todo, error) {
+	s.lock(metricOpRestore)
+	defer s.mu.Unlock()
+
+	t := s.begin(owner)
+	todo, err := t.restore(id, version, at)
+	if err != nil {
+		return models.Todo{}, err
+	}
+
+	if err := s.commit(t.records...); err != nil {
+		return models.Todo{}, err
+	}
+
+	return todo, nil
+}
+
+// Delete окончательно удаляет объект по его ID, в том числе из корзины. Подзадачи обрабатываются
+// согласно children, как в SoftDelete; каскадное удаление захватывает и подзадачи из корзины.
+// at — время изменения отвязанных подзадач.
+// Ненулевой version должен совпадать с текущей версией объекта. Возвращает затронутые объекты.
+func (s *TodoStorage) Delete(_ context.Context, owner string, id, version int, at time.Time, children models.ChildrenMode) (models.Removal, error) {
+	s.lock(metricOpDelete)
+	defer s.mu.Unlock()
+
+	t := s.begin(owner)
+	removal, err := t.delete(id, version, at, children)
+	if err != nil {
+		return models.Removal{}, err
+	}
+
+	if err := s.commit(t.records...); err != nil {
+		return models.Removal{}, err
+	}
+
+	return removal, nil
 }
 
-// GetAll возвращает все объекты из хранилища.
-func (s *TodoStorage) GetAll(_ context.Context) ([]models.Todo, error) {
-	s.mu.RLock()
+// GetAll возвращает все объекты владельца.
+func (s *TodoStorage) GetAll(_ context.Context, owner string) ([]models.Todo, error) {
+	s.rlock(metricOpGetAll)
 	defer s.mu.RUnlock()
 
-	result := make([]models.Todo, 0, len(s.items))
-	for _, todo := range s.items {
+	tn := s.tenants[owner]
+	if tn == nil {
+		return []models.Todo{}, nil
+	}
+
+	result := make([]models.Todo, 0, len(tn.items))
+	for _, todo := range tn.items {
 		result = append(result, todo)
 	}
 
 	return result, nil
 }
 
-// GetByID возвращает объект по его ID.
-func (s *TodoStorage) GetByID(_ context.Context, id int) (models.Todo, error) {
-	s.mu.RLock()
+// ListTrash возвращает объекты владельца в корзине, начиная с удаленных последними.
+func (s *TodoStorage) ListTrash(_ context.Context, owner string) ([]models.Todo, error) {
+	s.rlock(metricOpListTrash)
+	defer s.mu.RUnlock()
+
+	tn := s.tenants[owner]
+	if tn == nil {
+		return []models.Todo{}, nil
+	}
+
+	result := make([]models.Todo, 0, len(tn.trash))
+	for _, todo := range tn.trash {
+		result = append(result, todo)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].DeletedAt.Equal(*result[j].DeletedAt) {
+			return result[i].DeletedAt.After(*result[j].DeletedAt)
+		}
+		return result[i].ID > result[j].ID
+	})
+
+	return result, nil
+}
+
+// PurgeTrash окончательно удаляет объекты всех владельцев, попавшие в корзину раньше before.
+// Возвращает количество удаленных объектов.
+func (s *TodoStorage) PurgeTrash(_ context.Context, before time.Time) (int, error) {
+	s.lock(metricOpPurgeTrash)
+	defer s.mu.Unlock()
+
+	var records []record
+	for _, tn := range s.tenants {
+		for _, todo := range tn.trash {
+			if todo.DeletedAt.Before(before) {
+				records = append(records, record{Op: opDelete, Todo: todo})
+			}
+		}
+	}
+
+	if err := s.commit(records...); err != nil {
+		return 0, err
+	}
+
+	return len(records), nil
+}
+
+// Reminders возвращает незавершенные объекты всех владельцев с напоминанием позже after.
+// Объекты в корзине не возвращаются.
+func (s *TodoStorage) Reminders(_ context.Context, after time.Time) ([]models.Todo, error) {
+	s.rlock(metricOpReminders)
 	defer s.mu.RUnlock()
 
-	todo, exists := s.items[id]
+	var todos []models.Todo
+	for _, tn := range s.tenants {
+		for _, todo := range tn.items {
+			if !todo.Completed && todo.RemindAt != nil && todo.RemindAt.After(after) {
+				todos = append(todos, todo)
+			}
+		}
+	}
+
+	return todos, nil
+}
+
+// History возвращает ревизии объекта владельца, начиная с первой, в том числе
+// для объекта в корзине. Для окончательно удаленного объекта возвращает models.ErrNotFound.
+func (s *TodoStorage) History(_ context.Context, owner string, id int) ([]models.Todo, error) {
+	s.rlock(metricOpHistory)
+	defer s.mu.RUnlock()
+
+	tn := s.tenants[owner]
+	if tn == nil || len(tn.history[id]) == 0 {
+		return nil, models.ErrNotFound
+	}
+
+	return slices.Clone(tn.history[id]), nil
+}
+
+// GetByID возвращает объект владельца по его ID. Объекты других владельцев
+// неотличимы от отсутствующих.
+func (s *TodoStorage) GetByID(_ context.Context, owner string, id int) (models.Todo, error) {
+	s.rlock(metricOpGet)
+	defer s.mu.RUnlock()
+
+	tn := s.tenants[owner]
+	if tn == nil {
+		return models.Todo{}, models.ErrNotFound
+	}
+
+	todo, exists := tn.items[id]
 	if !exists {
 		return models.Todo{}, models.ErrNotFound
 	}
 
 	return todo, nil
 }
+
+// commit передает изменения в журнал и применяет их в памяти. Вызывается под s.mu.
+func (s *TodoStorage) commit(records ...record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	if s.journal != nil {
+		if err := s.journal.append(records...); err != nil {
+			return err
+		}
+	}
+
+	for _, rec := range records {
+		s.apply(rec)
+	}
+
+	return nil
+}
+
+// apply применяет одно изменение к данным в памяти. Вызывается под s.mu
+// либо при восстановлении из журнала, когда хранилище еще никому не доступно.
+func (s *TodoStorage) apply(rec record) {
+	switch rec.Op {
+	case opPut:
+		// Задача с DeletedAt хранится в корзине, без него — среди активных.
+		tn := s.tenant(rec.Todo.Owner)
+		tn.unindex(rec.Todo.ID)
+		if rec.Todo.DeletedAt != nil {
+			delete(tn.items, rec.Todo.ID)
+			tn.trash[rec.Todo.ID] = rec.Todo
+		} else {
+			delete(tn.trash, rec.Todo.ID)
+			tn.items[rec.Todo.ID] = rec.Todo
+			tn.index(rec.Todo)
+		}
+		tn.remember(rec.Todo)
+		if rec.Todo.ID > tn.lastID {
+			tn.lastID = rec.Todo.ID
+		}
+	case opDelete:
+		// Владелец остается в tenants даже без задач: его lastID нужен,
+		// чтобы удаленные ID не выдавались повторно.
+		tn := s.tenant(rec.Todo.Owner)
+		tn.unindex(rec.Todo.ID)
+		delete(tn.items, rec.Todo.ID)
+		delete(tn.trash, rec.Todo.ID)
+		delete(tn.history, rec.Todo.ID)
+	case opBatch:
+		for _, nested := range rec.Records {
+			s.apply(nested)
+		}
+	}
+}
+
+// tenant возвращает задачи владельца, создавая их при первом обращении. Вызывается под s.mu.
+func (s *TodoStorage) tenant(owner string) *tenant {
+	tn, ok := s.tenants[owner]
+	if !ok {
+		tn = &tenant{
+			items:    make(map[int]models.Todo),
+			trash:    make(map[int]models.Todo),
+			history:  make(map[int][]models.Todo),
+			tags:     make(map[string]map[int]struct{}),
+			children: make(map[int]map[int]struct{}),
+		}
+		s.tenants[owner] = tn
+	}
+
+	return tn
+}
+
+// remember добавляет ревизию в историю задачи. Ревизии не новее последней пропускаются:
+// записи журнала, уже вошедшие в снимок, при повторном проигрывании историю не дублируют.
+func (tn *tenant) remember(todo models.Todo) {
+	revisions := tn.history[todo.ID]
+	if n := len(revisions); n > 0 && revisions[n-1].Version >= todo.Version {
+		return
+	}
+	tn.history[todo.ID] = append(revisions, todo)
+}
+
+// index добавляет активную задачу в индексы тегов и подзадач. Вызывается под s.mu.
+func (tn *tenant) index(todo models.Todo) {
+	for _, tag := range todo.Tags {
+		ids := tn.tags[tag]
+		if ids == nil {
+			ids = make(map[int]struct{})
+			tn.tags[tag] = ids
+		}
+		ids[todo.ID] = struct{}{}
+	}
+
+	if todo.ParentID != 0 {
+		ids := tn.children[todo.ParentID]
+		if ids == nil {
+			ids = make(map[int]struct{})
+			tn.children[todo.ParentID] = ids
+		}
+		ids[todo.ID] = struct{}{}
+	}
+}
+
+// unindex удаляет из индексов активную задачу id, если она есть. Вызывается под s.mu.
+func (tn *tenant) unindex(id int) {
+	todo, ok := tn.items[id]
+	if !ok {
+		return
+	}
+
+	for _, tag := range todo.Tags {
+		delete(tn.tags[tag], id)
+		if len(tn.tags[tag]) == 0 {
+			delete(tn.tags, tag)
+		}
+	}
+
+	if todo.ParentID != 0 {
+		delete(tn.children[todo.ParentID], id)
+		if len(tn.children[todo.ParentID]) == 0 {
+			delete(tn.children, todo.ParentID)
+		}
+	}
+}
+
+// count возвращает общее количество активных задач всех владельцев. Вызывается под s.mu.
+func (s *TodoStorage) count() int {
+	var n int
+	for _, tn := range s.tenants {
+		n += len(tn.items)
+	}
+
+	return n
+}
+
+// countTrash возвращает общее количество задач в корзинах всех владельцев. Вызывается под s.mu.
+func (s *TodoStorage) countTrash() int {
+	var n int
+	for _, tn := range s.tenants {
+		n += len(tn.trash)
+	}
+
+	return n
+}
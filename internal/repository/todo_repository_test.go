@@ -3,7 +3,11 @@ package repository
 import (
 	"context"
 	"errors"
+	"math"
+	"reflect"
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/RoGogDBD/ecom/internal/models"
 )
@@ -25,6 +29,15 @@ func TestTodoStorageCreate(t *testing.T) {
 			todo:    models.Todo{ID: 1, Title: "дубликат"},
 			wantErr: models.ErrDuplicateID,
 		},
+		{
+			name: "предпоследний id",
+			todo: models.Todo{ID: math.MaxInt - 1, Title: "граница"},
+		},
+		{
+			name:    "id переполняет последовательность",
+			todo:    models.Todo{ID: math.MaxInt, Title: "переполнение"},
+			wantErr: models.ErrInvalidID,
+		},
 	}
 
 	for _, tc := range cases {
@@ -33,25 +46,412 @@ func TestTodoStorageCreate(t *testing.T) {
 			storage := NewTodoStorage()
 			ctx := context.Background()
 			for _, item := range tc.initial {
-				if err := storage.Create(ctx, item); err != nil {
+				if _, err := storage.Create(ctx, item); err != nil {
 					t.Fatalf("ошибка подготовки данных: %v", err)
 				}
 			}
 
-			err := storage.Create(ctx, tc.todo)
+			_, err := storage.Create(ctx, tc.todo)
 			if !errors.Is(err, tc.wantErr) {
 				t.Fatalf("ожидалась ошибка %v, получено %v", tc.wantErr, err)
 			}
 
 			if tc.wantErr == nil {
-				got, err := storage.GetByID(ctx, tc.todo.ID)
+				got, err := storage.GetByID(ctx, "", tc.todo.ID)
 				if err != nil {
 					t.Fatalf("ожидалась сохраненная задача, получена ошибка: %v", err)
 				}
-				if got != tc.todo {
-					t.Fatalf("ожидалась задача %+v, получено %+v", tc.todo, got)
+				want := tc.todo
+				want.Version = 1
+				if !reflect.DeepEqual(got, want) {
+					t.Fatalf("ожидалась задача %+v, получено %+v", want, got)
 				}
 			}
 		})
 	}
 }
+
+func TestTodoStorageCreateAssignsID(t *testing.T) {
+	storage := NewTodoStorage()
+	ctx := context.Background()
+
+	first, err := storage.Create(ctx, models.Todo{Title: "первая"})
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if first.ID != 1 {
+		t.Fatalf("ожидался id 1, получено %d", first.ID)
+	}
+
+	if _, err := storage.Create(ctx, models.Todo{ID: 10, Title: "импорт"}); err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+
+	next, err := storage.Create(ctx, models.Todo{Title: "после импорта"})
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if next.ID != 11 {
+		t.Fatalf("ожидался id 11, получено %d", next.ID)
+	}
+
+	got, err := storage.GetByID(ctx, "", next.ID)
+	if err != nil {
+		t.Fatalf("ожидалась сохраненная задача, получена ошибка: %v", err)
+	}
+	if !reflect.DeepEqual(got, next) {
+		t.Fatalf("ожидалась задача %+v, получено %+v", next, got)
+	}
+
+	if _, err := storage.Create(ctx, models.Todo{ID: math.MaxInt - 1, Title: "граница"}); err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if _, err := storage.Create(ctx, models.Todo{Title: "после границы"}); !errors.Is(err, models.ErrInvalidID) {
+		t.Fatalf("ожидалась ошибка %v, получено %v", models.ErrInvalidID, err)
+	}
+}
+
+func TestTodoStorageModify(t *testing.T) {
+	errReject := errors.New("отклонено")
+
+	cases := []struct {
+		name    string
+		id      int
+		fn      func(models.Todo) (models.Todo, error)
+		want    models.Todo
+		wantErr error
+	}{
+		{
+			name: "успешное изменение",
+			id:   1,
+			fn: func(todo models.Todo) (models.Todo, error) {
+				todo.Completed = true
+				return todo, nil
+			},
+			want: models.Todo{ID: 1, Title: "исходная", Completed: true, Version: 2},
+		},
+		{
+			name: "ошибка функции не меняет объект",
+			id:   1,
+			fn: func(todo models.Todo) (models.Todo, error) {
+				return models.Todo{}, errReject
+			},
+			want:    models.Todo{ID: 1, Title: "исходная", Version: 1},
+			wantErr: errReject,
+		},
+		{
+			name: "не найдено",
+			id:   2,
+			fn: func(todo models.Todo) (models.Todo, error) {
+				return todo, nil
+			},
+			want:    models.Todo{ID: 1, Title: "исходная", Version: 1},
+			wantErr: models.ErrNotFound,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			storage := NewTodoStorage()
+			ctx := context.Background()
+			if _, err := storage.Create(ctx, models.Todo{ID: 1, Title: "исходная"}); err != nil {
+				t.Fatalf("ошибка подготовки данных: %v", err)
+			}
+
+			_, err := storage.Modify(ctx, "", tc.id, tc.fn)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("ожидалась ошибка %v, получено %v", tc.wantErr, err)
+			}
+
+			got, err := storage.GetByID(ctx, "", 1)
+			if err != nil {
+				t.Fatalf("неожиданная ошибка: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("ожидалась задача %+v, получено %+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestTodoStorageDeleteVersion(t *testing.T) {
+	cases := []struct {
+		name    string
+		version int
+		wantErr error
+	}{
+		{name: "без условия", version: 0},
+		{name: "совпадающая версия", version: 2},
+		{name: "устаревшая версия", version: 1, wantErr: models.ErrVersionMismatch},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			storage := NewTodoStorage()
+			ctx := context.Background()
+			created, err := storage.Create(ctx, models.Todo{Title: "исходная"})
+			if err != nil {
+				t.Fatalf("ошибка подготовки данных: %v", err)
+			}
+			rename := func(todo models.Todo) (models.Todo, error) {
+				todo.Title = "новая"
+				return todo, nil
+			}
+			if _, err := storage.Modify(ctx, "", created.ID, rename); err != nil {
+				t.Fatalf("ошибка подготовки данных: %v", err)
+			}
+
+			removal, err := storage.Delete(ctx, "", created.ID, tc.version, time.Time{}, models.ChildrenReject)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("ожидалась ошибка %v, получено %v", tc.wantErr, err)
+			}
+			if tc.wantErr == nil && (removal.Deleted[0].Title != "новая" || removal.Deleted[0].Version != 2) {
+				t.Fatalf("ожидалась удаленная задача версии 2, получено %+v", removal.Deleted)
+			}
+
+			_, err = storage.GetByID(ctx, "", created.ID)
+			if deleted := errors.Is(err, models.ErrNotFound); deleted != (tc.wantErr == nil) {
+				t.Fatalf("задача удалена: %v, ожидалось %v", deleted, tc.wantErr == nil)
+			}
+		})
+	}
+}
+
+func TestTodoStorageTenants(t *testing.T) {
+	storage := NewTodoStorage()
+	ctx := context.Background()
+
+	alice, err := storage.Create(ctx, models.Todo{Title: "задача alice", Owner: "alice"})
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	bob, err := storage.Create(ctx, models.Todo{Title: "задача bob", Owner: "bob"})
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if alice.ID != 1 || bob.ID != 1 {
+		t.Fatalf("ожидались независимые последовательности id, получено %d и %d", alice.ID, bob.ID)
+	}
+
+	if _, err := storage.GetByID(ctx, "bob", 1); err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if _, err := storage.GetByID(ctx, "eve", 1); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("ожидалась ошибка %v для чужой задачи, получено %v", models.ErrNotFound, err)
+	}
+	if _, err := storage.Delete(ctx, "eve", 1, 0, time.Time{}, models.ChildrenReject); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("ожидалась ошибка %v при удалении чужой задачи, получено %v", models.ErrNotFound, err)
+	}
+
+	// Владельца нельзя подменить через Modify.
+	moved, err := storage.Modify(ctx, "alice", 1, func(todo models.Todo) (models.Todo, error) {
+		todo.Owner = "bob"
+		return todo, nil
+	})
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if moved.Owner != "alice" {
+		t.Fatalf("ожидался владелец alice, получено %q", moved.Owner)
+	}
+
+	items, err := storage.GetAll(ctx, "bob")
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if len(items) != 1 || items[0].Title != "задача bob" {
+		t.Fatalf("ожидалась только задача bob, получено %+v", items)
+	}
+}
+
+func TestTodoStorageTrash(t *testing.T) {
+	storage := NewTodoStorage()
+	ctx := context.Background()
+	deletedAt := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	for _, title := range []string{"первая", "вторая"} {
+		if _, err := storage.Create(ctx, models.Todo{Title: title}); err != nil {
+			t.Fatalf("ошибка подготовки данных: %v", err)
+		}
+	}
+
+	removal, err := storage.SoftDelete(ctx, "", 1, 1, deletedAt, models.ChildrenReject)
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	trashed := removal.Deleted[0]
+	if trashed.DeletedAt == nil || !trashed.DeletedAt.Equal(deletedAt) || trashed.Version != 2 {
+		t.Fatalf("ожидалась задача в корзине версии 2, получено %+v", trashed)
+	}
+
+	// Задача в корзине не видна обычным операциям, но ее ID занят.
+	if _, err := storage.GetByID(ctx, "", 1); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("ожидалась ошибка %v, получено %v", models.ErrNotFound, err)
+	}
+	if _, err := storage.SoftDelete(ctx, "", 1, 0, deletedAt, models.ChildrenReject); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("ожидалась ошибка %v при повторном удалении, получено %v", models.ErrNotFound, err)
+	}
+	if _, err := storage.Create(ctx, models.Todo{ID: 1, Title: "импорт"}); !errors.Is(err, models.ErrDuplicateID) {
+		t.Fatalf("ожидалась ошибка %v, получено %v", models.ErrDuplicateID, err)
+	}
+	if items, _ := storage.GetAll(ctx, ""); len(items) != 1 {
+		t.Fatalf("ожидалась 1 активная задача, получено %d", len(items))
+	}
+
+	trash, err := storage.ListTrash(ctx, "")
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if len(trash) != 1 || trash[0].ID != 1 {
+		t.Fatalf("ожидалась задача 1 в корзине, получено %+v", trash)
+	}
+
+	if _, err := storage.Restore(ctx, "", 2, 0, deletedAt); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("ожидалась ошибка %v для задачи не из корзины, получено %v", models.ErrNotFound, err)
+	}
+	if _, err := storage.Restore(ctx, "", 1, 1, deletedAt); !errors.Is(err, models.ErrVersionMismatch) {
+		t.Fatalf("ожидалась ошибка %v, получено %v", models.ErrVersionMismatch, err)
+	}
+	restoredAt := deletedAt.Add(time.Hour)
+	restored, err := storage.Restore(ctx, "", 1, 2, restoredAt)
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if restored.DeletedAt != nil || restored.Version != 3 || !restored.UpdatedAt.Equal(restoredAt) {
+		t.Fatalf("ожидалась восстановленная задача версии 3, получено %+v", restored)
+	}
+	if _, err := storage.GetByID(ctx, "", 1); err != nil {
+		t.Fatalf("ожидалась восстановленная задача, получена ошибка: %v", err)
+	}
+}
+
+func TestTodoStoragePurgeTrash(t *testing.T) {
+	storage := NewTodoStorage()
+	ctx := context.Background()
+	old := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	recent := old.Add(48 * time.Hour)
+
+	for _, owner := range []string{"alice", "alice", "bob"} {
+		if _, err := storage.Create(ctx, models.Todo{Title: "задача", Owner: owner}); err != nil {
+			t.Fatalf("ошибка подготовки данных: %v", err)
+		}
+	}
+	deletions := []struct {
+		owner string
+		id    int
+		at    time.Time
+	}{
+		{owner: "alice", id: 1, at: old},
+		{owner: "alice", id: 2, at: recent},
+		{owner: "bob", id: 1, at: old},
+	}
+	for _, d := range deletions {
+		if _, err := storage.SoftDelete(ctx, d.owner, d.id, 0, d.at, models.ChildrenReject); err != nil {
+			t.Fatalf("ошибка подготовки данных: %v", err)
+		}
+	}
+
+	purged, err := storage.PurgeTrash(ctx, old.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if purged != 2 {
+		t.Fatalf("ожидалось 2 удаленные задачи, получено %d", purged)
+	}
+
+	trash, _ := storage.ListTrash(ctx, "alice")
+	if len(trash) != 1 || trash[0].ID != 2 {
+		t.Fatalf("в корзине alice ожидалась задача 2, получено %+v", trash)
+	}
+	if _, err := storage.Restore(ctx, "bob", 1, 0, recent); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("ожидалась ошибка %v для окончательно удаленной задачи, получено %v", models.ErrNotFound, err)
+	}
+}
+
+func TestTodoStorageReminders(t *testing.T) {
+	storage := NewTodoStorage()
+	ctx := context.Background()
+	now := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+
+	for _, todo := range []models.Todo{
+		{Title: "будущее напоминание", Owner: "alice", RemindAt: &future},
+		{Title: "прошедшее напоминание", Owner: "alice", RemindAt: &past},
+		{Title: "выполнена", Owner: "alice", Completed: true, RemindAt: &future},
+		{Title: "в корзине", Owner: "alice", RemindAt: &future},
+		{Title: "без напоминания", Owner: "bob"},
+		{Title: "другой владелец", Owner: "bob", RemindAt: &future},
+	} {
+		if _, err := storage.Create(ctx, todo); err != nil {
+			t.Fatalf("ошибка подготовки данных: %v", err)
+		}
+	}
+	if _, err := storage.SoftDelete(ctx, "alice", 4, 0, now, models.ChildrenReject); err != nil {
+		t.Fatalf("ошибка подготовки данных: %v", err)
+	}
+
+	todos, err := storage.Reminders(ctx, now)
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+
+	var got []string
+	for _, todo := range todos {
+		got = append(got, todo.Title)
+	}
+	slices.Sort(got)
+	if want := []string{"будущее напоминание", "другой владелец"}; !slices.Equal(got, want) {
+		t.Fatalf("ожидались задачи %v, получено %v", want, got)
+	}
+}
+
+func TestTodoStorageHistory(t *testing.T) {
+	storage := NewTodoStorage()
+	ctx := context.Background()
+	deletedAt := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	if _, err := storage.Create(ctx, models.Todo{Title: "черновик"}); err != nil {
+		t.Fatalf("ошибка подготовки данных: %v", err)
+	}
+	if _, err := storage.Modify(ctx, "", 1, func(todo models.Todo) (models.Todo, error) {
+		todo.Title = "задача"
+		return todo, nil
+	}); err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if _, err := storage.SoftDelete(ctx, "", 1, 0, deletedAt, models.ChildrenReject); err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+
+	// История задачи в корзине доступна.
+	history, err := storage.History(ctx, "", 1)
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if len(history) != 3 {
+		t.Fatalf("ожидалось 3 ревизии, получено %d", len(history))
+	}
+	for i, todo := range history {
+		if todo.Version != i+1 {
+			t.Fatalf("ревизия %d: ожидалась версия %d, получено %d", i, i+1, todo.Version)
+		}
+	}
+	if history[0].Title != "черновик" || history[1].Title != "задача" || history[2].DeletedAt == nil {
+		t.Fatalf("неверное содержимое истории: %+v", history)
+	}
+
+	// История другого владельца не видна.
+	if _, err := storage.History(ctx, "bob", 1); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("ожидалась ошибка %v, получено %v", models.ErrNotFound, err)
+	}
+
+	// Окончательное удаление стирает историю.
+	if _, err := storage.Delete(ctx, "", 1, 0, time.Time{}, models.ChildrenReject); err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if _, err := storage.History(ctx, "", 1); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("ожидалась ошибка %v, получено %v", models.ErrNotFound, err)
+	}
+}
@@ -0,0 +1,284 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/RoGogDBD/ecom/internal/models"
+)
+
+// tx накапливает изменения поверх текущего состояния хранилища, не трогая его.
+// Используется под s.mu: изменения становятся видимыми только после commit,
+// поэтому набор операций применяется либо целиком, либо никак.
+// Транзакция видит и изменяет задачи только одного владельца.
+type tx struct {
+	s     *TodoStorage
+	owner string
+	items map[int]models.Todo
+	trash map[int]models.Todo
+	// childIndex индекс подзадач хранилища без учета изменений транзакции.
+	childIndex map[int]map[int]struct{}
+	// staged измененные задачи: активные и удаленные в корзину различаются по DeletedAt,
+	// nil означает окончательное удаление.
+	staged  map[int]*models.Todo
+	lastID  int
+	records []record
+}
+
+// savepoint состояние транзакции, к которому можно откатиться через rollback.
+type savepoint struct {
+	records int
+	lastID  int
+}
+
+// begin начинает транзакцию над задачами владельца owner. Вызывается под s.mu.
+func (s *TodoStorage) begin(owner string) *tx {
+	t := &tx{
+		s:      s,
+		owner:  owner,
+		staged: make(map[int]*models.Todo),
+	}
+	if tn := s.tenants[owner]; tn != nil {
+		t.items = tn.items
+		t.trash = tn.trash
+		t.childIndex = tn.children
+		t.lastID = tn.lastID
+	}
+
+	return t
+}
+
+// Apply применяет набор изменений задач владельца owner под одной блокировкой хранилища.
+// В атомарном режиме первая ошибка отменяет весь набор: операция с ошибкой получает ее,
+// остальные — models.ErrBatchAborted. Иначе успешные операции применяются независимо
+// от неуспешных. Ошибка второго результата означает сбой сохранения всего набора.
+func (s *TodoStorage) Apply(_ context.Context, owner string, muts []models.Mutation, atomic bool) ([]models.BatchResult, error) {
+	s.lock(metricOpApply)
+	defer s.mu.Unlock()
+
+	t := s.begin(owner)
+	results := make([]models.BatchResult, len(muts))
+	for i, mut := range muts {
+		sp := t.savepoint()
+		todo, err := t.apply(mut)
+		results[i] = models.BatchResult{Todo: todo, Err: err}
+		// Неуспешная операция могла успеть изменить задачи, например переместить
+		// в корзину родителя до проверки подзадач: ее изменения не применяются.
+		if err != nil {
+			t.rollback(sp)
+		}
+
+		if err != nil && atomic {
+			for j := range results {
+				if j != i {
+					results[j] = models.BatchResult{Err: models.ErrBatchAborted}
+				}
+			}
+			return results, nil
+		}
+	}
+
+	if err := s.commit(t.records...); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func (t *tx) apply(mut models.Mutation) (models.Todo, error) {
+	switch mut.Kind {
+	case models.BatchCreate:
+		return t.create(mut.Todo)
+	case models.BatchUpdate:
+		return t.modify(mut.ID, mut.Modify)
+	case models.BatchDelete:
+		removal, err := t.softDelete(mut.ID, mut.Version, mut.DeletedAt, models.ChildrenReject)
+		if err != nil {
+			return models.Todo{}, err
+		}
+		return removal.Deleted[0], nil
+	default:
+		return models.Todo{}, fmt.Errorf("%w: неизвестная операция %q", models.ErrInvalidBatch, mut.Kind)
+	}
+}
+
+func (t *tx) savepoint() savepoint {
+	return savepoint{records: len(t.records), lastID: t.lastID}
+}
+
+// rollback отменяет изменения транзакции, сделанные после sp. staged восстанавливается
+// повтором оставшихся записей, поскольку каждое его изменение сопровождается записью.
+func (t *tx) rollback(sp savepoint) {
+	t.records = t.records[:sp.records]
+	t.lastID = sp.lastID
+
+	clear(t.staged)
+	for _, rec := range t.records {
+		if rec.Op == opDelete {
+			t.staged[rec.Todo.ID] = nil
+			continue
+		}
+		todo := rec.Todo
+		t.staged[todo.ID] = &todo
+	}
+}
+
+// get возвращает активную задачу с учетом изменений транзакции.
+func (t *tx) get(id int) (models.Todo, bool) {
+	todo, ok := t.lookup(id)
+	if !ok || todo.DeletedAt != nil {
+		return models.Todo{}, false
+	}
+
+	return todo, true
+}
+
+// lookup возвращает задачу с учетом изменений транзакции, включая задачи в корзине.
+func (t *tx) lookup(id int) (models.Todo, bool) {
+	if staged, ok := t.staged[id]; ok {
+		if staged == nil {
+			return models.Todo{}, false
+		}
+		return *staged, true
+	}
+
+	if todo, ok := t.items[id]; ok {
+		return todo, true
+	}
+	todo, ok := t.trash[id]
+	return todo, ok
+}
+
+func (t *tx) put(todo models.Todo) {
+	t.staged[todo.ID] = &todo
+	t.records = append(t.records, record{Op: opPut, Todo: todo})
+}
+
+func (t *tx) create(todo models.Todo) (models.Todo, error) {
+	if todo.ID == 0 {
+		todo.ID = t.lastID + 1
+	}
+	// Максимальный ID не выдается: следующий идентификатор последовательности переполнил бы int.
+	if todo.ID == math.MaxInt {
+		return models.Todo{}, fmt.Errorf("%w: id %d исчерпывает последовательность идентификаторов", models.ErrInvalidID, todo.ID)
+	}
+
+	// ID задачи в корзине занят, пока она не удалена окончательно.
+	if _, exists := t.lookup(todo.ID); exists {
+		return models.Todo{}, models.ErrDuplicateID
+	}
+	if todo.ID > t.lastID {
+		t.lastID = todo.ID
+	}
+	todo.Owner = t.owner
+	todo.Version = 1
+	todo.DeletedAt = nil
+	if err := t.checkParent(todo); err != nil {
+		return models.Todo{}, err
+	}
+
+	t.put(todo)
+	return todo, nil
+}
+
+func (t *tx) modify(id int, fn func(models.Todo) (models.Todo, error)) (models.Todo, error) {
+	current, exists := t.get(id)
+	if !exists {
+		return models.Todo{}, models.ErrNotFound
+	}
+
+	updated, err := fn(current)
+	if err != nil {
+		return models.Todo{}, err
+	}
+	updated.ID = id
+	updated.Owner = t.owner
+	updated.Version = current.Version + 1
+	updated.DeletedAt = nil
+	if err := t.checkParent(updated); err != nil {
+		return models.Todo{}, err
+	}
+
+	t.put(updated)
+	return updated, nil
+}
+
+// softDelete перемещает активную задачу в корзину, поступая с ее подзадачами согласно mode.
+func (t *tx) softDelete(id, version int, at time.Time, mode models.ChildrenMode) (models.Removal, error) {
+	todo, exists := t.get(id)
+	if !exists {
+		return models.Removal{}, models.ErrNotFound
+	}
+	if version != 0 && version != todo.Version {
+		return models.Removal{}, models.ErrVersionMismatch
+	}
+
+	todo.DeletedAt = &at
+	todo.Version++
+	t.put(todo)
+
+	removal := models.Removal{Deleted: []models.Todo{todo}}
+	if err := t.release(id, at, mode, false, &removal); err != nil {
+		return models.Removal{}, err
+	}
+
+	return removal, nil
+}
+
+// restore возвращает задачу из корзины в активные.
+func (t *tx) restore(id, version int, at time.Time) (models.Todo, error) {
+	todo, exists := t.lookup(id)
+	if !exists || todo.DeletedAt == nil {
+		return models.Todo{}, models.ErrNotFound
+	}
+	if version != 0 && version != todo.Version {
+		return models.Todo{}, models.ErrVersionMismatch
+	}
+
+	// Подзадача возвращается к родителю, только если он активен. Родителя из корзины
+	// нужно восстановить первым, а без окончательно удаленного задача становится верхнего уровня.
+	if todo.ParentID != 0 {
+		if _, ok := t.get(todo.ParentID); !ok {
+			if _, trashed := t.lookup(todo.ParentID); trashed {
+				return models.Todo{}, fmt.Errorf("%w: родительская задача %d в корзине", models.ErrInvalidParent, todo.ParentID)
+			}
+			todo.ParentID = 0
+		}
+	}
+
+	todo.DeletedAt = nil
+	todo.UpdatedAt = at
+	todo.Version++
+
+	t.put(todo)
+	return todo, nil
+}
+
+// delete окончательно удаляет задачу, активную или из корзины, поступая с ее подзадачами согласно mode.
+// at — время изменения отвязанных подзадач.
+func (t *tx) delete(id, version int, at time.Time, mode models.ChildrenMode) (models.Removal, error) {
+	todo, exists := t.lookup(id)
+	if !exists {
+		return models.Removal{}, models.ErrNotFound
+	}
+	if version != 0 && version != todo.Version {
+		return models.Removal{}, models.ErrVersionMismatch
+	}
+
+	t.drop(todo)
+
+	removal := models.Removal{Deleted: []models.Todo{todo}}
+	if err := t.release(id, at, mode, true, &removal); err != nil {
+		return models.Removal{}, err
+	}
+
+	return removal, nil
+}
+
+// drop окончательно удаляет задачу.
+func (t *tx) drop(todo models.Todo) {
+	t.staged[todo.ID] = nil
+	t.records = append(t.records, record{Op: opDelete, Todo: todo})
+}
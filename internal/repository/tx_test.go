@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/RoGogDBD/ecom/internal/models"
+)
+
+func TestTodoStorageApply(t *testing.T) {
+	rename := func(todo models.Todo) (models.Todo, error) {
+		todo.Title = "переименована"
+		return todo, nil
+	}
+
+	cases := []struct {
+		name        string
+		muts        []models.Mutation
+		atomic      bool
+		wantResults []error
+		wantIDs     []int
+	}{
+		{
+			name: "атомарно: успешный пакет",
+			muts: []models.Mutation{
+				{Kind: models.BatchCreate, Todo: models.Todo{Title: "новая"}},
+				{Kind: models.BatchUpdate, ID: 1, Modify: rename},
+				{Kind: models.BatchDelete, ID: 1},
+			},
+			atomic:      true,
+			wantResults: []error{nil, nil, nil},
+			wantIDs:     []int{2},
+		},
+		{
+			name: "атомарно: ошибка отменяет весь пакет",
+			muts: []models.Mutation{
+				{Kind: models.BatchCreate, Todo: models.Todo{Title: "новая"}},
+				{Kind: models.BatchDelete, ID: 42},
+				{Kind: models.BatchDelete, ID: 1},
+			},
+			atomic:      true,
+			wantResults: []error{models.ErrBatchAborted, models.ErrNotFound, models.ErrBatchAborted},
+			wantIDs:     []int{1},
+		},
+		{
+			name: "неатомарно: ошибка не мешает остальным",
+			muts: []models.Mutation{
+				{Kind: models.BatchCreate, Todo: models.Todo{ID: 1, Title: "дубликат"}},
+				{Kind: models.BatchCreate, Todo: models.Todo{Title: "новая"}},
+				{Kind: models.BatchDelete, ID: 1, Version: 7},
+			},
+			wantResults: []error{models.ErrDuplicateID, nil, models.ErrVersionMismatch},
+			wantIDs:     []int{1, 2},
+		},
+		{
+			name: "операции видят изменения предыдущих",
+			muts: []models.Mutation{
+				{Kind: models.BatchDelete, ID: 1},
+				{Kind: models.BatchUpdate, ID: 1, Modify: rename},
+			},
+			wantResults: []error{nil, models.ErrNotFound},
+			wantIDs:     []int{},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			storage := NewTodoStorage()
+			ctx := context.Background()
+			if _, err := storage.Create(ctx, models.Todo{Title: "исходная"}); err != nil {
+				t.Fatalf("ошибка подготовки данных: %v", err)
+			}
+
+			results, err := storage.Apply(ctx, "", tc.muts, tc.atomic)
+			if err != nil {
+				t.Fatalf("неожиданная ошибка: %v", err)
+			}
+			for i, want := range tc.wantResults {
+				if !errors.Is(results[i].Err, want) {
+					t.Fatalf("операция %d: ожидалась ошибка %v, получено %v", i, want, results[i].Err)
+				}
+			}
+
+			page, err := storage.List(ctx, "", models.TodoQuery{})
+			if err != nil {
+				t.Fatalf("неожиданная ошибка: %v", err)
+			}
+			if got := ids(page.Items); !slices.Equal(got, tc.wantIDs) {
+				t.Fatalf("ожидались id %v, получено %v", tc.wantIDs, got)
+			}
+		})
+	}
+}
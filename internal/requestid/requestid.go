@@ -0,0 +1,54 @@
+// Package requestid хранит идентификатор запроса в контексте, чтобы связывать
+// записи логов и ответы сервера с конкретным запросом клиента.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+const (
+	// maxLength ограничивает длину идентификатора, принятого от клиента.
+	maxLength = 128
+	// generatedBytes количество случайных байт в сгенерированном идентификаторе.
+	generatedBytes = 16
+)
+
+type contextKey struct{}
+
+// NewContext возвращает копию ctx с идентификатором запроса.
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext возвращает идентификатор запроса из ctx или пустую строку.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// New генерирует случайный идентификатор из 32 шестнадцатеричных символов.
+func New() string {
+	b := make([]byte, generatedBytes)
+	// crypto/rand.Read не возвращает ошибку на поддерживаемых платформах.
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Valid проверяет идентификатор, полученный от клиента: непустой, не длиннее
+// maxLength и только из видимых ASCII-символов, чтобы его можно было безопасно
+// вернуть в заголовке и записать в лог.
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] < '!' || id[i] > '~' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,51 @@
+package requestid
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestContext(t *testing.T) {
+	if got := FromContext(context.Background()); got != "" {
+		t.Errorf("FromContext() = %q, ожидалась пустая строка", got)
+	}
+
+	ctx := NewContext(context.Background(), "abc-123")
+	if got := FromContext(ctx); got != "abc-123" {
+		t.Errorf("FromContext() = %q, ожидалось %q", got, "abc-123")
+	}
+}
+
+func TestNew(t *testing.T) {
+	first, second := New(), New()
+	if len(first) != 2*generatedBytes || !Valid(first) {
+		t.Errorf("New() = %q, ожидался валидный идентификатор длины %d", first, 2*generatedBytes)
+	}
+	if first == second {
+		t.Errorf("New() вернул одинаковые идентификаторы %q", first)
+	}
+}
+
+func TestValid(t *testing.T) {
+	tests := []struct {
+		name string
+		id   string
+		want bool
+	}{
+		{name: "uuid", id: "0f8fad5b-d9cb-469f-a165-70867728950e", want: true},
+		{name: "пустой", id: "", want: false},
+		{name: "слишком длинный", id: strings.Repeat("a", maxLength+1), want: false},
+		{name: "пробел", id: "abc def", want: false},
+		{name: "перевод строки", id: "abc\nlevel=ERROR", want: false},
+		{name: "не ASCII", id: "запрос", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Valid(tt.id); got != tt.want {
+				t.Errorf("Valid(%q) = %v, ожидалось %v", tt.id, got, tt.want)
+			}
+		})
+	}
+}
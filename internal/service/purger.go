@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultPurgeInterval период проверки корзины, если он не задан.
+	DefaultPurgeInterval = time.Hour
+
+	logPurged      = "trash purged"
+	logPurgeFailed = "trash purge failed"
+)
+
+type (
+	// TrashPurger окончательно удаляет задачи, попавшие в корзину раньше before.
+	TrashPurger interface {
+		PurgeTrash(ctx context.Context, before time.Time) (int, error)
+	}
+
+	// PurgerOptions параметры фоновой очистки корзины.
+	PurgerOptions struct {
+		// TTL сколько задача хранится в корзине до окончательного удаления.
+		TTL time.Duration
+		// Interval период проверки корзины; 0 — DefaultPurgeInterval.
+		Interval time.Duration
+		// Clock источник времени; по умолчанию системные часы.
+		Clock Clock
+		// Logger журнал результатов очистки; по умолчанию slog.Default().
+		Logger *slog.Logger
+	}
+
+	// Purger в фоне окончательно удаляет задачи всех владельцев, пролежавшие в корзине дольше TTL.
+	Purger struct {
+		storage TrashPurger
+		opts    PurgerOptions
+
+		stop      chan struct{}
+		done      chan struct{}
+		closeOnce sync.Once
+	}
+)
+
+// NewPurger создает Purger и запускает фоновую очистку. Остановка — Close.
+func NewPurger(storage TrashPurger, opts PurgerOptions) *Purger {
+	p := newPurger(storage, opts)
+
+	p.stop = make(chan struct{})
+	p.done = make(chan struct{})
+	go p.loop()
+
+	return p
+}
+
+func newPurger(storage TrashPurger, opts PurgerOptions) *Purger {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultPurgeInterval
+	}
+	if opts.Clock == nil {
+		opts.Clock = systemClock{}
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+
+	return &Purger{storage: storage, opts: opts}
+}
+
+// Purge выполняет одну очистку и возвращает количество окончательно удаленных задач.
+func (p *Purger) Purge(ctx context.Context) (int, error) {
+	return p.storage.PurgeTrash(ctx, p.opts.Clock.Now().Add(-p.opts.TTL))
+}
+
+// Close останавливает фоновую очистку и ждет завершения текущей.
+func (p *Purger) Close() {
+	p.closeOnce.Do(func() {
+		close(p.stop)
+		<-p.done
+	})
+}
+
+func (p *Purger) loop() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.purge()
+		}
+	}
+}
+
+func (p *Purger) purge() {
+	purged, err := p.Purge(context.Background())
+	if err != nil {
+		p.opts.Logger.Error(logPurgeFailed, slog.Any("error", err))
+		return
+	}
+	if purged > 0 {
+		p.opts.Logger.Info(logPurged, slog.Int("count", purged))
+	}
+}
@@ -0,0 +1,271 @@
+package service
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/RoGogDBD/ecom/internal/events"
+	"github.com/RoGogDBD/ecom/internal/models"
+)
+
+const (
+	logReminder       = "todo reminder"
+	logReminderFailed = "todo reminder check failed"
+)
+
+type (
+	// ReminderStorage хранилище, из которого планировщик берет задачи с напоминаниями.
+	ReminderStorage interface {
+		Reminders(ctx context.Context, after time.Time) ([]models.Todo, error)
+		GetByID(ctx context.Context, owner string, id int) (models.Todo, error)
+	}
+
+	// Notifier получает задачи, время напоминания о которых наступило.
+	Notifier interface {
+		Notify(todo models.Todo)
+	}
+
+	// NotifierFunc позволяет использовать функцию как Notifier.
+	NotifierFunc func(todo models.Todo)
+
+	// ReminderOptions параметры планировщика напоминаний.
+	ReminderOptions struct {
+		// Notifiers получатели напоминаний в дополнение к журналу.
+		Notifiers []Notifier
+		// Clock источник времени; по умолчанию системные часы.
+		Clock Clock
+		// Logger журнал напоминаний; по умолчанию slog.Default().
+		Logger *slog.Logger
+	}
+
+	// ReminderScheduler в фоне сообщает о задачах в момент их remind_at: пишет напоминание
+	// в журнал и передает задачу получателям. Расписание обновляется через Publish,
+	// поэтому планировщик регистрируется в TodoService как Publisher.
+	ReminderScheduler struct {
+		storage ReminderStorage
+		opts    ReminderOptions
+
+		mu sync.Mutex
+		// queue напоминания в порядке времени; устаревшие записи пропускаются при извлечении.
+		queue reminderQueue
+		// pending актуальное время напоминания каждой задачи.
+		pending map[reminderKey]time.Time
+
+		wake      chan struct{}
+		stop      chan struct{}
+		done      chan struct{}
+		closeOnce sync.Once
+	}
+
+	reminderKey struct {
+		owner string
+		id    int
+	}
+
+	reminder struct {
+		key reminderKey
+		at  time.Time
+	}
+
+	// reminderQueue min-куча напоминаний по времени для container/heap.
+	reminderQueue []reminder
+)
+
+// Notify вызывает f(todo).
+func (f NotifierFunc) Notify(todo models.Todo) {
+	f(todo)
+}
+
+// ReminderPublisher передает напоминания в p как события reminder,
+// например в поток событий или webhook.
+func ReminderPublisher(p Publisher) Notifier {
+	return NotifierFunc(func(todo models.Todo) {
+		p.Publish(events.TypeReminder, todo)
+	})
+}
+
+// NewReminderScheduler загружает из storage будущие напоминания и запускает планировщик.
+// Остановка — Close.
+func NewReminderScheduler(ctx context.Context, storage ReminderStorage, opts ReminderOptions) (*ReminderScheduler, error) {
+	s := newReminderScheduler(storage, opts)
+
+	todos, err := storage.Reminders(ctx, s.opts.Clock.Now())
+	if err != nil {
+		return nil, err
+	}
+	for _, todo := range todos {
+		s.schedule(todo)
+	}
+
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.loop()
+
+	return s, nil
+}
+
+func newReminderScheduler(storage ReminderStorage, opts ReminderOptions) *ReminderScheduler {
+	if opts.Clock == nil {
+		opts.Clock = systemClock{}
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+
+	return &ReminderScheduler{
+		storage: storage,
+		opts:    opts,
+		pending: make(map[reminderKey]time.Time),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// Publish обновляет расписание по изменению задачи: удаленная, завершенная или оставшаяся
+// без будущего напоминания задача снимается с расписания, остальные планируются на remind_at.
+func (s *ReminderScheduler) Publish(typ string, todo models.Todo) {
+	if typ == events.TypeDeleted {
+		todo.RemindAt = nil
+	}
+	s.schedule(todo)
+}
+
+// Len возвращает количество запланированных напоминаний.
+func (s *ReminderScheduler) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.pending)
+}
+
+// Close останавливает планировщик; напоминания, время которых еще не наступило, не отправляются.
+func (s *ReminderScheduler) Close() {
+	s.closeOnce.Do(func() {
+		close(s.stop)
+		<-s.done
+	})
+}
+
+func (s *ReminderScheduler) schedule(todo models.Todo) {
+	key := reminderKey{owner: todo.Owner, id: todo.ID}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if todo.Completed || todo.RemindAt == nil || !todo.RemindAt.After(s.opts.Clock.Now()) {
+		delete(s.pending, key)
+		return
+	}
+	if at, ok := s.pending[key]; ok && at.Equal(*todo.RemindAt) {
+		return
+	}
+
+	s.pending[key] = *todo.RemindAt
+	heap.Push(&s.queue, reminder{key: key, at: *todo.RemindAt})
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *ReminderScheduler) loop() {
+	defer close(s.done)
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-s.wake:
+		case <-timer.C:
+			s.fire(context.Background(), s.opts.Clock.Now())
+		}
+
+		timer.Stop()
+		if next, ok := s.next(); ok {
+			timer.Reset(next.Sub(s.opts.Clock.Now()))
+		}
+	}
+}
+
+// next возвращает время ближайшего актуального напоминания.
+func (s *ReminderScheduler) next() (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for len(s.queue) > 0 {
+		head := s.queue[0]
+		if at, ok := s.pending[head.key]; ok && at.Equal(head.at) {
+			return head.at, true
+		}
+		heap.Pop(&s.queue)
+	}
+
+	return time.Time{}, false
+}
+
+// fire отправляет напоминания, время которых наступило к now. Перед отправкой задача
+// перечитывается из хранилища: напоминание, измененное или снятое в обход Publish, не отправляется.
+func (s *ReminderScheduler) fire(ctx context.Context, now time.Time) {
+	for _, key := range s.due(now) {
+		todo, err := s.storage.GetByID(ctx, key.owner, key.id)
+		if errors.Is(err, models.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			s.opts.Logger.Error(logReminderFailed, slog.Int("id", key.id), slog.Any("error", err))
+			continue
+		}
+		if todo.Completed || todo.RemindAt == nil || todo.RemindAt.After(now) {
+			continue
+		}
+
+		s.opts.Logger.Info(logReminder,
+			slog.String("owner", todo.Owner),
+			slog.Int("id", todo.ID),
+			slog.String("title", todo.Title),
+			slog.Time("remind_at", *todo.RemindAt),
+		)
+		for _, n := range s.opts.Notifiers {
+			n.Notify(todo)
+		}
+	}
+}
+
+// due снимает с расписания и возвращает задачи, время напоминания которых наступило к now.
+func (s *ReminderScheduler) due(now time.Time) []reminderKey {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var keys []reminderKey
+	for len(s.queue) > 0 && !s.queue[0].at.After(now) {
+		r := heap.Pop(&s.queue).(reminder)
+		if at, ok := s.pending[r.key]; ok && at.Equal(r.at) {
+			delete(s.pending, r.key)
+			keys = append(keys, r.key)
+		}
+	}
+
+	return keys
+}
+
+func (q reminderQueue) Len() int           { return len(q) }
+func (q reminderQueue) Less(i, j int) bool { return q[i].at.Before(q[j].at) }
+func (q reminderQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+
+func (q *reminderQueue) Push(x any) {
+	*q = append(*q, x.(reminder))
+}
+
+func (q *reminderQueue) Pop() any {
+	old := *q
+	r := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return r
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/RoGogDBD/ecom/internal/events"
+	"github.com/RoGogDBD/ecom/internal/models"
+)
+
+// Children возвращает активные подзадачи первого уровня задачи id в порядке ID.
+func (s *TodoService) Children(ctx context.Context, id int) ([]models.Todo, error) {
+	if id <= 0 {
+		return nil, models.ErrInvalidID
+	}
+
+	return s.storage.Children(ctx, owner(ctx), id)
+}
+
+// Expand строит для задач todos деревья их активных подзадач всех уровней.
+// Порядок корней сохраняется, подзадачи каждого уровня идут в порядке ID.
+func (s *TodoService) Expand(ctx context.Context, todos []models.Todo) ([]models.TodoTree, error) {
+	ids := make([]int, len(todos))
+	for i, todo := range todos {
+		ids[i] = todo.ID
+	}
+
+	descendants, err := s.storage.Descendants(ctx, owner(ctx), ids)
+	if err != nil {
+		return nil, err
+	}
+
+	byParent := make(map[int][]models.Todo)
+	for _, todo := range descendants {
+		byParent[todo.ParentID] = append(byParent[todo.ParentID], todo)
+	}
+
+	trees := make([]models.TodoTree, len(todos))
+	for i, todo := range todos {
+		trees[i] = buildTree(todo, byParent)
+	}
+
+	return trees, nil
+}
+
+// buildTree собирает дерево задачи из подзадач, сгруппированных по родителю.
+// Хранилище не допускает циклов, поэтому рекурсия конечна.
+func buildTree(todo models.Todo, byParent map[int][]models.Todo) models.TodoTree {
+	children := byParent[todo.ID]
+	tree := models.TodoTree{Todo: todo, Subtasks: make([]models.TodoTree, len(children))}
+	for i, child := range children {
+		tree.Subtasks[i] = buildTree(child, byParent)
+	}
+
+	return tree
+}
+
+// completeParents при включенном WithAutoComplete завершает родителей завершенной задачи
+// todo, у которых завершены все подзадачи, поднимаясь по цепочке, пока это возможно.
+func (s *TodoService) completeParents(ctx context.Context, todo models.Todo) error {
+	if !s.autoComplete || !todo.Completed {
+		return nil
+	}
+
+	for id := todo.ParentID; id != 0; {
+		var previous models.Todo
+		completed, ok, err := s.storage.CompleteParent(ctx, owner(ctx), id, func(current models.Todo) models.Todo {
+			updated := current
+			updated.Completed = true
+			s.stamp(&updated, current)
+			previous = current
+			return updated
+		})
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+		s.publish(events.TypeUpdated, completed)
+		s.publishCompleted(previous, completed)
+
+		id = completed.ParentID
+	}
+
+	return nil
+}
+
+// normalizeChildrenMode проверяет режим обработки подзадач при удалении.
+// Пустой режим — models.ChildrenReject.
+func normalizeChildrenMode(mode models.ChildrenMode) (models.ChildrenMode, error) {
+	if mode == "" {
+		return models.ChildrenReject, nil
+	}
+	if !mode.Valid() {
+		return "", fmt.Errorf("%w: неизвестный режим children %q", models.ErrInvalidQuery, mode)
+	}
+
+	return mode, nil
+}
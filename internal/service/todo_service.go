@@ -1,55 +1,524 @@
 package service
 
 import (
+	"bytes"
+	"cmp"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
 	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/RoGogDBD/ecom/internal/auth"
+	"github.com/RoGogDBD/ecom/internal/events"
+	"github.com/RoGogDBD/ecom/internal/jsonpatch"
 	"github.com/RoGogDBD/ecom/internal/models"
 )
 
+const (
+	// DefaultListLimit размер страницы, если клиент не указал limit.
+	DefaultListLimit = 100
+	// MaxListLimit максимально допустимый размер страницы.
+	MaxListLimit = 1000
+	// MaxBatchSize максимальное количество операций в одном пакете.
+	MaxBatchSize = 1000
+	// MaxTags максимальное количество тегов у задачи.
+	MaxTags = 20
+	// MaxTagLength максимальная длина тега в символах.
+	MaxTagLength = 32
+)
+
 type (
+	// Storage хранит задачи по владельцам. Все операции, кроме Create (владелец берется
+	// из todo.Owner) и PurgeTrash, видят только задачи owner; ID уникальны в пределах владельца.
+	// Задачи в корзине видны только SoftDelete, Restore, Delete, ListTrash и History.
 	Storage interface {
-		Create(ctx context.Context, todo models.Todo) error
-		Update(ctx context.Context, todo models.Todo) error
-		Delete(ctx context.Context, id int) error
-		GetAll(ctx context.Context) ([]models.Todo, error)
-		GetByID(ctx context.Context, id int) (models.Todo, error)
+		Create(ctx context.Context, todo models.Todo) (models.Todo, error)
+		Modify(ctx context.Context, owner string, id int, fn func(models.Todo) (models.Todo, error)) (models.Todo, error)
+		SoftDelete(ctx context.Context, owner string, id, version int, at time.Time, children models.ChildrenMode) (models.Removal, error)
+		Restore(ctx context.Context, owner string, id, version int, at time.Time) (models.Todo, error)
+		Delete(ctx context.Context, owner string, id, version int, at time.Time, children models.ChildrenMode) (models.Removal, error)
+		ListTrash(ctx context.Context, owner string) ([]models.Todo, error)
+		PurgeTrash(ctx context.Context, before time.Time) (int, error)
+		Reminders(ctx context.Context, after time.Time) ([]models.Todo, error)
+		Tags(ctx context.Context, owner string) ([]models.TagCount, error)
+		History(ctx context.Context, owner string, id int) ([]models.Todo, error)
+		Children(ctx context.Context, owner string, id int) ([]models.Todo, error)
+		Descendants(ctx context.Context, owner string, ids []int) ([]models.Todo, error)
+		CompleteParent(ctx context.Context, owner string, id int, fn func(models.Todo) models.Todo) (models.Todo, bool, error)
+		Apply(ctx context.Context, owner string, muts []models.Mutation, atomic bool) ([]models.BatchResult, error)
+		GetAll(ctx context.Context, owner string) ([]models.Todo, error)
+		GetByID(ctx context.Context, owner string, id int) (models.Todo, error)
+		List(ctx context.Context, owner string, query models.TodoQuery) (models.TodoPage, error)
+	}
+	// Publisher получает успешные изменения задач: событие created, updated или deleted
+	// и состояние задачи после изменения (для deleted — последнее перед удалением).
+	Publisher interface {
+		Publish(typ string, todo models.Todo)
+	}
+	// CompletionPublisher Publisher, которому нужно знать о переводе задачи в завершенные.
+	// PublishCompleted вызывается после Publish того же изменения, если до него задача
+	// не была завершена, а после — завершена.
+	CompletionPublisher interface {
+		PublishCompleted(todo models.Todo)
 	}
+	// Clock источник текущего времени для временных меток задач.
+	Clock interface {
+		Now() time.Time
+	}
+
+	// Option настраивает TodoService.
+	Option func(*TodoService)
+
 	TodoService struct {
 		storage Storage
+		clock   Clock
+		// events брокер, на события которого подписываются клиенты. Может быть nil.
+		events *events.Broker
+		// publishers получатели успешных изменений задач, включая events.
+		publishers []Publisher
+		// autoComplete завершать задачу, когда завершены все ее подзадачи.
+		autoComplete bool
+		// writeMu упорядочивает изменения с их публикацией: получатели видят события
+		// в том же порядке, в каком изменения сохранены в хранилище.
+		writeMu sync.Mutex
 	}
+
+	systemClock struct{}
 )
 
-func NewTodoService(storage Storage) *TodoService {
-	return &TodoService{storage: storage}
+func NewTodoService(storage Storage, opts ...Option) *TodoService {
+	s := &TodoService{
+		storage: storage,
+		clock:   systemClock{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
 }
 
-func (s *TodoService) Create(ctx context.Context, todo models.Todo) error {
-	if err := validateTodo(todo); err != nil {
-		return err
+// WithClock подменяет источник времени (например, фиксированными часами в тестах).
+func WithClock(clock Clock) Option {
+	return func(s *TodoService) {
+		s.clock = clock
+	}
+}
+
+// WithEvents публикует успешные изменения задач в broker и подписывает на него через Subscribe.
+func WithEvents(broker *events.Broker) Option {
+	return func(s *TodoService) {
+		s.events = broker
+		s.publishers = append(s.publishers, broker)
+	}
+}
+
+// WithAutoComplete завершает задачу, когда завершена последняя из ее подзадач.
+// Завершение поднимается по цепочке родителей.
+func WithAutoComplete() Option {
+	return func(s *TodoService) {
+		s.autoComplete = true
+	}
+}
+
+// WithPublisher передает успешные изменения задач в p (например, для отправки webhook).
+func WithPublisher(p Publisher) Option {
+	return func(s *TodoService) {
+		s.publishers = append(s.publishers, p)
 	}
+}
 
-	return s.storage.Create(ctx, todo)
+// Now возвращает текущее время в UTC.
+func (systemClock) Now() time.Time {
+	return time.Now().UTC()
 }
 
-func (s *TodoService) Update(ctx context.Context, todo models.Todo) error {
-	if err := validateTodo(todo); err != nil {
+// Create создает задачу от имени пользователя из ctx. Если ID не указан, его назначает хранилище.
+func (s *TodoService) Create(ctx context.Context, todo models.Todo) (models.Todo, error) {
+	if err := validateTodo(&todo); err != nil {
+		return models.Todo{}, err
+	}
+	todo.Owner = owner(ctx)
+	s.stamp(&todo, models.Todo{})
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	created, err := s.storage.Create(ctx, todo)
+	if err != nil {
+		return models.Todo{}, err
+	}
+	s.publish(events.TypeCreated, created)
+	s.publishCompleted(models.Todo{}, created)
+
+	if err := s.completeParents(ctx, created); err != nil {
+		return models.Todo{}, err
+	}
+
+	return created, nil
+}
+
+// Update полностью заменяет задачу. Ненулевой todo.Version — ожидаемая текущая версия.
+func (s *TodoService) Update(ctx context.Context, todo models.Todo) (models.Todo, error) {
+	if todo.ID <= 0 {
+		return models.Todo{}, models.ErrInvalidID
+	}
+
+	if err := validateTodo(&todo); err != nil {
+		return models.Todo{}, err
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	var previous models.Todo
+	updated, err := s.storage.Modify(ctx, owner(ctx), todo.ID, func(current models.Todo) (models.Todo, error) {
+		if todo.Version != 0 && todo.Version != current.Version {
+			return models.Todo{}, models.ErrVersionMismatch
+		}
+		s.stamp(&todo, current)
+		previous = current
+
+		return todo, nil
+	})
+	if err != nil {
+		return models.Todo{}, err
+	}
+	s.publish(events.TypeUpdated, updated)
+	s.publishCompleted(previous, updated)
+
+	if err := s.completeParents(ctx, updated); err != nil {
+		return models.Todo{}, err
+	}
+
+	return updated, nil
+}
+
+// Patch частично обновляет задачу. Патч применяется к текущему состоянию атомарно,
+// внутри блокировки хранилища, а результат проходит ту же валидацию, что и при Update.
+// Ненулевой version — ожидаемая текущая версия задачи.
+func (s *TodoService) Patch(ctx context.Context, id, version int, patchType models.PatchType, patch []byte) (models.Todo, error) {
+	if id <= 0 {
+		return models.Todo{}, models.ErrInvalidID
+	}
+
+	var apply func(doc, patch []byte) ([]byte, error)
+	switch patchType {
+	case models.PatchMerge:
+		apply = jsonpatch.MergePatch
+	case models.PatchJSON:
+		apply = jsonpatch.Apply
+	default:
+		return models.Todo{}, fmt.Errorf("%w: неподдерживаемый формат %q", models.ErrInvalidPatch, patchType)
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	var previous models.Todo
+	updated, err := s.storage.Modify(ctx, owner(ctx), id, func(current models.Todo) (models.Todo, error) {
+		if version != 0 && version != current.Version {
+			return models.Todo{}, models.ErrVersionMismatch
+		}
+
+		doc, err := json.Marshal(current)
+		if err != nil {
+			return models.Todo{}, err
+		}
+
+		patched, err := apply(doc, patch)
+		if err != nil {
+			return models.Todo{}, patchError(err)
+		}
+
+		todo, err := decodePatched(patched)
+		if err != nil {
+			return models.Todo{}, err
+		}
+		if todo.ID != current.ID {
+			return models.Todo{}, fmt.Errorf("%w: id нельзя изменить", models.ErrInvalidPatch)
+		}
+		if todo.Version != current.Version {
+			return models.Todo{}, fmt.Errorf("%w: version нельзя изменить", models.ErrInvalidPatch)
+		}
+		if todo.Owner != current.Owner {
+			return models.Todo{}, fmt.Errorf("%w: owner нельзя изменить", models.ErrInvalidPatch)
+		}
+
+		if err := validateTodo(&todo); err != nil {
+			return models.Todo{}, err
+		}
+		s.stamp(&todo, current)
+		previous = current
+
+		return todo, nil
+	})
+	if err != nil {
+		return models.Todo{}, err
+	}
+	s.publish(events.TypeUpdated, updated)
+	s.publishCompleted(previous, updated)
+
+	if err := s.completeParents(ctx, updated); err != nil {
+		return models.Todo{}, err
+	}
+
+	return updated, nil
+}
+
+// Delete перемещает задачу в корзину, откуда ее можно вернуть через Restore.
+// children определяет судьбу подзадач, пустой — models.ChildrenReject.
+// Ненулевой version — ожидаемая текущая версия.
+func (s *TodoService) Delete(ctx context.Context, id, version int, children models.ChildrenMode) error {
+	if id <= 0 {
+		return models.ErrInvalidID
+	}
+	children, err := normalizeChildrenMode(children)
+	if err != nil {
 		return err
 	}
 
-	return s.storage.Update(ctx, todo)
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	removal, err := s.storage.SoftDelete(ctx, owner(ctx), id, version, s.clock.Now(), children)
+	if err != nil {
+		return err
+	}
+	s.publishRemoval(removal, false)
+
+	return nil
 }
 
-func (s *TodoService) Delete(ctx context.Context, id int) error {
+// HardDelete окончательно удаляет задачу, активную или из корзины.
+// children определяет судьбу подзадач, пустой — models.ChildrenReject.
+// Ненулевой version — ожидаемая текущая версия.
+func (s *TodoService) HardDelete(ctx context.Context, id, version int, children models.ChildrenMode) error {
 	if id <= 0 {
 		return models.ErrInvalidID
 	}
+	children, err := normalizeChildrenMode(children)
+	if err != nil {
+		return err
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	removal, err := s.storage.Delete(ctx, owner(ctx), id, version, s.clock.Now(), children)
+	if err != nil {
+		return err
+	}
+	s.publishRemoval(removal, true)
+
+	return nil
+}
+
+// publishRemoval сообщает получателям об удаленных и отвязанных задачах.
+// hard — задачи удалены окончательно.
+func (s *TodoService) publishRemoval(removal models.Removal, hard bool) {
+	for _, deleted := range removal.Deleted {
+		// Об удалении задачи из корзины подписчики уже узнали при ее перемещении туда.
+		if !hard || deleted.DeletedAt == nil {
+			s.publish(events.TypeDeleted, deleted)
+		}
+	}
+	for _, detached := range removal.Detached {
+		s.publish(events.TypeUpdated, detached)
+	}
+}
+
+// Restore возвращает задачу из корзины. Подписчики получают ее как updated.
+// Ненулевой version — ожидаемая текущая версия.
+func (s *TodoService) Restore(ctx context.Context, id, version int) (models.Todo, error) {
+	if id <= 0 {
+		return models.Todo{}, models.ErrInvalidID
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
 
-	return s.storage.Delete(ctx, id)
+	restored, err := s.storage.Restore(ctx, owner(ctx), id, version, s.clock.Now())
+	if err != nil {
+		return models.Todo{}, err
+	}
+	s.publish(events.TypeUpdated, restored)
+
+	return restored, nil
+}
+
+// ListTrash возвращает задачи пользователя в корзине, начиная с удаленных последними.
+func (s *TodoService) ListTrash(ctx context.Context) ([]models.Todo, error) {
+	return s.storage.ListTrash(ctx, owner(ctx))
+}
+
+// History возвращает историю задачи: все ее ревизии, начиная с первой,
+// с изменениями полей относительно предыдущей ревизии.
+func (s *TodoService) History(ctx context.Context, id int) ([]models.Revision, error) {
+	if id <= 0 {
+		return nil, models.ErrInvalidID
+	}
+
+	todos, err := s.storage.History(ctx, owner(ctx), id)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions := make([]models.Revision, len(todos))
+	previous := models.Todo{}
+	for i, todo := range todos {
+		revisions[i] = models.Revision{Rev: todo.Version, Todo: todo, Changes: models.Diff(previous, todo)}
+		previous = todo
+	}
+
+	return revisions, nil
+}
+
+// Revision возвращает ревизию rev задачи с изменениями полей относительно ревизии from.
+// Нулевой from — предыдущая ревизия (для первой — пустая задача).
+func (s *TodoService) Revision(ctx context.Context, id, rev, from int) (models.Revision, error) {
+	if id <= 0 {
+		return models.Revision{}, models.ErrInvalidID
+	}
+
+	todos, err := s.storage.History(ctx, owner(ctx), id)
+	if err != nil {
+		return models.Revision{}, err
+	}
+
+	target, ok := findRevision(todos, rev)
+	if !ok {
+		return models.Revision{}, fmt.Errorf("%w: %d", models.ErrRevisionNotFound, rev)
+	}
+
+	base := models.Todo{}
+	switch {
+	case from != 0:
+		if base, ok = findRevision(todos, from); !ok {
+			return models.Revision{}, fmt.Errorf("%w: %d", models.ErrRevisionNotFound, from)
+		}
+	case rev > 1:
+		base, _ = findRevision(todos, rev-1)
+	}
+
+	return models.Revision{Rev: rev, Todo: target, Changes: models.Diff(base, target)}, nil
+}
+
+// Revert возвращает задаче содержимое ревизии rev. Это обычное изменение задачи
+// с той же валидацией, что у Update: оно получает новую версию и попадает в историю.
+// Ненулевой version — ожидаемая текущая версия. Задачу из корзины сначала нужно восстановить.
+func (s *TodoService) Revert(ctx context.Context, id, rev, version int) (models.Todo, error) {
+	if id <= 0 {
+		return models.Todo{}, models.ErrInvalidID
+	}
+
+	todos, err := s.storage.History(ctx, owner(ctx), id)
+	if err != nil {
+		return models.Todo{}, err
+	}
+
+	target, ok := findRevision(todos, rev)
+	if !ok {
+		return models.Todo{}, fmt.Errorf("%w: %d", models.ErrRevisionNotFound, rev)
+	}
+	target.Version = version
+
+	return s.Update(ctx, target)
+}
+
+// Batch выполняет набор операций под одной блокировкой хранилища.
+// В атомарном режиме ошибка любой операции (включая валидацию) отменяет весь пакет;
+// иначе каждая операция выполняется независимо. Результаты возвращаются в порядке операций.
+func (s *TodoService) Batch(ctx context.Context, ops []models.BatchOperation, atomic bool) ([]models.BatchResult, error) {
+	if len(ops) == 0 {
+		return nil, fmt.Errorf("%w: пустой пакет", models.ErrInvalidBatch)
+	}
+	if len(ops) > MaxBatchSize {
+		return nil, fmt.Errorf("%w: не более %d операций в пакете", models.ErrInvalidBatch, MaxBatchSize)
+	}
+
+	results := make([]models.BatchResult, len(ops))
+	muts := make([]models.Mutation, 0, len(ops))
+	// indexes[j] — позиция в ops для muts[j].
+	indexes := make([]int, 0, len(ops))
+	// previous[i] — состояние задачи до операции ops[i]; пустое для создания.
+	previous := make([]models.Todo, len(ops))
+	failed := false
+
+	for i, op := range ops {
+		mut, err := s.mutation(op, &previous[i])
+		if err != nil {
+			results[i].Err = err
+			failed = true
+			continue
+		}
+		muts = append(muts, mut)
+		indexes = append(indexes, i)
+	}
+
+	if atomic && failed {
+		for _, i := range indexes {
+			results[i].Err = models.ErrBatchAborted
+		}
+		return results, nil
+	}
+
+	if len(muts) == 0 {
+		return results, nil
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	applied, err := s.storage.Apply(ctx, owner(ctx), muts, atomic)
+	if err != nil {
+		return nil, err
+	}
+	for j, result := range applied {
+		results[indexes[j]] = result
+		if result.Err == nil {
+			s.publish(batchEventTypes[muts[j].Kind], result.Todo)
+			if muts[j].Kind != models.BatchDelete {
+				s.publishCompleted(previous[indexes[j]], result.Todo)
+			}
+		}
+	}
+	// Автозавершение родителей проверяется только для записанных задач: удаленная
+	// подзадача не делает родителя выполненным.
+	for j, result := range applied {
+		if result.Err == nil && muts[j].Kind != models.BatchDelete {
+			if err := s.completeParents(ctx, result.Todo); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return results, nil
 }
 
 func (s *TodoService) GetAll(ctx context.Context) ([]models.Todo, error) {
-	return s.storage.GetAll(ctx)
+	return s.storage.GetAll(ctx, owner(ctx))
+}
+
+// List возвращает страницу задач. Проверяет и дополняет параметры выборки значениями по умолчанию.
+func (s *TodoService) List(ctx context.Context, query models.TodoQuery) (models.TodoPage, error) {
+	if err := normalizeQuery(&query); err != nil {
+		return models.TodoPage{}, err
+	}
+	query.Now = s.clock.Now()
+
+	return s.storage.List(ctx, owner(ctx), query)
+}
+
+// Tags возвращает теги задач пользователя из ctx с количеством задач у каждого.
+func (s *TodoService) Tags(ctx context.Context) ([]models.TagCount, error) {
+	return s.storage.Tags(ctx, owner(ctx))
 }
 
 func (s *TodoService) GetByID(ctx context.Context, id int) (models.Todo, error) {
@@ -57,16 +526,150 @@ func (s *TodoService) GetByID(ctx context.Context, id int) (models.Todo, error)
 		return models.Todo{}, models.ErrInvalidID
 	}
 
-	return s.storage.GetByID(ctx, id)
+	return s.storage.GetByID(ctx, owner(ctx), id)
+}
+
+// Subscribe подписывает на изменения задач пользователя из ctx. Ненулевой lastEventID —
+// ID последнего полученного события, с которого возобновляется поток.
+func (s *TodoService) Subscribe(ctx context.Context, lastEventID uint64) (*events.Subscription, error) {
+	if s.events == nil {
+		return nil, events.ErrClosed
+	}
+
+	return s.events.Subscribe(owner(ctx), lastEventID)
 }
 
 // ******************
 // Хелпующие функции.
 // ******************
 
-// validateTodo проверяет корректность данных.
-func validateTodo(todo models.Todo) error {
-	if todo.ID <= 0 {
+// batchEventTypes типы событий для операций пакета.
+var batchEventTypes = map[string]string{
+	models.BatchCreate: events.TypeCreated,
+	models.BatchUpdate: events.TypeUpdated,
+	models.BatchDelete: events.TypeDeleted,
+}
+
+// publish сообщает получателям об успешном изменении задачи.
+func (s *TodoService) publish(typ string, todo models.Todo) {
+	for _, p := range s.publishers {
+		p.Publish(typ, todo)
+	}
+}
+
+// publishCompleted сообщает получателям CompletionPublisher о переводе задачи в завершенные,
+// если изменение previous -> todo его содержит.
+func (s *TodoService) publishCompleted(previous, todo models.Todo) {
+	if previous.Completed || !todo.Completed {
+		return
+	}
+
+	for _, p := range s.publishers {
+		if cp, ok := p.(CompletionPublisher); ok {
+			cp.PublishCompleted(todo)
+		}
+	}
+}
+
+// findRevision ищет ревизию rev среди ревизий, упорядоченных по версии.
+func findRevision(todos []models.Todo, rev int) (models.Todo, bool) {
+	i, found := slices.BinarySearchFunc(todos, rev, func(todo models.Todo, rev int) int {
+		return cmp.Compare(todo.Version, rev)
+	})
+	if !found {
+		return models.Todo{}, false
+	}
+
+	return todos[i], true
+}
+
+// owner возвращает владельца задач для запроса: аутентифицированного пользователя из ctx.
+// Без аутентификации все запросы работают с задачами владельца по умолчанию "".
+func owner(ctx context.Context) string {
+	principal, _ := auth.FromContext(ctx)
+	return principal.Subject
+}
+
+// mutation проверяет операцию пакета и готовит изменение для хранилища
+// с теми же правилами, что у одиночных Create, Update и Delete. Изменение update
+// сохраняет в previous состояние задачи, к которому оно применено.
+func (s *TodoService) mutation(op models.BatchOperation, previous *models.Todo) (models.Mutation, error) {
+	switch op.Op {
+	case models.BatchCreate:
+		if op.Todo == nil {
+			return models.Mutation{}, fmt.Errorf("%w: для create требуется todo", models.ErrInvalidBatch)
+		}
+		todo := *op.Todo
+		if err := validateTodo(&todo); err != nil {
+			return models.Mutation{}, err
+		}
+		s.stamp(&todo, models.Todo{})
+
+		return models.Mutation{Kind: models.BatchCreate, Todo: todo}, nil
+	case models.BatchUpdate:
+		if op.Todo == nil {
+			return models.Mutation{}, fmt.Errorf("%w: для update требуется todo", models.ErrInvalidBatch)
+		}
+		if op.ID <= 0 {
+			return models.Mutation{}, models.ErrInvalidID
+		}
+		todo := *op.Todo
+		todo.ID = op.ID
+		if err := validateTodo(&todo); err != nil {
+			return models.Mutation{}, err
+		}
+
+		return models.Mutation{
+			Kind: models.BatchUpdate,
+			ID:   op.ID,
+			Modify: func(current models.Todo) (models.Todo, error) {
+				if op.Version != 0 && op.Version != current.Version {
+					return models.Todo{}, models.ErrVersionMismatch
+				}
+				updated := todo
+				s.stamp(&updated, current)
+				*previous = current
+
+				return updated, nil
+			},
+		}, nil
+	case models.BatchDelete:
+		if op.ID <= 0 {
+			return models.Mutation{}, models.ErrInvalidID
+		}
+
+		return models.Mutation{Kind: models.BatchDelete, ID: op.ID, Version: op.Version, DeletedAt: s.clock.Now()}, nil
+	default:
+		return models.Mutation{}, fmt.Errorf("%w: неизвестная операция %q", models.ErrInvalidBatch, op.Op)
+	}
+}
+
+// stamp проставляет серверные временные метки. previous — состояние задачи до изменения
+// (пустое при создании): из него сохраняются created_at и, если задача уже была
+// завершена, completed_at. Значения, присланные клиентом, игнорируются.
+func (s *TodoService) stamp(todo *models.Todo, previous models.Todo) {
+	now := s.clock.Now()
+
+	todo.CreatedAt = previous.CreatedAt
+	if todo.CreatedAt.IsZero() {
+		todo.CreatedAt = now
+	}
+	todo.UpdatedAt = now
+
+	switch {
+	case !todo.Completed:
+		todo.CompletedAt = nil
+	case previous.Completed && previous.CompletedAt != nil:
+		todo.CompletedAt = previous.CompletedAt
+	default:
+		todo.CompletedAt = &now
+	}
+}
+
+// validateTodo проверяет корректность данных и приводит теги к каноническому виду.
+// Нулевой ID допустим: он означает, что идентификатор назначит хранилище.
+func validateTodo(todo *models.Todo) error {
+	if todo.ID < 0 {
 		return models.ErrInvalidID
 	}
 
@@ -74,5 +677,109 @@ func validateTodo(todo models.Todo) error {
 		return models.ErrEmptyTitle
 	}
 
+	if todo.ParentID < 0 || (todo.ParentID != 0 && todo.ParentID == todo.ID) {
+		return fmt.Errorf("%w: parent_id должен ссылаться на другую задачу", models.ErrInvalidParent)
+	}
+
+	if todo.Priority != "" && !todo.Priority.Valid() {
+		return models.ErrInvalidPriority
+	}
+
+	if todo.RemindAt != nil && todo.DueAt != nil && todo.RemindAt.After(*todo.DueAt) {
+		return models.ErrInvalidReminder
+	}
+
+	tags, err := normalizeTags(todo.Tags)
+	if err != nil {
+		return err
+	}
+	todo.Tags = tags
+
+	return nil
+}
+
+// normalizeTags приводит теги к нижнему регистру, убирает пробелы по краям и повторы
+// и сортирует их. Тег — от 1 до MaxTagLength букв, цифр и символов "-", "_", ".", ":", "/".
+// Пустой список возвращается как nil.
+func normalizeTags(tags []string) ([]string, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+
+	result := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || utf8.RuneCountInString(tag) > MaxTagLength {
+			return nil, fmt.Errorf("%w: длина тега должна быть от 1 до %d символов", models.ErrInvalidTags, MaxTagLength)
+		}
+		if strings.IndexFunc(tag, invalidTagRune) >= 0 {
+			return nil, fmt.Errorf("%w: недопустимый символ в теге %q", models.ErrInvalidTags, tag)
+		}
+		result = append(result, tag)
+	}
+
+	slices.Sort(result)
+	result = slices.Compact(result)
+	if len(result) > MaxTags {
+		return nil, fmt.Errorf("%w: не больше %d тегов", models.ErrInvalidTags, MaxTags)
+	}
+
+	return result, nil
+}
+
+// invalidTagRune сообщает, что символ недопустим в теге.
+func invalidTagRune(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("-_.:/", r)
+}
+
+// normalizeQuery проверяет параметры выборки и подставляет значения по умолчанию.
+func normalizeQuery(query *models.TodoQuery) error {
+	switch {
+	case query.Limit < 0:
+		return fmt.Errorf("%w: limit не может быть отрицательным", models.ErrInvalidQuery)
+	case query.Limit > MaxListLimit:
+		return fmt.Errorf("%w: limit не может превышать %d", models.ErrInvalidQuery, MaxListLimit)
+	case query.Offset < 0:
+		return fmt.Errorf("%w: offset не может быть отрицательным", models.ErrInvalidQuery)
+	case query.Offset > 0 && query.Cursor != "":
+		return fmt.Errorf("%w: offset и cursor взаимоисключающие", models.ErrInvalidQuery)
+	case query.Priority != "" && !query.Priority.Valid():
+		return fmt.Errorf("%w: %v", models.ErrInvalidQuery, models.ErrInvalidPriority)
+	}
+
+	var err error
+	if query.Tags, err = normalizeTags(query.Tags); err != nil {
+		return fmt.Errorf("%w: %v", models.ErrInvalidQuery, err)
+	}
+	if query.AnyTags, err = normalizeTags(query.AnyTags); err != nil {
+		return fmt.Errorf("%w: %v", models.ErrInvalidQuery, err)
+	}
+
+	if query.Limit == 0 {
+		query.Limit = DefaultListLimit
+	}
+	query.Search = strings.TrimSpace(query.Search)
+
 	return nil
 }
+
+// decodePatched строго разбирает задачу после применения патча: неизвестные поля запрещены.
+func decodePatched(data []byte) (models.Todo, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	var todo models.Todo
+	if err := dec.Decode(&todo); err != nil {
+		return models.Todo{}, fmt.Errorf("%w: %v", models.ErrInvalidPatch, err)
+	}
+
+	return todo, nil
+}
+
+// patchError переводит ошибки пакета jsonpatch в ошибки модели.
+func patchError(err error) error {
+	if errors.Is(err, jsonpatch.ErrTestFailed) {
+		return fmt.Errorf("%w: %v", models.ErrPatchTestFailed, err)
+	}
+	return fmt.Errorf("%w: %v", models.ErrInvalidPatch, err)
+}
@@ -3,9 +3,18 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/RoGogDBD/ecom/internal/auth"
+	"github.com/RoGogDBD/ecom/internal/events"
 	"github.com/RoGogDBD/ecom/internal/models"
+	"github.com/RoGogDBD/ecom/internal/repository"
 )
 
 type stubStorage struct {
@@ -13,31 +22,148 @@ type stubStorage struct {
 	updateErr   error
 	createCalls int
 	updateCalls int
+	lastQuery   models.TodoQuery
+	current     models.Todo
+	applied     []models.Mutation
+	// trashed задача, которую возвращает Delete, находится в корзине.
+	trashed     bool
+	deletedAt   time.Time
+	purgeBefore time.Time
+	// history ревизии, которые возвращает History.
+	history []models.Todo
+	// detached подзадачи, которые удаление отвязывает от родителя.
+	detached []models.Todo
+	// descendants подзадачи, которые возвращают Children и Descendants.
+	descendants []models.Todo
+	// parents задачи, которые CompleteParent считает готовыми к завершению.
+	parents map[int]models.Todo
+	// batchDeleted задача, которую Apply возвращает для операций удаления.
+	batchDeleted models.Todo
 }
 
-func (s *stubStorage) Create(_ context.Context, _ models.Todo) error {
+func (s *stubStorage) Create(_ context.Context, todo models.Todo) (models.Todo, error) {
 	s.createCalls++
-	return s.createErr
+	if s.createErr != nil {
+		return models.Todo{}, s.createErr
+	}
+	return todo, nil
 }
 
-func (s *stubStorage) Update(_ context.Context, _ models.Todo) error {
+func (s *stubStorage) Modify(_ context.Context, _ string, _ int, fn func(models.Todo) (models.Todo, error)) (models.Todo, error) {
 	s.updateCalls++
-	return s.updateErr
+	if s.updateErr != nil {
+		return models.Todo{}, s.updateErr
+	}
+	return fn(s.current)
+}
+
+// fixedClock всегда возвращает одно и то же время.
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+func (s *stubStorage) SoftDelete(_ context.Context, _ string, id, _ int, at time.Time, _ models.ChildrenMode) (models.Removal, error) {
+	s.deletedAt = at
+	return models.Removal{Deleted: []models.Todo{{ID: id, DeletedAt: &at}}, Detached: s.detached}, nil
+}
+
+func (s *stubStorage) Restore(_ context.Context, _ string, id, _ int, at time.Time) (models.Todo, error) {
+	return models.Todo{ID: id, UpdatedAt: at}, nil
+}
+
+func (s *stubStorage) Delete(_ context.Context, _ string, id, _ int, _ time.Time, _ models.ChildrenMode) (models.Removal, error) {
+	todo := models.Todo{ID: id}
+	if s.trashed {
+		todo.DeletedAt = &s.deletedAt
+	}
+	return models.Removal{Deleted: []models.Todo{todo}, Detached: s.detached}, nil
+}
+
+func (s *stubStorage) ListTrash(_ context.Context, _ string) ([]models.Todo, error) {
+	return nil, nil
 }
 
-func (s *stubStorage) Delete(_ context.Context, _ int) error {
-	return nil
+func (s *stubStorage) PurgeTrash(_ context.Context, before time.Time) (int, error) {
+	s.purgeBefore = before
+	return 1, nil
 }
 
-func (s *stubStorage) GetAll(_ context.Context) ([]models.Todo, error) {
+func (s *stubStorage) Reminders(_ context.Context, _ time.Time) ([]models.Todo, error) {
 	return nil, nil
 }
 
-func (s *stubStorage) GetByID(_ context.Context, _ int) (models.Todo, error) {
+func (s *stubStorage) Tags(_ context.Context, _ string) ([]models.TagCount, error) {
+	return nil, nil
+}
+
+func (s *stubStorage) History(_ context.Context, _ string, _ int) ([]models.Todo, error) {
+	if len(s.history) == 0 {
+		return nil, models.ErrNotFound
+	}
+	return s.history, nil
+}
+
+func (s *stubStorage) Children(_ context.Context, _ string, id int) ([]models.Todo, error) {
+	var children []models.Todo
+	for _, todo := range s.descendants {
+		if todo.ParentID == id {
+			children = append(children, todo)
+		}
+	}
+	return children, nil
+}
+
+func (s *stubStorage) Descendants(_ context.Context, _ string, _ []int) ([]models.Todo, error) {
+	return s.descendants, nil
+}
+
+func (s *stubStorage) CompleteParent(_ context.Context, _ string, id int, fn func(models.Todo) models.Todo) (models.Todo, bool, error) {
+	parent, ok := s.parents[id]
+	if !ok || parent.Completed {
+		return models.Todo{}, false, nil
+	}
+	parent = fn(parent)
+	s.parents[id] = parent
+	return parent, true, nil
+}
+
+func (s *stubStorage) GetAll(_ context.Context, _ string) ([]models.Todo, error) {
+	return nil, nil
+}
+
+func (s *stubStorage) Apply(_ context.Context, _ string, muts []models.Mutation, _ bool) ([]models.BatchResult, error) {
+	s.applied = muts
+	results := make([]models.BatchResult, len(muts))
+	for i, mut := range muts {
+		switch mut.Kind {
+		case models.BatchUpdate:
+			results[i].Todo, results[i].Err = mut.Modify(s.current)
+		case models.BatchDelete:
+			results[i].Todo = s.batchDeleted
+		default:
+			results[i].Todo = mut.Todo
+		}
+	}
+	return results, nil
+}
+
+func (s *stubStorage) List(_ context.Context, _ string, query models.TodoQuery) (models.TodoPage, error) {
+	s.lastQuery = query
+	return models.TodoPage{}, nil
+}
+
+func (s *stubStorage) GetByID(_ context.Context, _ string, _ int) (models.Todo, error) {
 	return models.Todo{}, nil
 }
 
 func TestTodoServiceCreate(t *testing.T) {
+	var (
+		dueAt     = time.Date(2026, 1, 10, 18, 0, 0, 0, time.UTC)
+		remindAt  = dueAt.Add(-time.Hour)
+		lateAlarm = dueAt.Add(time.Minute)
+	)
 	cases := []struct {
 		name            string
 		todo            models.Todo
@@ -55,11 +181,46 @@ func TestTodoServiceCreate(t *testing.T) {
 			todo:    models.Todo{ID: 2, Title: "  ", Description: "нет заголовка"},
 			wantErr: models.ErrEmptyTitle,
 		},
+		{
+			name:            "без id: назначается хранилищем",
+			todo:            models.Todo{Title: "заголовок"},
+			wantCreateCalls: 1,
+		},
 		{
 			name:    "ошибка валидации: неверный id",
-			todo:    models.Todo{ID: 0, Title: "заголовок"},
+			todo:    models.Todo{ID: -1, Title: "заголовок"},
 			wantErr: models.ErrInvalidID,
 		},
+		{
+			name:            "срок, приоритет и напоминание",
+			todo:            models.Todo{Title: "оплатить счет", Priority: models.PriorityUrgent, DueAt: &dueAt, RemindAt: &remindAt},
+			wantCreateCalls: 1,
+		},
+		{
+			name:    "ошибка валидации: неизвестный приоритет",
+			todo:    models.Todo{Title: "заголовок", Priority: "critical"},
+			wantErr: models.ErrInvalidPriority,
+		},
+		{
+			name:    "ошибка валидации: напоминание позже срока",
+			todo:    models.Todo{Title: "заголовок", DueAt: &dueAt, RemindAt: &lateAlarm},
+			wantErr: models.ErrInvalidReminder,
+		},
+		{
+			name:            "подзадача",
+			todo:            models.Todo{Title: "заголовок", ParentID: 1},
+			wantCreateCalls: 1,
+		},
+		{
+			name:    "ошибка валидации: отрицательный parent_id",
+			todo:    models.Todo{Title: "заголовок", ParentID: -1},
+			wantErr: models.ErrInvalidParent,
+		},
+		{
+			name:    "ошибка валидации: подзадача самой себя",
+			todo:    models.Todo{ID: 4, Title: "заголовок", ParentID: 4},
+			wantErr: models.ErrInvalidParent,
+		},
 		{
 			name:            "дубликат id",
 			todo:            models.Todo{ID: 3, Title: "дубликат"},
@@ -77,7 +238,7 @@ func TestTodoServiceCreate(t *testing.T) {
 			storage := &stubStorage{createErr: tc.createErr}
 			service := NewTodoService(storage)
 
-			err := service.Create(context.Background(), tc.todo)
+			_, err := service.Create(context.Background(), tc.todo)
 			if !errors.Is(err, tc.wantErr) {
 				t.Fatalf("ожидалась ошибка %v, получено %v", tc.wantErr, err)
 			}
@@ -88,6 +249,83 @@ func TestTodoServiceCreate(t *testing.T) {
 	}
 }
 
+func TestNormalizeTags(t *testing.T) {
+	tooMany := make([]string, MaxTags+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf("tag%d", i)
+	}
+
+	cases := []struct {
+		name    string
+		tags    []string
+		want    []string
+		wantErr error
+	}{
+		{name: "без тегов", tags: []string{}, want: nil},
+		{
+			name: "регистр, пробелы, повторы и порядок",
+			tags: []string{" Work ", "home", "work", "проект:альфа"},
+			want: []string{"home", "work", "проект:альфа"},
+		},
+		{name: "пустой тег", tags: []string{"work", "  "}, wantErr: models.ErrInvalidTags},
+		{name: "пробел внутри тега", tags: []string{"два слова"}, wantErr: models.ErrInvalidTags},
+		{name: "слишком длинный тег", tags: []string{strings.Repeat("я", MaxTagLength+1)}, wantErr: models.ErrInvalidTags},
+		{name: "повторы не учитываются в лимите", tags: append(slices.Clone(tooMany[:MaxTags]), "TAG0"), want: sortedTags(tooMany[:MaxTags])},
+		{name: "слишком много тегов", tags: tooMany, wantErr: models.ErrInvalidTags},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := normalizeTags(tc.tags)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("ожидалась ошибка %v, получено %v", tc.wantErr, err)
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Fatalf("ожидались теги %q, получено %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func sortedTags(tags []string) []string {
+	sorted := slices.Clone(tags)
+	slices.Sort(sorted)
+	return sorted
+}
+
+func TestTodoServiceCreateOwner(t *testing.T) {
+	service := NewTodoService(&stubStorage{})
+
+	cases := []struct {
+		name string
+		ctx  context.Context
+		want string
+	}{
+		{name: "без аутентификации", ctx: context.Background(), want: ""},
+		{
+			name: "владелец из контекста",
+			ctx:  auth.NewContext(context.Background(), auth.Principal{Subject: "alice", Method: auth.MethodJWT}),
+			want: "alice",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Владелец, присланный клиентом, игнорируется.
+			got, err := service.Create(tc.ctx, models.Todo{Title: "задача", Owner: "mallory"})
+			if err != nil {
+				t.Fatalf("неожиданная ошибка: %v", err)
+			}
+			if got.Owner != tc.want {
+				t.Fatalf("ожидался владелец %q, получено %q", tc.want, got.Owner)
+			}
+		})
+	}
+}
+
 func TestTodoServiceUpdate(t *testing.T) {
 	cases := []struct {
 		name            string
@@ -111,6 +349,11 @@ func TestTodoServiceUpdate(t *testing.T) {
 			todo:    models.Todo{ID: -1, Title: "заголовок"},
 			wantErr: models.ErrInvalidID,
 		},
+		{
+			name:    "ошибка валидации: нулевой id",
+			todo:    models.Todo{ID: 0, Title: "заголовок"},
+			wantErr: models.ErrInvalidID,
+		},
 		{
 			name:            "не найдено",
 			todo:            models.Todo{ID: 3, Title: "нет записи"},
@@ -128,7 +371,7 @@ func TestTodoServiceUpdate(t *testing.T) {
 			storage := &stubStorage{updateErr: tc.updateErr}
 			service := NewTodoService(storage)
 
-			err := service.Update(context.Background(), tc.todo)
+			_, err := service.Update(context.Background(), tc.todo)
 			if !errors.Is(err, tc.wantErr) {
 				t.Fatalf("ожидалась ошибка %v, получено %v", tc.wantErr, err)
 			}
@@ -138,3 +381,959 @@ func TestTodoServiceUpdate(t *testing.T) {
 		})
 	}
 }
+
+func TestTodoServiceList(t *testing.T) {
+	cases := []struct {
+		name      string
+		query     models.TodoQuery
+		wantErr   error
+		wantLimit int
+	}{
+		{
+			name:      "лимит по умолчанию",
+			query:     models.TodoQuery{},
+			wantLimit: DefaultListLimit,
+		},
+		{
+			name:      "явный лимит",
+			query:     models.TodoQuery{Limit: 10, Offset: 20},
+			wantLimit: 10,
+		},
+		{
+			name:    "отрицательный лимит",
+			query:   models.TodoQuery{Limit: -1},
+			wantErr: models.ErrInvalidQuery,
+		},
+		{
+			name:    "слишком большой лимит",
+			query:   models.TodoQuery{Limit: MaxListLimit + 1},
+			wantErr: models.ErrInvalidQuery,
+		},
+		{
+			name:    "offset вместе с cursor",
+			query:   models.TodoQuery{Offset: 5, Cursor: "abc"},
+			wantErr: models.ErrInvalidQuery,
+		},
+		{
+			name:    "неизвестный приоритет",
+			query:   models.TodoQuery{Priority: "critical"},
+			wantErr: models.ErrInvalidQuery,
+		},
+		{
+			name:    "некорректный тег",
+			query:   models.TodoQuery{AnyTags: []string{"два слова"}},
+			wantErr: models.ErrInvalidQuery,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			storage := &stubStorage{}
+			service := NewTodoService(storage)
+
+			_, err := service.List(context.Background(), tc.query)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("ожидалась ошибка %v, получено %v", tc.wantErr, err)
+			}
+			if tc.wantErr == nil && storage.lastQuery.Limit != tc.wantLimit {
+				t.Fatalf("ожидался limit %d, получено %d", tc.wantLimit, storage.lastQuery.Limit)
+			}
+		})
+	}
+}
+
+func TestTodoServicePatch(t *testing.T) {
+	created := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	now := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+	current := models.Todo{ID: 1, Title: "купить молоко", Description: "у дома", Version: 3, CreatedAt: created, UpdatedAt: created}
+
+	cases := []struct {
+		name      string
+		id        int
+		version   int
+		patchType models.PatchType
+		patch     string
+		want      models.Todo
+		wantErr   error
+	}{
+		{
+			name:      "merge patch: отметить выполненной",
+			id:        1,
+			patchType: models.PatchMerge,
+			patch:     `{"completed":true}`,
+			want: models.Todo{
+				ID: 1, Title: "купить молоко", Description: "у дома", Completed: true, Version: 3,
+				CreatedAt: created, UpdatedAt: now, CompletedAt: &now,
+			},
+		},
+		{
+			name:      "совпадающая версия",
+			id:        1,
+			version:   3,
+			patchType: models.PatchMerge,
+			patch:     `{"description":"в магазине"}`,
+			want: models.Todo{
+				ID: 1, Title: "купить молоко", Description: "в магазине", Version: 3,
+				CreatedAt: created, UpdatedAt: now,
+			},
+		},
+		{
+			name:      "устаревшая версия",
+			id:        1,
+			version:   2,
+			patchType: models.PatchMerge,
+			patch:     `{"completed":true}`,
+			wantErr:   models.ErrVersionMismatch,
+		},
+		{
+			name:      "merge patch: смена version запрещена",
+			id:        1,
+			patchType: models.PatchMerge,
+			patch:     `{"version":10}`,
+			wantErr:   models.ErrInvalidPatch,
+		},
+		{
+			name:      "merge patch: удаление заголовка не проходит валидацию",
+			id:        1,
+			patchType: models.PatchMerge,
+			patch:     `{"title":null}`,
+			wantErr:   models.ErrEmptyTitle,
+		},
+		{
+			name:      "merge patch: неизвестное поле",
+			id:        1,
+			patchType: models.PatchMerge,
+			patch:     `{"color":"red"}`,
+			wantErr:   models.ErrInvalidPatch,
+		},
+		{
+			name:      "merge patch: смена id запрещена",
+			id:        1,
+			patchType: models.PatchMerge,
+			patch:     `{"id":2}`,
+			wantErr:   models.ErrInvalidPatch,
+		},
+		{
+			name:      "merge patch: смена owner запрещена",
+			id:        1,
+			patchType: models.PatchMerge,
+			patch:     `{"owner":"bob"}`,
+			wantErr:   models.ErrInvalidPatch,
+		},
+		{
+			name:      "json patch: замена описания",
+			id:        1,
+			patchType: models.PatchJSON,
+			patch:     `[{"op":"replace","path":"/description","value":"в магазине"}]`,
+			want: models.Todo{
+				ID: 1, Title: "купить молоко", Description: "в магазине", Version: 3,
+				CreatedAt: created, UpdatedAt: now,
+			},
+		},
+		{
+			name:      "json patch: неуспешный test",
+			id:        1,
+			patchType: models.PatchJSON,
+			patch:     `[{"op":"test","path":"/completed","value":true}]`,
+			wantErr:   models.ErrPatchTestFailed,
+		},
+		{
+			name:      "неверный id",
+			id:        0,
+			patchType: models.PatchMerge,
+			patch:     `{}`,
+			wantErr:   models.ErrInvalidID,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			service := NewTodoService(&stubStorage{current: current}, WithClock(fixedClock(now)))
+
+			got, err := service.Patch(context.Background(), tc.id, tc.version, tc.patchType, []byte(tc.patch))
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("ожидалась ошибка %v, получено %v", tc.wantErr, err)
+			}
+			if tc.wantErr == nil && !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("ожидалась задача %+v, получено %+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestTodoServiceTimestamps(t *testing.T) {
+	created := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	completed := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name          string
+		current       models.Todo
+		update        models.Todo
+		wantCompleted *time.Time
+	}{
+		{
+			name:          "завершение проставляет completed_at",
+			current:       models.Todo{ID: 1, Title: "задача", CreatedAt: created},
+			update:        models.Todo{ID: 1, Title: "задача", Completed: true},
+			wantCompleted: &now,
+		},
+		{
+			name:          "повторное сохранение завершенной задачи не сдвигает completed_at",
+			current:       models.Todo{ID: 1, Title: "задача", Completed: true, CreatedAt: created, CompletedAt: &completed},
+			update:        models.Todo{ID: 1, Title: "новый заголовок", Completed: true},
+			wantCompleted: &completed,
+		},
+		{
+			name:    "возврат в работу сбрасывает completed_at",
+			current: models.Todo{ID: 1, Title: "задача", Completed: true, CreatedAt: created, CompletedAt: &completed},
+			update:  models.Todo{ID: 1, Title: "задача"},
+		},
+		{
+			name:    "клиентские метки игнорируются",
+			current: models.Todo{ID: 1, Title: "задача", CreatedAt: created},
+			update:  models.Todo{ID: 1, Title: "задача", CreatedAt: now.Add(time.Hour), CompletedAt: &now},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			service := NewTodoService(&stubStorage{current: tc.current}, WithClock(fixedClock(now)))
+
+			got, err := service.Update(context.Background(), tc.update)
+			if err != nil {
+				t.Fatalf("неожиданная ошибка: %v", err)
+			}
+			if !got.CreatedAt.Equal(created) {
+				t.Fatalf("ожидался created_at %v, получено %v", created, got.CreatedAt)
+			}
+			if !got.UpdatedAt.Equal(now) {
+				t.Fatalf("ожидался updated_at %v, получено %v", now, got.UpdatedAt)
+			}
+			if !reflect.DeepEqual(got.CompletedAt, tc.wantCompleted) {
+				t.Fatalf("ожидался completed_at %v, получено %v", tc.wantCompleted, got.CompletedAt)
+			}
+		})
+	}
+
+	t.Run("создание", func(t *testing.T) {
+		t.Parallel()
+
+		service := NewTodoService(&stubStorage{}, WithClock(fixedClock(now)))
+
+		got, err := service.Create(context.Background(), models.Todo{Title: "новая", Completed: true})
+		if err != nil {
+			t.Fatalf("неожиданная ошибка: %v", err)
+		}
+		if !got.CreatedAt.Equal(now) || !got.UpdatedAt.Equal(now) || got.CompletedAt == nil || !got.CompletedAt.Equal(now) {
+			t.Fatalf("ожидались метки %v, получено %+v", now, got)
+		}
+	})
+}
+
+func TestTodoServiceBatch(t *testing.T) {
+	valid := &models.Todo{Title: "задача"}
+	empty := &models.Todo{Title: " "}
+
+	cases := []struct {
+		name        string
+		ops         []models.BatchOperation
+		atomic      bool
+		wantErr     error
+		wantResults []error
+		wantApplied int
+	}{
+		{
+			name:    "пустой пакет",
+			wantErr: models.ErrInvalidBatch,
+		},
+		{
+			name: "все операции валидны",
+			ops: []models.BatchOperation{
+				{Op: models.BatchCreate, Todo: valid},
+				{Op: models.BatchUpdate, ID: 1, Todo: valid},
+				{Op: models.BatchDelete, ID: 2},
+			},
+			atomic:      true,
+			wantResults: []error{nil, nil, nil},
+			wantApplied: 3,
+		},
+		{
+			name: "атомарный пакет с ошибкой валидации не доходит до хранилища",
+			ops: []models.BatchOperation{
+				{Op: models.BatchCreate, Todo: valid},
+				{Op: models.BatchCreate, Todo: empty},
+			},
+			atomic:      true,
+			wantResults: []error{models.ErrBatchAborted, models.ErrEmptyTitle},
+		},
+		{
+			name: "неатомарный пакет применяет валидные операции",
+			ops: []models.BatchOperation{
+				{Op: models.BatchDelete, ID: 0},
+				{Op: models.BatchCreate, Todo: valid},
+				{Op: "upsert"},
+			},
+			wantResults: []error{models.ErrInvalidID, nil, models.ErrInvalidBatch},
+			wantApplied: 1,
+		},
+		{
+			name:        "update без todo",
+			ops:         []models.BatchOperation{{Op: models.BatchUpdate, ID: 1}},
+			wantResults: []error{models.ErrInvalidBatch},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			storage := &stubStorage{}
+			service := NewTodoService(storage)
+
+			results, err := service.Batch(context.Background(), tc.ops, tc.atomic)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("ожидалась ошибка %v, получено %v", tc.wantErr, err)
+			}
+			if len(results) != len(tc.wantResults) {
+				t.Fatalf("ожидалось результатов %d, получено %d", len(tc.wantResults), len(results))
+			}
+			for i, want := range tc.wantResults {
+				if !errors.Is(results[i].Err, want) {
+					t.Fatalf("операция %d: ожидалась ошибка %v, получено %v", i, want, results[i].Err)
+				}
+			}
+			if len(storage.applied) != tc.wantApplied {
+				t.Fatalf("ожидалось изменений в хранилище %d, получено %d", tc.wantApplied, len(storage.applied))
+			}
+		})
+	}
+}
+
+func TestTodoServiceEvents(t *testing.T) {
+	broker := events.NewBroker(events.Options{Buffer: 8})
+	storage := &stubStorage{current: models.Todo{ID: 1, Title: "задача", Version: 1}}
+	service := NewTodoService(storage, WithEvents(broker))
+	ctx := context.Background()
+
+	sub, err := service.Subscribe(ctx, 0)
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	defer sub.Close()
+
+	if _, err := service.Create(ctx, models.Todo{Title: "задача"}); err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if _, err := service.Update(ctx, models.Todo{ID: 1, Title: "другая"}); err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	// Неуспешная операция не публикует событие.
+	storage.updateErr = models.ErrNotFound
+	if _, err := service.Update(ctx, models.Todo{ID: 1, Title: "третья"}); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("ожидалась ошибка %v, получено %v", models.ErrNotFound, err)
+	}
+	if err := service.Delete(ctx, 1, 0, ""); err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if _, err := service.Batch(ctx, []models.BatchOperation{
+		{Op: models.BatchCreate, Todo: &models.Todo{Title: "в пакете"}},
+		{Op: models.BatchDelete, ID: 0},
+	}, false); err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+
+	want := []string{events.TypeCreated, events.TypeUpdated, events.TypeDeleted, events.TypeCreated}
+	for i, typ := range want {
+		select {
+		case event := <-sub.Events():
+			if event.Type != typ {
+				t.Fatalf("событие %d: ожидался тип %q, получено %q", i, typ, event.Type)
+			}
+		default:
+			t.Fatalf("событие %d: ожидался тип %q, событий нет", i, typ)
+		}
+	}
+	select {
+	case event := <-sub.Events():
+		t.Fatalf("получено лишнее событие %+v", event)
+	default:
+	}
+}
+
+func TestTodoServiceSubscribeWithoutEvents(t *testing.T) {
+	service := NewTodoService(&stubStorage{})
+
+	if _, err := service.Subscribe(context.Background(), 0); !errors.Is(err, events.ErrClosed) {
+		t.Fatalf("ожидалась ошибка %v, получено %v", events.ErrClosed, err)
+	}
+}
+
+// recordingPublisher запоминает типы опубликованных изменений.
+type recordingPublisher struct {
+	types []string
+}
+
+func (p *recordingPublisher) Publish(typ string, _ models.Todo) {
+	p.types = append(p.types, typ)
+}
+
+func TestTodoServicePublisher(t *testing.T) {
+	publisher := &recordingPublisher{}
+	storage := &stubStorage{current: models.Todo{ID: 1, Title: "задача", Version: 1}}
+	service := NewTodoService(storage, WithPublisher(publisher))
+	ctx := context.Background()
+
+	if _, err := service.Create(ctx, models.Todo{Title: "задача"}); err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if err := service.Delete(ctx, 1, 0, ""); err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+
+	want := []string{events.TypeCreated, events.TypeDeleted}
+	if !slices.Equal(publisher.types, want) {
+		t.Fatalf("опубликованы %v, ожидались %v", publisher.types, want)
+	}
+}
+
+func TestTodoServiceTrash(t *testing.T) {
+	now := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	publisher := &recordingPublisher{}
+	storage := &stubStorage{}
+	service := NewTodoService(storage, WithClock(fixedClock(now)), WithPublisher(publisher))
+	ctx := context.Background()
+
+	if err := service.Delete(ctx, 1, 0, ""); err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if !storage.deletedAt.Equal(now) {
+		t.Fatalf("ожидалось время удаления %v, получено %v", now, storage.deletedAt)
+	}
+
+	restored, err := service.Restore(ctx, 1, 0)
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if !restored.UpdatedAt.Equal(now) {
+		t.Fatalf("ожидалось время изменения %v, получено %v", now, restored.UpdatedAt)
+	}
+
+	// Окончательное удаление задачи из корзины не публикуется повторно.
+	storage.trashed = true
+	if err := service.HardDelete(ctx, 1, 0, ""); err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	storage.trashed = false
+	if err := service.HardDelete(ctx, 2, 0, ""); err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+
+	want := []string{events.TypeDeleted, events.TypeUpdated, events.TypeDeleted}
+	if !slices.Equal(publisher.types, want) {
+		t.Fatalf("опубликованы %v, ожидались %v", publisher.types, want)
+	}
+
+	if _, err := service.Restore(ctx, 0, 0); !errors.Is(err, models.ErrInvalidID) {
+		t.Fatalf("ожидалась ошибка %v, получено %v", models.ErrInvalidID, err)
+	}
+}
+
+func TestTodoServiceDeleteChildren(t *testing.T) {
+	cases := []struct {
+		name       string
+		children   models.ChildrenMode
+		hard       bool
+		wantErr    error
+		wantEvents []string
+	}{
+		{name: "по умолчанию", wantEvents: []string{events.TypeDeleted, events.TypeUpdated}},
+		{name: "отвязка подзадач", children: models.ChildrenDetach, wantEvents: []string{events.TypeDeleted, events.TypeUpdated}},
+		{name: "окончательное удаление", children: models.ChildrenCascade, hard: true, wantEvents: []string{events.TypeDeleted, events.TypeUpdated}},
+		{name: "неизвестный режим", children: "orphan", wantErr: models.ErrInvalidQuery},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			publisher := &recordingPublisher{}
+			storage := &stubStorage{detached: []models.Todo{{ID: 2}}}
+			service := NewTodoService(storage, WithPublisher(publisher))
+
+			del := service.Delete
+			if tc.hard {
+				del = service.HardDelete
+			}
+			if err := del(context.Background(), 1, 0, tc.children); !errors.Is(err, tc.wantErr) {
+				t.Fatalf("ожидалась ошибка %v, получено %v", tc.wantErr, err)
+			}
+			if !slices.Equal(publisher.types, tc.wantEvents) {
+				t.Fatalf("опубликованы %v, ожидались %v", publisher.types, tc.wantEvents)
+			}
+		})
+	}
+}
+
+func TestTodoServiceExpand(t *testing.T) {
+	storage := &stubStorage{descendants: []models.Todo{
+		{ID: 3, Title: "подзадача", ParentID: 1},
+		{ID: 4, Title: "вложенная", ParentID: 3},
+		{ID: 5, Title: "еще подзадача", ParentID: 1},
+	}}
+	service := NewTodoService(storage)
+
+	trees, err := service.Expand(context.Background(), []models.Todo{{ID: 2, Title: "без подзадач"}, {ID: 1, Title: "корень"}})
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+
+	want := []models.TodoTree{
+		{Todo: models.Todo{ID: 2, Title: "без подзадач"}, Subtasks: []models.TodoTree{}},
+		{Todo: models.Todo{ID: 1, Title: "корень"}, Subtasks: []models.TodoTree{
+			{Todo: storage.descendants[0], Subtasks: []models.TodoTree{
+				{Todo: storage.descendants[1], Subtasks: []models.TodoTree{}},
+			}},
+			{Todo: storage.descendants[2], Subtasks: []models.TodoTree{}},
+		}},
+	}
+	if !reflect.DeepEqual(trees, want) {
+		t.Fatalf("ожидались деревья %+v, получено %+v", want, trees)
+	}
+}
+
+func TestTodoServiceAutoComplete(t *testing.T) {
+	now := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	child := models.Todo{ID: 3, Title: "подзадача", ParentID: 2, Version: 1}
+
+	cases := []struct {
+		name          string
+		opts          []Option
+		completed     bool
+		wantEvents    []string
+		wantCompleted []int
+	}{
+		{
+			name:          "завершение поднимается по цепочке",
+			opts:          []Option{WithAutoComplete()},
+			completed:     true,
+			wantEvents:    []string{events.TypeUpdated, events.TypeUpdated, events.TypeUpdated},
+			wantCompleted: []int{1, 2},
+		},
+		{
+			name:       "незавершенная подзадача",
+			opts:       []Option{WithAutoComplete()},
+			wantEvents: []string{events.TypeUpdated},
+		},
+		{
+			name:       "без WithAutoComplete",
+			completed:  true,
+			wantEvents: []string{events.TypeUpdated},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			publisher := &recordingPublisher{}
+			storage := &stubStorage{
+				current: child,
+				parents: map[int]models.Todo{
+					1: {ID: 1, Title: "проект", Version: 1},
+					2: {ID: 2, Title: "этап", ParentID: 1, Version: 1},
+				},
+			}
+			opts := append([]Option{WithClock(fixedClock(now)), WithPublisher(publisher)}, tc.opts...)
+			service := NewTodoService(storage, opts...)
+
+			update := child
+			update.Completed = tc.completed
+			if _, err := service.Update(context.Background(), update); err != nil {
+				t.Fatalf("неожиданная ошибка: %v", err)
+			}
+			if !slices.Equal(publisher.types, tc.wantEvents) {
+				t.Fatalf("опубликованы %v, ожидались %v", publisher.types, tc.wantEvents)
+			}
+
+			var completed []int
+			for _, id := range []int{1, 2} {
+				parent := storage.parents[id]
+				if !parent.Completed {
+					continue
+				}
+				if parent.CompletedAt == nil || !parent.CompletedAt.Equal(now) {
+					t.Fatalf("задача %d: ожидалось время завершения %v, получено %v", id, now, parent.CompletedAt)
+				}
+				completed = append(completed, id)
+			}
+			if !slices.Equal(completed, tc.wantCompleted) {
+				t.Fatalf("завершены %v, ожидались %v", completed, tc.wantCompleted)
+			}
+		})
+	}
+}
+
+func TestTodoServiceBatchAutoComplete(t *testing.T) {
+	child := models.Todo{ID: 2, Title: "подзадача", ParentID: 1, Completed: true, Version: 1}
+
+	cases := []struct {
+		name          string
+		op            models.BatchOperation
+		wantCompleted bool
+	}{
+		{
+			name:          "завершение подзадачи",
+			op:            models.BatchOperation{Op: models.BatchUpdate, ID: child.ID, Todo: &child},
+			wantCompleted: true,
+		},
+		{
+			name: "удаление завершенной подзадачи",
+			op:   models.BatchOperation{Op: models.BatchDelete, ID: child.ID},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			storage := &stubStorage{
+				current:      child,
+				batchDeleted: child,
+				parents:      map[int]models.Todo{1: {ID: 1, Title: "проект", Version: 1}},
+			}
+			service := NewTodoService(storage, WithAutoComplete())
+
+			results, err := service.Batch(context.Background(), []models.BatchOperation{tc.op}, true)
+			if err != nil || results[0].Err != nil {
+				t.Fatalf("неожиданная ошибка: %v, %v", err, results[0].Err)
+			}
+			if got := storage.parents[1].Completed; got != tc.wantCompleted {
+				t.Fatalf("родитель завершен = %v, ожидалось %v", got, tc.wantCompleted)
+			}
+		})
+	}
+}
+
+func TestTodoServiceHistory(t *testing.T) {
+	now := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	history := []models.Todo{
+		{ID: 1, Title: "черновик", Version: 1},
+		{ID: 1, Title: "задача", Version: 2},
+		{ID: 1, Title: "задача", Description: "подробности", Completed: true, Version: 3, CompletedAt: &now},
+	}
+	storage := &stubStorage{history: history, current: history[2]}
+	service := NewTodoService(storage, WithClock(fixedClock(now)))
+	ctx := context.Background()
+
+	revisions, err := service.History(ctx, 1)
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if len(revisions) != len(history) {
+		t.Fatalf("ожидалось %d ревизий, получено %d", len(history), len(revisions))
+	}
+	if got := changedFields(revisions[0].Changes); !slices.Equal(got, []string{"title"}) {
+		t.Fatalf("первая ревизия: изменены %v, ожидался только title", got)
+	}
+	if got := changedFields(revisions[2].Changes); !slices.Equal(got, []string{"completed", "completed_at", "description"}) {
+		t.Fatalf("третья ревизия: изменены %v", got)
+	}
+
+	revision, err := service.Revision(ctx, 1, 3, 1)
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if got := changedFields(revision.Changes); !slices.Equal(got, []string{"completed", "completed_at", "description", "title"}) {
+		t.Fatalf("изменения от первой ревизии: %v", got)
+	}
+	if string(revision.Changes[3].From) != `"черновик"` || string(revision.Changes[3].To) != `"задача"` {
+		t.Fatalf("неверное изменение title: %s -> %s", revision.Changes[3].From, revision.Changes[3].To)
+	}
+
+	for _, rev := range []int{0, 4} {
+		if _, err := service.Revision(ctx, 1, rev, 0); !errors.Is(err, models.ErrRevisionNotFound) {
+			t.Fatalf("ревизия %d: ожидалась ошибка %v, получено %v", rev, models.ErrRevisionNotFound, err)
+		}
+	}
+	if _, err := service.Revision(ctx, 1, 2, 7); !errors.Is(err, models.ErrRevisionNotFound) {
+		t.Fatalf("ожидалась ошибка %v, получено %v", models.ErrRevisionNotFound, err)
+	}
+
+	reverted, err := service.Revert(ctx, 1, 1, 0)
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if reverted.Title != "черновик" || reverted.Description != "" || reverted.Completed || reverted.CompletedAt != nil {
+		t.Fatalf("задача не вернулась к первой ревизии: %+v", reverted)
+	}
+	if _, err := service.Revert(ctx, 1, 1, 2); !errors.Is(err, models.ErrVersionMismatch) {
+		t.Fatalf("ожидалась ошибка %v, получено %v", models.ErrVersionMismatch, err)
+	}
+
+	// Откат проходит ту же валидацию, что и Update.
+	storage.history = []models.Todo{{ID: 1, Title: " ", Version: 1}}
+	if _, err := service.Revert(ctx, 1, 1, 0); !errors.Is(err, models.ErrEmptyTitle) {
+		t.Fatalf("ожидалась ошибка %v, получено %v", models.ErrEmptyTitle, err)
+	}
+}
+
+// changedFields возвращает имена измененных полей.
+func changedFields(changes []models.FieldChange) []string {
+	names := make([]string, len(changes))
+	for i, change := range changes {
+		names[i] = change.Field
+	}
+	return names
+}
+
+func TestPurger(t *testing.T) {
+	now := time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC)
+	storage := &stubStorage{}
+	purger := newPurger(storage, PurgerOptions{TTL: 30 * 24 * time.Hour, Clock: fixedClock(now)})
+
+	purged, err := purger.Purge(context.Background())
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if purged != 1 {
+		t.Fatalf("ожидалась 1 удаленная задача, получено %d", purged)
+	}
+	if want := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC); !storage.purgeBefore.Equal(want) {
+		t.Fatalf("ожидалась граница %v, получено %v", want, storage.purgeBefore)
+	}
+}
+
+// reminderStub хранилище задач для планировщика напоминаний.
+type reminderStub map[int]models.Todo
+
+func (s reminderStub) Reminders(_ context.Context, after time.Time) ([]models.Todo, error) {
+	var todos []models.Todo
+	for _, todo := range s {
+		if !todo.Completed && todo.RemindAt != nil && todo.RemindAt.After(after) {
+			todos = append(todos, todo)
+		}
+	}
+	return todos, nil
+}
+
+func (s reminderStub) GetByID(_ context.Context, _ string, id int) (models.Todo, error) {
+	todo, ok := s[id]
+	if !ok {
+		return models.Todo{}, models.ErrNotFound
+	}
+	return todo, nil
+}
+
+func TestReminderScheduler(t *testing.T) {
+	now := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+
+	storage := reminderStub{
+		1: {ID: 1, Title: "позвонить", RemindAt: at(time.Hour)},
+		2: {ID: 2, Title: "написать отчет", RemindAt: at(2 * time.Hour)},
+		3: {ID: 3, Title: "выполнена", Completed: true, RemindAt: at(time.Hour)},
+		4: {ID: 4, Title: "удалить", RemindAt: at(time.Hour)},
+		5: {ID: 5, Title: "напоминание прошло", RemindAt: at(-time.Hour)},
+	}
+
+	var notified []int
+	scheduler, err := NewReminderScheduler(context.Background(), storage, ReminderOptions{
+		Notifiers: []Notifier{NotifierFunc(func(todo models.Todo) {
+			notified = append(notified, todo.ID)
+		})},
+		Clock: fixedClock(now),
+	})
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	defer scheduler.Close()
+
+	if scheduler.Len() != 3 {
+		t.Fatalf("ожидалось 3 запланированных напоминания, получено %d", scheduler.Len())
+	}
+
+	// Напоминание задачи 2 перенесено раньше, задача 4 удалена, новая задача 6 запланирована на потом.
+	storage[2] = models.Todo{ID: 2, Title: "написать отчет", RemindAt: at(30 * time.Minute)}
+	scheduler.Publish(events.TypeUpdated, storage[2])
+	scheduler.Publish(events.TypeDeleted, storage[4])
+	delete(storage, 4)
+	storage[6] = models.Todo{ID: 6, Title: "завтра", RemindAt: at(24 * time.Hour)}
+	scheduler.Publish(events.TypeCreated, storage[6])
+
+	// Задача 1 завершена в хранилище: напоминание о ней не отправляется.
+	storage[1] = models.Todo{ID: 1, Title: "позвонить", Completed: true, RemindAt: at(time.Hour)}
+
+	scheduler.fire(context.Background(), now.Add(time.Hour))
+
+	if !reflect.DeepEqual(notified, []int{2}) {
+		t.Fatalf("ожидались напоминания о задачах [2], получено %v", notified)
+	}
+	if scheduler.Len() != 1 {
+		t.Fatalf("ожидалось 1 оставшееся напоминание, получено %d", scheduler.Len())
+	}
+}
+
+// versionRecorder запоминает версии опубликованных задач в порядке публикации.
+type versionRecorder struct {
+	mu       sync.Mutex
+	versions []int
+}
+
+func (p *versionRecorder) Publish(_ string, todo models.Todo) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.versions = append(p.versions, todo.Version)
+}
+
+// delayPublisher задерживает публикацию на время, зависящее от версии задачи.
+type delayPublisher struct{}
+
+func (delayPublisher) Publish(_ string, todo models.Todo) {
+	time.Sleep(time.Duration(todo.Version%3) * time.Millisecond)
+}
+
+func TestTodoServicePublishOrder(t *testing.T) {
+	publisher := &versionRecorder{}
+	// Задержка перед записью расширяет окно между сохранением изменения и его публикацией.
+	service := NewTodoService(repository.NewTodoStorage(), WithPublisher(delayPublisher{}), WithPublisher(publisher))
+	ctx := context.Background()
+
+	if _, err := service.Create(ctx, models.Todo{Title: "задача"}); err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+
+	const writers = 50
+	var wg sync.WaitGroup
+	for i := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := service.Update(ctx, models.Todo{ID: 1, Title: fmt.Sprintf("версия %d", i)}); err != nil {
+				t.Errorf("неожиданная ошибка: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(publisher.versions) != writers+1 {
+		t.Fatalf("ожидалось %d событий, получено %d", writers+1, len(publisher.versions))
+	}
+	if !slices.IsSorted(publisher.versions) {
+		t.Fatalf("события опубликованы не в порядке версий: %v", publisher.versions)
+	}
+}
+
+// completionRecorder запоминает опубликованные изменения и переводы задач в завершенные.
+type completionRecorder struct {
+	events []string
+}
+
+func (p *completionRecorder) Publish(typ string, todo models.Todo) {
+	p.events = append(p.events, fmt.Sprintf("%s %d", typ, todo.ID))
+}
+
+func (p *completionRecorder) PublishCompleted(todo models.Todo) {
+	p.events = append(p.events, fmt.Sprintf("%s %d", models.WebhookEventCompleted, todo.ID))
+}
+
+// tickingClock каждый раз возвращает время на секунду больше предыдущего.
+type tickingClock struct {
+	now time.Time
+}
+
+func (c *tickingClock) Now() time.Time {
+	c.now = c.now.Add(time.Second)
+	return c.now
+}
+
+func TestTodoServicePublishCompleted(t *testing.T) {
+	publisher := &completionRecorder{}
+	clock := &tickingClock{now: time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)}
+	service := NewTodoService(repository.NewTodoStorage(), WithClock(clock), WithPublisher(publisher))
+	ctx := context.Background()
+
+	steps := []struct {
+		name string
+		run  func() error
+		want []string
+	}{
+		{
+			name: "создание незавершенной задачи",
+			run: func() error {
+				_, err := service.Create(ctx, models.Todo{Title: "задача"})
+				return err
+			},
+			want: []string{"created 1"},
+		},
+		{
+			name: "завершение",
+			run: func() error {
+				_, err := service.Update(ctx, models.Todo{ID: 1, Title: "задача", Completed: true})
+				return err
+			},
+			want: []string{"updated 1", "completed 1"},
+		},
+		{
+			name: "изменение завершенной задачи",
+			run: func() error {
+				_, err := service.Patch(ctx, 1, 0, models.PatchMerge, []byte(`{"title":"другая"}`))
+				return err
+			},
+			want: []string{"updated 1"},
+		},
+		{
+			name: "возврат в работу и повторное завершение в пакете",
+			run: func() error {
+				_, err := service.Batch(ctx, []models.BatchOperation{
+					{Op: models.BatchUpdate, ID: 1, Todo: &models.Todo{Title: "задача"}},
+					{Op: models.BatchUpdate, ID: 1, Todo: &models.Todo{Title: "задача", Completed: true}},
+				}, true)
+				return err
+			},
+			want: []string{"updated 1", "updated 1", "completed 1"},
+		},
+		{
+			name: "создание завершенной задачи",
+			run: func() error {
+				_, err := service.Create(ctx, models.Todo{Title: "готово", Completed: true})
+				return err
+			},
+			want: []string{"created 2", "completed 2"},
+		},
+		{
+			name: "удаление завершенной задачи",
+			run: func() error {
+				_, err := service.Batch(ctx, []models.BatchOperation{{Op: models.BatchDelete, ID: 2}}, true)
+				return err
+			},
+			want: []string{"deleted 2"},
+		},
+	}
+
+	for _, step := range steps {
+		publisher.events = nil
+		if err := step.run(); err != nil {
+			t.Fatalf("%s: неожиданная ошибка: %v", step.name, err)
+		}
+		if !slices.Equal(publisher.events, step.want) {
+			t.Fatalf("%s: опубликованы %v, ожидались %v", step.name, publisher.events, step.want)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// errForbiddenAddress адрес получателя во внутренней или служебной сети.
+var errForbiddenAddress = errors.New("адрес во внутренней или служебной сети запрещен")
+
+// forbiddenPrefixes служебные диапазоны, не покрытые методами netip.Addr.
+var forbiddenPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// forbiddenAddr сообщает, что адрес принадлежит локальной, частной или служебной сети:
+// запросы на него позволили бы пользователю обращаться к внутренним сервисам от имени сервера.
+func forbiddenAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() || addr.IsMulticast() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() {
+		return true
+	}
+
+	for _, prefix := range forbiddenPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkHost проверяет хост адреса подписки. Имена, кроме localhost, не разрешаются:
+// адрес, в который они разрешатся при доставке, проверяет dialControl.
+func checkHost(host string) error {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return errForbiddenAddress
+	}
+
+	if addr, err := netip.ParseAddr(host); err == nil && forbiddenAddr(addr) {
+		return errForbiddenAddress
+	}
+
+	return nil
+}
+
+// dialControl проверяет адрес, к которому подключается клиент доставки, уже после
+// разрешения имени: так подписка не обойдет проверку, сменив DNS-запись после регистрации.
+func dialControl(_, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("некорректный адрес %q: %w", address, err)
+	}
+	if forbiddenAddr(addrPort.Addr()) {
+		return fmt.Errorf("%s: %w", addrPort.Addr(), errForbiddenAddress)
+	}
+
+	return nil
+}
+
+// newClient создает HTTP-клиент доставок. Без allowPrivate подключения к внутренним
+// адресам отклоняются; прокси из окружения не используются, так как иначе проверялся бы
+// адрес прокси, а не получателя.
+func newClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = dialControl
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
@@ -0,0 +1,86 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/RoGogDBD/ecom/internal/models"
+)
+
+func TestServicePrivateAddresses(t *testing.T) {
+	cases := []struct {
+		url     string
+		wantErr error
+	}{
+		{url: "https://example.com/hook"},
+		{url: "http://93.184.216.34:8080/hook"},
+		{url: "http://localhost:8080/hook", wantErr: models.ErrInvalidWebhook},
+		{url: "http://LocalHost./hook", wantErr: models.ErrInvalidWebhook},
+		{url: "http://api.localhost/hook", wantErr: models.ErrInvalidWebhook},
+		{url: "http://127.0.0.1/hook", wantErr: models.ErrInvalidWebhook},
+		{url: "http://[::1]/hook", wantErr: models.ErrInvalidWebhook},
+		{url: "http://[::ffff:127.0.0.1]/hook", wantErr: models.ErrInvalidWebhook},
+		{url: "http://0.0.0.0/hook", wantErr: models.ErrInvalidWebhook},
+		{url: "http://169.254.169.254/latest/meta-data", wantErr: models.ErrInvalidWebhook},
+		{url: "http://10.1.2.3/hook", wantErr: models.ErrInvalidWebhook},
+		{url: "http://172.16.0.1/hook", wantErr: models.ErrInvalidWebhook},
+		{url: "http://192.168.1.1/hook", wantErr: models.ErrInvalidWebhook},
+		{url: "http://100.64.0.1/hook", wantErr: models.ErrInvalidWebhook},
+		{url: "http://[fd00::1]/hook", wantErr: models.ErrInvalidWebhook},
+		{url: "http://[fe80::1]/hook", wantErr: models.ErrInvalidWebhook},
+	}
+
+	s := New(Options{Workers: 1})
+	defer s.Close()
+
+	for _, tc := range cases {
+		t.Run(tc.url, func(t *testing.T) {
+			_, err := s.Create(context.Background(), models.Webhook{URL: tc.url, Events: []string{models.WebhookEventCreated}})
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("ожидалась ошибка %v, получено %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestDialControl(t *testing.T) {
+	cases := []struct {
+		address string
+		wantErr bool
+	}{
+		{address: "93.184.216.34:443"},
+		{address: "[2606:2800:220:1:248:1893:25c8:1946]:443"},
+		{address: "127.0.0.1:80", wantErr: true},
+		{address: "169.254.169.254:80", wantErr: true},
+		{address: "[::ffff:10.0.0.1]:80", wantErr: true},
+		{address: "[::1]:80", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.address, func(t *testing.T) {
+			err := dialControl("tcp", tc.address, nil)
+			if gotErr := errors.Is(err, errForbiddenAddress); gotErr != tc.wantErr {
+				t.Fatalf("dialControl(%q) = %v, ожидалась ошибка: %v", tc.address, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestClientRejectsPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(&receiver{})
+	defer server.Close()
+
+	// Клиент проверяет адрес при подключении, независимо от проверки при регистрации подписки.
+	if _, err := newClient(time.Second, false).Get(server.URL); !errors.Is(err, errForbiddenAddress) {
+		t.Fatalf("ожидалась ошибка %v, получено %v", errForbiddenAddress, err)
+	}
+
+	resp, err := newClient(time.Second, true).Get(server.URL)
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	_ = resp.Body.Close()
+}
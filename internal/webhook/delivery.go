@@ -0,0 +1,273 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/RoGogDBD/ecom/internal/models"
+	"github.com/RoGogDBD/ecom/internal/requestid"
+)
+
+// Заголовки запроса доставки.
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+
+	signaturePrefix = "sha256="
+	userAgent       = "ecom-webhook/1"
+
+	// maxResponseBytes сколько байт ответа получателя читается, чтобы переиспользовать соединение.
+	maxResponseBytes = 64 << 10
+
+	errQueueFull = "очередь доставки переполнена"
+
+	resultSuccess = "success"
+	resultFailure = "failure"
+)
+
+type (
+	// job одна попытка доставки события на подписку.
+	job struct {
+		owner    string
+		hookID   int
+		delivery string
+		event    string
+		body     []byte
+		attempt  int
+	}
+
+	// payload тело запроса доставки.
+	payload struct {
+		Event      string      `json:"event"`
+		OccurredAt time.Time   `json:"occurred_at"`
+		Todo       models.Todo `json:"todo"`
+	}
+)
+
+// Publish ставит в очередь доставки события typ (created, updated, deleted или reminder) о задаче todo
+// подписчикам ее владельца.
+func (s *Service) Publish(typ string, todo models.Todo) {
+	s.enqueue(typ, todo)
+}
+
+// PublishCompleted ставит в очередь доставки события completed о задаче todo, только что
+// переведенной в завершенные. Переход определяет TodoService по состоянию до изменения.
+func (s *Service) PublishCompleted(todo models.Todo) {
+	s.enqueue(models.WebhookEventCompleted, todo)
+}
+
+// enqueue ставит в очередь доставки события event о задаче todo подписчикам, подписанным на него.
+func (s *Service) enqueue(event string, todo models.Todo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tn := s.tenants[todo.Owner]
+	if s.closed || tn == nil {
+		return
+	}
+
+	now := s.now()
+	var body []byte
+	for _, h := range tn.hooks {
+		if !slices.Contains(h.webhook.Events, event) {
+			continue
+		}
+		if body == nil {
+			var err error
+			if body, err = json.Marshal(payload{Event: event, OccurredAt: now, Todo: todo}); err != nil {
+				return
+			}
+		}
+
+		delivery := &models.WebhookDelivery{
+			ID:        requestid.New(),
+			Event:     event,
+			TodoID:    todo.ID,
+			Status:    models.DeliveryPending,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		h.record(delivery, s.opts.History)
+
+		j := job{owner: todo.Owner, hookID: h.webhook.ID, delivery: delivery.ID, event: event, body: body, attempt: 1}
+		select {
+		case s.jobs <- j:
+		default:
+			delivery.Status = models.DeliveryFailed
+			delivery.Error = errQueueFull
+		}
+	}
+}
+
+// Signature возвращает значение заголовка X-Webhook-Signature: HMAC-SHA256 ключом secret
+// от строки "<timestamp>.<body>". Получатель вычисляет ее так же и сравнивает
+// за постоянное время; метка времени в подписи защищает от повторной отправки.
+func Signature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *Service) worker() {
+	defer s.wg.Done()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case j := <-s.jobs:
+			s.deliver(j)
+		}
+	}
+}
+
+// deliver выполняет одну попытку и планирует следующую при временной ошибке.
+func (s *Service) deliver(j job) {
+	s.mu.Lock()
+	h, err := s.hook(j.owner, j.hookID)
+	var target models.Webhook
+	if err == nil {
+		// Адрес и ключ копируются под блокировкой: подписку могут изменить во время отправки.
+		target = h.webhook
+	}
+	s.mu.Unlock()
+	if err != nil {
+		// Подписку удалили, пока доставка ждала в очереди.
+		return
+	}
+
+	code, err := s.send(target, j)
+	if err != nil {
+		s.metrics.attempt(resultFailure)
+	} else {
+		s.metrics.attempt(resultSuccess)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	delivery := h.find(j.delivery)
+	if delivery != nil {
+		delivery.Attempts = j.attempt
+		delivery.ResponseCode = code
+		delivery.UpdatedAt = now
+		delivery.NextAttemptAt = nil
+		delivery.Error = ""
+		delivery.Status = models.DeliverySucceeded
+	}
+	if err == nil {
+		return
+	}
+
+	if delivery != nil {
+		delivery.Error = err.Error()
+		delivery.Status = models.DeliveryFailed
+	}
+	if s.closed || j.attempt >= s.opts.MaxAttempts || !retryable(code) {
+		return
+	}
+
+	delay := s.backoff(j.attempt)
+	if delivery != nil {
+		next := now.Add(delay)
+		delivery.Status = models.DeliveryPending
+		delivery.NextAttemptAt = &next
+	}
+
+	j.attempt++
+	time.AfterFunc(delay, func() {
+		select {
+		case s.jobs <- j:
+		case <-s.ctx.Done():
+		}
+	})
+}
+
+// send отправляет событие и возвращает HTTP-статус ответа. Ответ не из диапазона 2xx — ошибка.
+func (s *Service) send(target models.Webhook, j job) (int, error) {
+	ctx, cancel := context.WithTimeout(s.ctx, s.opts.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.URL, bytes.NewReader(j.body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(s.now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set(HeaderEvent, j.event)
+	req.Header.Set(HeaderDelivery, j.delivery)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Signature(target.Secret, timestamp, j.body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBytes))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("получатель ответил статусом %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// backoff задержка перед попыткой attempt+1: Backoff, 2*Backoff, 4*Backoff... не больше MaxBackoff.
+func (s *Service) backoff(attempt int) time.Duration {
+	delay := s.opts.Backoff
+	for i := 1; i < attempt && delay < s.opts.MaxBackoff; i++ {
+		delay *= 2
+	}
+
+	return min(delay, s.opts.MaxBackoff)
+}
+
+// retryable сообщает, имеет ли смысл повторять доставку: при сетевой ошибке (code == 0),
+// 408, 429 и 5xx. Остальные ответы 4xx означают, что получатель отверг событие.
+func retryable(code int) bool {
+	return code == 0 || code == http.StatusRequestTimeout || code == http.StatusTooManyRequests || code >= 500
+}
+
+// record добавляет доставку в журнал подписки, оставляя не больше limit последних.
+func (h *hook) record(delivery *models.WebhookDelivery, limit int) {
+	h.deliveries = append(h.deliveries, delivery)
+	if extra := len(h.deliveries) - limit; extra > 0 {
+		h.deliveries = slices.Delete(h.deliveries, 0, extra)
+	}
+}
+
+// find возвращает доставку по ID или nil, если она уже вытеснена из журнала.
+func (h *hook) find(id string) *models.WebhookDelivery {
+	for _, delivery := range h.deliveries {
+		if delivery.ID == id {
+			return delivery
+		}
+	}
+	return nil
+}
+
+func (m *serviceMetrics) attempt(result string) {
+	if m == nil {
+		return
+	}
+
+	m.attempts.Inc(result)
+}
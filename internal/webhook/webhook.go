@@ -0,0 +1,389 @@
+// Package webhook хранит подписки на события задач и доставляет события
+// подписчикам HTTP-запросами, подписанными HMAC-SHA256.
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/RoGogDBD/ecom/internal/auth"
+	"github.com/RoGogDBD/ecom/internal/metrics"
+	"github.com/RoGogDBD/ecom/internal/models"
+)
+
+const (
+	defaultWorkers     = 4
+	defaultQueueSize   = 1000
+	defaultMaxAttempts = 5
+	defaultBackoff     = time.Second
+	defaultMaxBackoff  = time.Minute
+	defaultTimeout     = 10 * time.Second
+	defaultHistory     = 50
+
+	// secretBytes длина генерируемого ключа подписи.
+	secretBytes = 32
+)
+
+// knownEvents события, на которые можно подписаться.
+var knownEvents = []string{
+	models.WebhookEventCreated,
+	models.WebhookEventUpdated,
+	models.WebhookEventDeleted,
+	models.WebhookEventCompleted,
+	models.WebhookEventReminder,
+}
+
+type (
+	// Options параметры доставки. Нулевые значения заменяются значениями по умолчанию.
+	Options struct {
+		// Workers количество одновременных доставок.
+		Workers int
+		// QueueSize размер очереди доставок; при переполнении новые доставки отклоняются.
+		QueueSize int
+		// MaxAttempts максимальное количество попыток одной доставки.
+		MaxAttempts int
+		// Backoff задержка перед второй попыткой; каждая следующая вдвое больше.
+		Backoff time.Duration
+		// MaxBackoff верхняя граница задержки между попытками.
+		MaxBackoff time.Duration
+		// Timeout время ожидания ответа получателя.
+		Timeout time.Duration
+		// History количество последних доставок, хранимых для каждой подписки.
+		History int
+		// AllowPrivateNetworks разрешает адреса подписок в локальных, частных и служебных сетях
+		// (localhost, 10.0.0.0/8, 169.254.0.0/16 и т. п.). По умолчанию такие адреса отклоняются
+		// при регистрации подписки и при подключении во время доставки.
+		AllowPrivateNetworks bool
+		// Client HTTP-клиент для доставок; по умолчанию http.Client с Timeout, не подключающийся
+		// к внутренним адресам без AllowPrivateNetworks.
+		Client *http.Client
+	}
+
+	// Service управляет подписками и асинхронно доставляет события пулом воркеров.
+	// Подписки хранятся в памяти и изолированы по владельцам, как и задачи.
+	Service struct {
+		opts   Options
+		client *http.Client
+		now    func() time.Time
+
+		mu      sync.Mutex
+		tenants map[string]*tenant
+		closed  bool
+
+		jobs      chan job
+		ctx       context.Context
+		cancel    context.CancelFunc
+		wg        sync.WaitGroup
+		closeOnce sync.Once
+
+		metrics *serviceMetrics
+	}
+
+	// tenant подписки одного владельца.
+	tenant struct {
+		hooks  map[int]*hook
+		lastID int
+	}
+
+	hook struct {
+		webhook models.Webhook
+		// deliveries последние доставки, от старых к новым.
+		deliveries []*models.WebhookDelivery
+	}
+
+	serviceMetrics struct {
+		attempts *metrics.Counter
+	}
+)
+
+// New создает сервис и запускает воркеры доставки. Остановка — Close.
+func New(opts Options) *Service {
+	opts = withDefaults(opts)
+
+	client := opts.Client
+	if client == nil {
+		client = newClient(opts.Timeout, opts.AllowPrivateNetworks)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Service{
+		opts:    opts,
+		client:  client,
+		now:     func() time.Time { return time.Now().UTC() },
+		tenants: make(map[string]*tenant),
+		jobs:    make(chan job, opts.QueueSize),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+
+	s.wg.Add(opts.Workers)
+	for range opts.Workers {
+		go s.worker()
+	}
+
+	return s
+}
+
+func withDefaults(opts Options) Options {
+	if opts.Workers <= 0 {
+		opts.Workers = defaultWorkers
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = defaultQueueSize
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = defaultMaxAttempts
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = defaultBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = defaultMaxBackoff
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+	if opts.History <= 0 {
+		opts.History = defaultHistory
+	}
+
+	return opts
+}
+
+// Instrument регистрирует метрики доставки в reg: длину очереди и количество попыток по результату.
+func (s *Service) Instrument(reg *metrics.Registry) {
+	reg.NewGaugeFunc("webhook_queue_length", "Количество доставок webhook в очереди.", func() float64 {
+		return float64(len(s.jobs))
+	})
+
+	s.metrics = &serviceMetrics{
+		attempts: reg.NewCounter("webhook_delivery_attempts_total",
+			"Количество попыток доставки webhook.", "result"),
+	}
+}
+
+// Close останавливает воркеры и прерывает текущие доставки.
+// Недоставленные события теряются: подписки и очередь хранятся только в памяти.
+func (s *Service) Close() {
+	s.closeOnce.Do(func() {
+		s.mu.Lock()
+		s.closed = true
+		s.mu.Unlock()
+
+		s.cancel()
+		s.wg.Wait()
+	})
+}
+
+// Create создает подписку пользователя из ctx. Если ключ подписи не указан, он генерируется.
+// Созданная подписка возвращается вместе с ключом — позже он не выдается.
+func (s *Service) Create(ctx context.Context, webhook models.Webhook) (models.Webhook, error) {
+	if err := normalize(&webhook, s.opts.AllowPrivateNetworks); err != nil {
+		return models.Webhook{}, err
+	}
+	if webhook.Secret == "" {
+		secret, err := newSecret()
+		if err != nil {
+			return models.Webhook{}, err
+		}
+		webhook.Secret = secret
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tn := s.tenant(owner(ctx))
+	tn.lastID++
+
+	now := s.now()
+	webhook.ID = tn.lastID
+	webhook.Owner = owner(ctx)
+	webhook.CreatedAt = now
+	webhook.UpdatedAt = now
+	tn.hooks[webhook.ID] = &hook{webhook: webhook}
+
+	webhook.Events = slices.Clone(webhook.Events)
+	return webhook, nil
+}
+
+// Update заменяет адрес и события подписки. Пустой Secret сохраняет прежний ключ.
+func (s *Service) Update(ctx context.Context, webhook models.Webhook) (models.Webhook, error) {
+	if webhook.ID <= 0 {
+		return models.Webhook{}, models.ErrWebhookNotFound
+	}
+	if err := normalize(&webhook, s.opts.AllowPrivateNetworks); err != nil {
+		return models.Webhook{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, err := s.hook(owner(ctx), webhook.ID)
+	if err != nil {
+		return models.Webhook{}, err
+	}
+
+	h.webhook.URL = webhook.URL
+	h.webhook.Events = webhook.Events
+	if webhook.Secret != "" {
+		h.webhook.Secret = webhook.Secret
+	}
+	h.webhook.UpdatedAt = s.now()
+
+	return public(h.webhook), nil
+}
+
+// Delete удаляет подписку. Доставки, ожидающие повторной попытки, отменяются.
+func (s *Service) Delete(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.hook(owner(ctx), id); err != nil {
+		return err
+	}
+
+	delete(s.tenants[owner(ctx)].hooks, id)
+	return nil
+}
+
+// List возвращает подписки пользователя, упорядоченные по ID.
+func (s *Service) List(ctx context.Context) ([]models.Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := []models.Webhook{}
+	if tn := s.tenants[owner(ctx)]; tn != nil {
+		for _, h := range tn.hooks {
+			result = append(result, public(h.webhook))
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+
+	return result, nil
+}
+
+// GetByID возвращает подписку пользователя.
+func (s *Service) GetByID(ctx context.Context, id int) (models.Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, err := s.hook(owner(ctx), id)
+	if err != nil {
+		return models.Webhook{}, err
+	}
+
+	return public(h.webhook), nil
+}
+
+// Deliveries возвращает последние доставки подписки, начиная с новых.
+func (s *Service) Deliveries(ctx context.Context, id int) ([]models.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, err := s.hook(owner(ctx), id)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]models.WebhookDelivery, 0, len(h.deliveries))
+	for i := len(h.deliveries) - 1; i >= 0; i-- {
+		result = append(result, *h.deliveries[i])
+	}
+
+	return result, nil
+}
+
+// tenant возвращает подписки владельца, создавая их при первом обращении. Вызывается под s.mu.
+func (s *Service) tenant(owner string) *tenant {
+	tn, ok := s.tenants[owner]
+	if !ok {
+		tn = &tenant{hooks: make(map[int]*hook)}
+		s.tenants[owner] = tn
+	}
+
+	return tn
+}
+
+// hook возвращает подписку владельца. Вызывается под s.mu.
+func (s *Service) hook(owner string, id int) (*hook, error) {
+	tn := s.tenants[owner]
+	if tn == nil {
+		return nil, models.ErrWebhookNotFound
+	}
+
+	h, ok := tn.hooks[id]
+	if !ok {
+		return nil, models.ErrWebhookNotFound
+	}
+
+	return h, nil
+}
+
+// normalize проверяет адрес и события подписки, удаляя повторы событий.
+// Без allowPrivate адрес во внутренней или служебной сети отклоняется.
+func normalize(webhook *models.Webhook, allowPrivate bool) error {
+	target, err := url.Parse(webhook.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Hostname() == "" {
+		return fmt.Errorf("%w: url должен быть абсолютным адресом http или https", models.ErrInvalidWebhook)
+	}
+	if !allowPrivate {
+		if err := checkHost(target.Hostname()); err != nil {
+			return fmt.Errorf("%w: url: %w", models.ErrInvalidWebhook, err)
+		}
+	}
+
+	if len(webhook.Events) == 0 {
+		return fmt.Errorf("%w: нужно указать хотя бы одно событие", models.ErrInvalidWebhook)
+	}
+	events := make([]string, 0, len(webhook.Events))
+	for _, event := range webhook.Events {
+		if !slices.Contains(knownEvents, event) {
+			return fmt.Errorf("%w: неизвестное событие %q, допустимы %s",
+				models.ErrInvalidWebhook, event, strings.Join(knownEvents, ", "))
+		}
+		if !slices.Contains(events, event) {
+			events = append(events, event)
+		}
+	}
+	webhook.Events = events
+
+	if webhook.Secret != "" && strings.TrimSpace(webhook.Secret) == "" {
+		return fmt.Errorf("%w: secret не может состоять из пробелов", models.ErrInvalidWebhook)
+	}
+
+	return nil
+}
+
+// public возвращает подписку без ключа подписи.
+func public(webhook models.Webhook) models.Webhook {
+	webhook.Secret = ""
+	webhook.Events = slices.Clone(webhook.Events)
+	return webhook
+}
+
+func newSecret() (string, error) {
+	buf := make([]byte, secretBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("не удалось сгенерировать secret: %w", err)
+	}
+
+	return hex.EncodeToString(buf), nil
+}
+
+// owner возвращает владельца подписок для запроса: аутентифицированного пользователя из ctx.
+// Без аутентификации все подписки принадлежат владельцу по умолчанию "".
+func owner(ctx context.Context) string {
+	principal, _ := auth.FromContext(ctx)
+	return principal.Subject
+}
@@ -0,0 +1,270 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RoGogDBD/ecom/internal/auth"
+	"github.com/RoGogDBD/ecom/internal/models"
+)
+
+// receiver тестовый получатель webhook: отвечает статусами из statuses по очереди
+// (последний повторяется) и запоминает полученные запросы.
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []receivedRequest
+}
+
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.requests = append(r.requests, receivedRequest{header: req.Header.Clone(), body: body})
+	status := http.StatusOK
+	if len(r.statuses) > 0 {
+		status = r.statuses[min(len(r.requests), len(r.statuses))-1]
+	}
+	w.WriteHeader(status)
+}
+
+func (r *receiver) received() []receivedRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]receivedRequest(nil), r.requests...)
+}
+
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+
+	s := New(Options{Workers: 2, MaxAttempts: 3, AllowPrivateNetworks: true, Backoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond})
+	t.Cleanup(s.Close)
+	return s
+}
+
+// waitDelivery ждет завершения последней доставки подписки.
+func waitDelivery(t *testing.T, s *Service, ctx context.Context, id int) models.WebhookDelivery {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		deliveries, err := s.Deliveries(ctx, id)
+		if err != nil {
+			t.Fatalf("неожиданная ошибка: %v", err)
+		}
+		if len(deliveries) > 0 && deliveries[0].Status != models.DeliveryPending {
+			return deliveries[0]
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	t.Fatal("доставка не завершилась")
+	return models.WebhookDelivery{}
+}
+
+func TestServiceDeliversSignedEvent(t *testing.T) {
+	recv := &receiver{}
+	server := httptest.NewServer(recv)
+	defer server.Close()
+
+	s := newTestService(t)
+	ctx := context.Background()
+
+	hook, err := s.Create(ctx, models.Webhook{URL: server.URL, Events: []string{models.WebhookEventCompleted}})
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if hook.Secret == "" {
+		t.Fatal("ожидался сгенерированный secret")
+	}
+
+	now := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	// Создание незавершенной задачи не интересует подписку.
+	s.Publish(models.WebhookEventCreated, models.Todo{ID: 1, Title: "задача", UpdatedAt: now})
+	todo := models.Todo{ID: 1, Title: "задача", Completed: true, UpdatedAt: now, CompletedAt: &now}
+	s.Publish(models.WebhookEventUpdated, todo)
+	s.PublishCompleted(todo)
+
+	delivery := waitDelivery(t, s, ctx, hook.ID)
+	if delivery.Status != models.DeliverySucceeded || delivery.Attempts != 1 || delivery.Event != models.WebhookEventCompleted {
+		t.Fatalf("неожиданная доставка %+v", delivery)
+	}
+
+	requests := recv.received()
+	if len(requests) != 1 {
+		t.Fatalf("ожидался 1 запрос, получено %d", len(requests))
+	}
+	req := requests[0]
+
+	want := Signature(hook.Secret, req.header.Get(HeaderTimestamp), req.body)
+	if got := req.header.Get(HeaderSignature); got != want {
+		t.Fatalf("подпись %q, ожидалась %q", got, want)
+	}
+	if got := req.header.Get(HeaderDelivery); got != delivery.ID {
+		t.Fatalf("идентификатор доставки %q, ожидался %q", got, delivery.ID)
+	}
+
+	var body payload
+	if err := json.Unmarshal(req.body, &body); err != nil {
+		t.Fatalf("некорректное тело: %v", err)
+	}
+	if body.Event != models.WebhookEventCompleted || body.Todo.ID != 1 {
+		t.Fatalf("неожиданное тело %+v", body)
+	}
+}
+
+func TestServiceRetries(t *testing.T) {
+	cases := []struct {
+		name         string
+		statuses     []int
+		wantStatus   string
+		wantAttempts int
+		wantCode     int
+	}{
+		{
+			name:         "успех после временных ошибок",
+			statuses:     []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusNoContent},
+			wantStatus:   models.DeliverySucceeded,
+			wantAttempts: 3,
+			wantCode:     http.StatusNoContent,
+		},
+		{
+			name:         "попытки исчерпаны",
+			statuses:     []int{http.StatusInternalServerError},
+			wantStatus:   models.DeliveryFailed,
+			wantAttempts: 3,
+			wantCode:     http.StatusInternalServerError,
+		},
+		{
+			name:         "отказ получателя не повторяется",
+			statuses:     []int{http.StatusGone},
+			wantStatus:   models.DeliveryFailed,
+			wantAttempts: 1,
+			wantCode:     http.StatusGone,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			recv := &receiver{statuses: tc.statuses}
+			server := httptest.NewServer(recv)
+			defer server.Close()
+
+			s := newTestService(t)
+			ctx := context.Background()
+
+			hook, err := s.Create(ctx, models.Webhook{URL: server.URL, Events: []string{models.WebhookEventDeleted}})
+			if err != nil {
+				t.Fatalf("неожиданная ошибка: %v", err)
+			}
+
+			s.Publish(models.WebhookEventDeleted, models.Todo{ID: 7})
+
+			delivery := waitDelivery(t, s, ctx, hook.ID)
+			if delivery.Status != tc.wantStatus || delivery.Attempts != tc.wantAttempts || delivery.ResponseCode != tc.wantCode {
+				t.Fatalf("доставка %+v, ожидались статус %s, попыток %d, код %d",
+					delivery, tc.wantStatus, tc.wantAttempts, tc.wantCode)
+			}
+			if got := len(recv.received()); got != tc.wantAttempts {
+				t.Fatalf("получатель получил %d запросов, ожидалось %d", got, tc.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestServiceValidation(t *testing.T) {
+	cases := []struct {
+		name    string
+		webhook models.Webhook
+		wantErr error
+	}{
+		{
+			name:    "корректная подписка",
+			webhook: models.Webhook{URL: "https://example.com/hook", Events: []string{"created", "created"}},
+		},
+		{
+			name:    "относительный адрес",
+			webhook: models.Webhook{URL: "/hook", Events: []string{"created"}},
+			wantErr: models.ErrInvalidWebhook,
+		},
+		{
+			name:    "неподдерживаемая схема",
+			webhook: models.Webhook{URL: "ftp://example.com", Events: []string{"created"}},
+			wantErr: models.ErrInvalidWebhook,
+		},
+		{
+			name:    "без событий",
+			webhook: models.Webhook{URL: "https://example.com/hook"},
+			wantErr: models.ErrInvalidWebhook,
+		},
+		{
+			name:    "неизвестное событие",
+			webhook: models.Webhook{URL: "https://example.com/hook", Events: []string{"archived"}},
+			wantErr: models.ErrInvalidWebhook,
+		},
+	}
+
+	s := newTestService(t)
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			created, err := s.Create(context.Background(), tc.webhook)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("ожидалась ошибка %v, получено %v", tc.wantErr, err)
+			}
+			if err == nil && len(created.Events) != 1 {
+				t.Fatalf("ожидались события без повторов, получено %v", created.Events)
+			}
+		})
+	}
+}
+
+func TestServiceTenants(t *testing.T) {
+	s := newTestService(t)
+	alice := auth.NewContext(context.Background(), auth.Principal{Subject: "alice", Method: auth.MethodAPIKey})
+	bob := auth.NewContext(context.Background(), auth.Principal{Subject: "bob", Method: auth.MethodAPIKey})
+
+	hook, err := s.Create(alice, models.Webhook{URL: "https://example.com/hook", Events: []string{"created"}, Secret: "ключ"})
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+
+	got, err := s.GetByID(alice, hook.ID)
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if got.Secret != "" {
+		t.Fatal("secret не должен возвращаться после создания")
+	}
+
+	if _, err := s.GetByID(bob, hook.ID); !errors.Is(err, models.ErrWebhookNotFound) {
+		t.Fatalf("ожидалась ошибка %v для чужой подписки, получено %v", models.ErrWebhookNotFound, err)
+	}
+	if err := s.Delete(bob, hook.ID); !errors.Is(err, models.ErrWebhookNotFound) {
+		t.Fatalf("ожидалась ошибка %v при удалении чужой подписки, получено %v", models.ErrWebhookNotFound, err)
+	}
+
+	// События задач другого владельца не доставляются.
+	s.Publish(models.WebhookEventCreated, models.Todo{ID: 1, Owner: "bob"})
+	deliveries, err := s.Deliveries(alice, hook.ID)
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if len(deliveries) != 0 {
+		t.Fatalf("ожидалось 0 доставок, получено %d", len(deliveries))
+	}
+}